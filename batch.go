@@ -0,0 +1,339 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrBatchOperationsRequired
+	ErrBatchOperationsRequired error = errors.New("Batch field Operations is required")
+	// ErrBatchTooManyOperations
+	ErrBatchTooManyOperations error = errors.New("Batch has too many operations")
+	// ErrBatchOperationNotValid
+	ErrBatchOperationNotValid error = errors.New("Batch operation is not valid")
+	// ErrBatchReferenceNotValid
+	ErrBatchReferenceNotValid error = errors.New("Batch reference is not valid")
+)
+
+// maxBatchOperations is maximal number of operations in one batch.
+const maxBatchOperations = 1000
+
+// Operations which can be applied in batch.
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+	BatchOpMove   = "move"
+)
+
+// OperationBatch is operation of TaskService which is recorded in history for
+// all changes of the batch so they are undone together.
+const OperationBatch = "batch"
+
+// batchRefPrefix is prefix of placeholders of Tasks created in batch.
+const batchRefPrefix = "$"
+
+// BatchTaskID is TaskID in batch operation. It's either TaskID of existing
+// Task (eg. "12") or placeholder of Task created by earlier operation of the
+// same batch (eg. "$checklist").
+type BatchTaskID string
+
+// isRef returns true if BatchTaskID is placeholder.
+func (id BatchTaskID) isRef() bool {
+	return strings.HasPrefix(string(id), batchRefPrefix)
+}
+
+// valid returns true if BatchTaskID is TaskID or non empty placeholder.
+func (id BatchTaskID) valid() bool {
+	if id.isRef() {
+		return len(id) > len(batchRefPrefix)
+	}
+
+	taskID, err := strconv.Atoi(string(id))
+	return err == nil && taskID > 0
+}
+
+// resolve returns TaskID of the BatchTaskID. Placeholders are looked up in
+// given TaskIDs of Tasks created so far.
+func (id BatchTaskID) resolve(refs map[BatchTaskID]TaskID) (TaskID, error) {
+	if id.isRef() {
+		taskID, found := refs[id]
+		if !found {
+			return 0, ErrBatchReferenceNotValid
+		}
+
+		return taskID, nil
+	}
+
+	taskID, err := strconv.Atoi(string(id))
+	if err != nil {
+		return 0, ErrBatchReferenceNotValid
+	}
+
+	return TaskID(taskID), nil
+}
+
+// resolveBatchPath returns TaskID path with resolved placeholders.
+func resolveBatchPath(path []BatchTaskID, refs map[BatchTaskID]TaskID) ([]TaskID, error) {
+	result := make([]TaskID, len(path))
+	for i, id := range path {
+		taskID, err := id.resolve(refs)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = taskID
+	}
+
+	return result, nil
+}
+
+// BatchOperation is single operation of the batch. Path is TaskID path of
+// the parent for create and TaskID path of the Task for other operations.
+type BatchOperation struct {
+	// Op is one of the batch operations.
+	Op string
+	// Ref is placeholder of the created Task (create only, optional).
+	Ref BatchTaskID
+	// Path is TaskID path the operation is applied on.
+	Path []BatchTaskID
+	// Target is TaskID path of the new parent (move only).
+	Target []BatchTaskID
+	// Create contains fields of the created Task (create only).
+	Create CreateFields
+	// Update contains updated fields of the Task (update only).
+	Update UpdateFields
+	// Delete contains options of the delete (delete only).
+	Delete DeleteFields
+}
+
+// BatchResult is result of single operation of the batch: the Task after
+// the operation with its TaskID path.
+type BatchResult struct {
+	Op   string      `json:"op"`
+	Ref  BatchTaskID `json:"ref,omitempty"`
+	Path TaskIDPath  `json:"path"`
+	Task Task        `json:"task"`
+}
+
+// BatchError is error of the batch operation at given index which caused
+// rollback of the whole batch.
+type BatchError struct {
+	// Index is index of the failed operation.
+	Index int
+	// Err is error of the operation.
+	Err error
+}
+
+// Error returns error message with index of the failed operation.
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("Batch operation %d failed: %s", e.Index, e.Err)
+}
+
+// Batch applies given operations in order in one storage transaction. If any
+// operation fails, changes of all operations are rolled back and BatchError
+// is returned. Changes are recorded in history as one operation.
+// Batch implements TaskService interface.
+func (s *TaskStorageService) Batch(operations []BatchOperation) ([]BatchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var results []BatchResult
+	_, err := s.transaction(OperationBatch, 0, func() error {
+		var err error
+		results, err = s.batch(operations)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// batch applies given operations in order and returns their results. Caller
+// must hold the lock.
+func (s *TaskStorageService) batch(operations []BatchOperation) ([]BatchResult, error) {
+	refs := map[BatchTaskID]TaskID{}
+	results := make([]BatchResult, 0, len(operations))
+	for i := range operations {
+		result, err := s.batchOperation(&operations[i], refs)
+		if err != nil {
+			fmt.Printf("(DEBUG) service: Batch operation %d failed: %s\n", i, err)
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// batchOperation applies single operation and stores TaskID of created Task
+// under its placeholder. Caller must hold the lock.
+func (s *TaskStorageService) batchOperation(op *BatchOperation, refs map[BatchTaskID]TaskID) (BatchResult, error) {
+	path, err := resolveBatchPath(op.Path, refs)
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	result := BatchResult{Op: op.Op, Ref: op.Ref, Path: path}
+	switch op.Op {
+	case BatchOpCreate:
+		if _, found := refs[op.Ref]; found {
+			return BatchResult{}, ErrBatchReferenceNotValid
+		}
+
+		result.Task, err = s.createTask(path, op.Create)
+		if err != nil {
+			return BatchResult{}, err
+		}
+
+		if op.Ref != "" {
+			refs[op.Ref] = result.Task.ID
+		}
+		result.Path = childPath(path, result.Task.ID)
+	case BatchOpUpdate:
+		result.Task, err = s.updateTask(path, op.Update)
+	case BatchOpDelete:
+		result.Task, err = s.deleteTask(path, op.Delete)
+	case BatchOpMove:
+		target, err := resolveBatchPath(op.Target, refs)
+		if err != nil {
+			return BatchResult{}, err
+		}
+
+		result.Task, err = s.moveTask(path, target)
+		if err != nil {
+			return BatchResult{}, err
+		}
+		result.Path = childPath(target, result.Task.ID)
+	default:
+		return BatchResult{}, ErrBatchOperationNotValid
+	}
+
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	return result, nil
+}
+
+// JSONBatch represents batch of operations in JSON request.
+type JSONBatch struct {
+	Operations []JSONBatchOperation `json:"operations"`
+}
+
+// JSONBatchOperation represents single batch operation in JSON request.
+// Task contains fields for create and update, Target is required for move
+// (empty array moves Task to top level).
+type JSONBatchOperation struct {
+	Op     string         `json:"op"`
+	Ref    BatchTaskID    `json:"ref"`
+	Path   []BatchTaskID  `json:"path"`
+	Target *[]BatchTaskID `json:"target"`
+	Task   *JSONTask      `json:"task"`
+}
+
+// Validate checks every operation of the batch. Placeholders must be defined
+// by create before they are used and every placeholder is defined only once.
+func (b *JSONBatch) Validate() error {
+	if len(b.Operations) == 0 {
+		return ErrBatchOperationsRequired
+	}
+
+	if len(b.Operations) > maxBatchOperations {
+		return ErrBatchTooManyOperations
+	}
+
+	refs := map[BatchTaskID]bool{}
+	for i := range b.Operations {
+		if err := b.Operations[i].validate(refs); err != nil {
+			return &BatchError{Index: i, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// validate checks the operation with given placeholders defined by previous
+// operations and defines its own placeholder.
+func (o *JSONBatchOperation) validate(refs map[BatchTaskID]bool) error {
+	paths := [][]BatchTaskID{o.Path}
+	if o.Target != nil {
+		paths = append(paths, *o.Target)
+	}
+	for _, path := range paths {
+		for _, id := range path {
+			if !id.valid() || (id.isRef() && !refs[id]) {
+				return ErrBatchReferenceNotValid
+			}
+		}
+	}
+
+	if o.Ref != "" && (o.Op != BatchOpCreate || !o.Ref.isRef() || !o.Ref.valid() || refs[o.Ref]) {
+		return ErrBatchReferenceNotValid
+	}
+
+	switch o.Op {
+	case BatchOpCreate:
+		if o.Task == nil {
+			return ErrTaskLabelIsRequired
+		}
+		if err := o.Task.Validate(NewCreateValidator()); err != nil {
+			return err
+		}
+		if o.Ref != "" {
+			refs[o.Ref] = true
+		}
+	case BatchOpUpdate:
+		if len(o.Path) == 0 {
+			return ErrTaskPathNotValid
+		}
+		if o.Task == nil {
+			return ErrTaskLabelOrCompletedRequired
+		}
+		return o.Task.Validate(NewUpdateValidator())
+	case BatchOpDelete:
+		if len(o.Path) == 0 {
+			return ErrTaskPathNotValid
+		}
+	case BatchOpMove:
+		if len(o.Path) == 0 {
+			return ErrTaskPathNotValid
+		}
+		if o.Target == nil {
+			return ErrTaskMoveTargetRequired
+		}
+	default:
+		return ErrBatchOperationNotValid
+	}
+
+	return nil
+}
+
+// batchOperations returns BatchOperations of validated JSONBatch.
+func (b *JSONBatch) batchOperations() []BatchOperation {
+	operations := make([]BatchOperation, len(b.Operations))
+	for i, o := range b.Operations {
+		operation := BatchOperation{
+			Op:   o.Op,
+			Ref:  o.Ref,
+			Path: o.Path,
+		}
+		if o.Target != nil {
+			operation.Target = *o.Target
+		}
+
+		switch o.Op {
+		case BatchOpCreate:
+			operation.Create = o.Task.createFields()
+		case BatchOpUpdate:
+			operation.Update = o.Task.updateFields()
+		}
+		operations[i] = operation
+	}
+
+	return operations
+}
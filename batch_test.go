@@ -0,0 +1,234 @@
+package tasks
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTaskServiceBatch(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	completed := true
+	results, err := service.Batch([]BatchOperation{
+		{Op: BatchOpCreate, Ref: "$list", Create: CreateFields{Label: "checklist"}},
+		{Op: BatchOpCreate, Ref: "$first", Path: []BatchTaskID{"$list"}, Create: CreateFields{Label: "first"}},
+		{Op: BatchOpCreate, Path: []BatchTaskID{"$list"}, Create: CreateFields{Label: "second"}},
+		{Op: BatchOpUpdate, Path: []BatchTaskID{"$list", "$first"}, Update: UpdateFields{Completed: &completed}},
+		{Op: BatchOpMove, Path: []BatchTaskID{"1"}, Target: []BatchTaskID{"$list"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := results[0].Task.ID
+	paths := []TaskIDPath{}
+	for _, result := range results {
+		paths = append(paths, result.Path)
+	}
+
+	expected := []TaskIDPath{
+		TaskIDPath{list},
+		TaskIDPath{list, results[1].Task.ID},
+		TaskIDPath{list, results[2].Task.ID},
+		TaskIDPath{list, results[1].Task.ID},
+		TaskIDPath{list, foo.ID},
+	}
+	if !reflect.DeepEqual(expected, paths) {
+		t.Fatalf("expected paths %v got %v", expected, paths)
+	}
+
+	if !results[3].Task.Completed {
+		t.Fatalf("expected completed Task got %v", results[3].Task)
+	}
+
+	// Failed operation rolls back the whole batch including history.
+	history, err := service.storage.FindAllHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = service.Batch([]BatchOperation{
+		{Op: BatchOpCreate, Ref: "$new", Create: CreateFields{Label: "new"}},
+		{Op: BatchOpDelete, Path: []BatchTaskID{BatchTaskID(strconv.Itoa(int(list)))}},
+		{Op: BatchOpUpdate, Path: []BatchTaskID{"42"}, Update: UpdateFields{Completed: &completed}},
+	})
+	if batchErr, ok := err.(*BatchError); !ok || batchErr.Index != 2 || batchErr.Err != ErrTaskNotFound {
+		t.Fatalf("expected error of operation 2 got %v", err)
+	}
+
+	tasks, err := service.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != list || len(tasks[0].Children) != 3 {
+		t.Fatalf("expected only checklist with 3 sub tasks got %v", tasks)
+	}
+
+	if trash, _ := service.FindTrash(); len(trash) != 0 {
+		t.Fatalf("expected empty trash got %v", trash)
+	}
+
+	if res, _ := service.storage.FindAllHistory(); !reflect.DeepEqual(history, res) {
+		t.Fatalf("expected history %v got %v", history, res)
+	}
+
+	// Whole batch is undone as one operation.
+	if _, err := service.Undo(); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err = service.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != foo.ID {
+		t.Fatalf("expected only Task %d got %v", foo.ID, tasks)
+	}
+}
+
+// blockingStorage is TaskMemoryStorage which blocks Move until it's
+// released so readers can run in the middle of the operation.
+type blockingStorage struct {
+	*TaskMemoryStorage
+
+	started chan struct{}
+	release chan struct{}
+}
+
+// Move signals start of the move and waits for release.
+func (s *blockingStorage) Move(from []TaskID, toParent []TaskID) error {
+	close(s.started)
+	<-s.release
+
+	return s.TaskMemoryStorage.Move(from, toParent)
+}
+
+func TestTaskServiceBatchIsolation(t *testing.T) {
+	storage := &blockingStorage{
+		TaskMemoryStorage: NewTaskMemoryStorage(),
+		started:           make(chan struct{}),
+		release:           make(chan struct{}),
+	}
+	service := NewTaskStorageService(storage, NewSystemClock())
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	completed := true
+	batchErr := make(chan error)
+	go func() {
+		_, err := service.Batch([]BatchOperation{
+			{Op: BatchOpCreate, Ref: "$new", Create: CreateFields{Label: "new"}},
+			{Op: BatchOpMove, Path: []BatchTaskID{BatchTaskID(strconv.Itoa(int(foo.ID)))}, Target: []BatchTaskID{"$new"}},
+			{Op: BatchOpUpdate, Path: []BatchTaskID{"42"}, Update: UpdateFields{Completed: &completed}},
+		})
+		batchErr <- err
+	}()
+	<-storage.started
+
+	// Reader started in the middle of the batch waits until it ends.
+	read := make(chan []Task)
+	go func() {
+		tasks, _ := service.FindAll()
+		read <- tasks
+	}()
+
+	select {
+	case tasks := <-read:
+		t.Fatalf("expected reader to wait for the batch got %v", tasks)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(storage.release)
+	if err := <-batchErr; err == nil {
+		t.Fatal("expected batch to fail")
+	}
+
+	if tasks := <-read; len(tasks) != 1 || tasks[0].ID != foo.ID {
+		t.Fatalf("expected only Task %d got %v", foo.ID, tasks)
+	}
+}
+
+func TestJSONBatchValidate(t *testing.T) {
+	tests := map[string]struct {
+		batch string
+		index int
+		err   error
+	}{
+		"valid": {
+			batch: `{"operations":[{"op":"create","ref":"$a","task":{"label":"foo"}},{"op":"move","path":["1"],"target":["$a"]},{"op":"delete","path":["$a"]}]}`,
+			index: -1,
+		},
+		"empty": {
+			batch: `{"operations":[]}`,
+			index: -1,
+			err:   ErrBatchOperationsRequired,
+		},
+		"unknown op": {
+			batch: `{"operations":[{"op":"create","task":{"label":"foo"}},{"op":"clone","path":["1"]}]}`,
+			index: 1,
+			err:   ErrBatchOperationNotValid,
+		},
+		"undefined ref": {
+			batch: `{"operations":[{"op":"delete","path":["$a"]},{"op":"create","ref":"$a","task":{"label":"foo"}}]}`,
+			index: 0,
+			err:   ErrBatchReferenceNotValid,
+		},
+		"duplicate ref": {
+			batch: `{"operations":[{"op":"create","ref":"$a","task":{"label":"foo"}},{"op":"create","ref":"$a","task":{"label":"bar"}}]}`,
+			index: 1,
+			err:   ErrBatchReferenceNotValid,
+		},
+		"ref without prefix": {
+			batch: `{"operations":[{"op":"create","ref":"a","task":{"label":"foo"}}]}`,
+			index: 0,
+			err:   ErrBatchReferenceNotValid,
+		},
+		"not valid id": {
+			batch: `{"operations":[{"op":"delete","path":["foo"]}]}`,
+			index: 0,
+			err:   ErrBatchReferenceNotValid,
+		},
+		"not valid task": {
+			batch: `{"operations":[{"op":"create","task":{"label":""}}]}`,
+			index: 0,
+			err:   ErrTaskLabelIsNotValid,
+		},
+		"move without target": {
+			batch: `{"operations":[{"op":"move","path":["1"]}]}`,
+			index: 0,
+			err:   ErrTaskMoveTargetRequired,
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		var batch JSONBatch
+		if err := json.Unmarshal([]byte(tc.batch), &batch); err != nil {
+			t.Fatal(err)
+		}
+
+		err := batch.Validate()
+		if batchErr, ok := err.(*BatchError); ok {
+			if batchErr.Index != tc.index || batchErr.Err != tc.err {
+				t.Fatalf("expected error %v of operation %d got %v", tc.err, tc.index, err)
+			}
+			continue
+		}
+
+		if tc.index != -1 || err != tc.err {
+			t.Fatalf("expected error %v got %v", tc.err, err)
+		}
+	}
+}
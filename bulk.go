@@ -0,0 +1,178 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrBulkQueryRequired
+	ErrBulkQueryRequired error = errors.New("Bulk field Query is required")
+)
+
+// Operations of TaskService which are recorded in history for all changes of
+// the bulk operation so they are undone together.
+const (
+	OperationBulkUpdate = "bulk_update"
+	OperationBulkDelete = "bulk_delete"
+)
+
+// BulkFields selects Tasks changed by bulk operation.
+type BulkFields struct {
+	// Query matches changed Tasks anywhere in the tree.
+	Query SearchQuery
+	// DryRun returns matched Tasks without changing them.
+	DryRun bool
+}
+
+// BulkResult is result of bulk operation for single matched Task: the Task
+// after the change (before the change for dry run) or error of the change.
+// Task doesn't contain children but keeps its progress.
+type BulkResult struct {
+	Path  TaskIDPath `json:"path"`
+	Task  *Task      `json:"task,omitempty"`
+	Error string     `json:"error,omitempty"`
+}
+
+// BulkUpdate updates every Task matching the query with given UpdateFields.
+// Tasks are updated in depth first order, failure of one Task doesn't stop
+// the others and it's returned in its result. Changes are recorded in history
+// as one operation.
+// BulkUpdate implements TaskService interface.
+func (s *TaskStorageService) BulkUpdate(bulk BulkFields, fields UpdateFields) ([]BulkResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results, err := s.bulkMatches(bulk.Query, false)
+	if err != nil || bulk.DryRun {
+		return results, err
+	}
+
+	// Bulk is applied to all matched Tasks, single Task precondition makes
+	// no sense.
+	fields.IfMatch = nil
+
+	return s.bulk(OperationBulkUpdate, results, func(path []TaskID) (Task, error) {
+		return s.updateTask(path, fields)
+	})
+}
+
+// BulkDelete moves every Task matching the query with its children into
+// trash. Matched sub tasks of matched Tasks are deleted together with them so
+// they are not returned. Failure of one Task doesn't stop the others and it's
+// returned in its result. Changes are recorded in history as one operation.
+// BulkDelete implements TaskService interface.
+func (s *TaskStorageService) BulkDelete(bulk BulkFields) ([]BulkResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results, err := s.bulkMatches(bulk.Query, true)
+	if err != nil || bulk.DryRun {
+		return results, err
+	}
+
+	return s.bulk(OperationBulkDelete, results, func(path []TaskID) (Task, error) {
+		return s.deleteTask(path, DeleteFields{})
+	})
+}
+
+// bulkMatches returns results with Tasks matching the query in depth first
+// order. If topmost is true, Tasks which have matching ancestor are skipped.
+// Caller must hold the lock.
+func (s *TaskStorageService) bulkMatches(query SearchQuery, topmost bool) ([]BulkResult, error) {
+	tasks, err := s.storage.FindAll()
+	if err != nil {
+		fmt.Println("(DEBUG) service: Finding Tasks of bulk operation failed.")
+		return nil, err
+	}
+
+	matched := map[TaskID]bool{}
+	results := []BulkResult{}
+	for _, match := range searchTasks(tasks, query, s.clock.Now()) {
+		if topmost && hasMatchedAncestor(match.Path, matched) {
+			continue
+		}
+		matched[match.Task.ID] = true
+
+		task := match.Task
+		results = append(results, BulkResult{Path: match.Path, Task: &task})
+	}
+
+	return results, nil
+}
+
+// hasMatchedAncestor returns true if any ancestor in TaskID path is matched.
+func hasMatchedAncestor(path TaskIDPath, matched map[TaskID]bool) bool {
+	for _, id := range path[:len(path)-1] {
+		if matched[id] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bulk applies given function on Tasks of the results in one storage
+// transaction and replaces the results with changed Tasks or errors. Task
+// which fails before it's changed is skipped, failure after a change (eg. of
+// the storage) rolls back the whole bulk operation. Caller must hold the
+// lock.
+func (s *TaskStorageService) bulk(op string, results []BulkResult, apply func(path []TaskID) (Task, error)) ([]BulkResult, error) {
+	_, err := s.transaction(op, 0, func() error {
+		for i := range results {
+			recorded := s.history.recorded()
+			task, err := apply(results[i].Path)
+			if err != nil && s.history.recorded() > recorded {
+				fmt.Printf("(DEBUG) service: Bulk operation on Task %d failed after change: %s\n", results[i].Task.ID, err)
+				return err
+			}
+			if err != nil {
+				fmt.Printf("(DEBUG) service: Bulk operation on Task %d failed: %s\n", results[i].Task.ID, err)
+				results[i].Task = nil
+				results[i].Error = err.Error()
+				continue
+			}
+			results[i].Task = task.withProgress()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// JSONBulk represents bulk operation request. Query is search query matching
+// changed Tasks, Task contains updated fields (update only).
+type JSONBulk struct {
+	Query  string    `json:"query"`
+	DryRun bool      `json:"dry_run"`
+	Task   *JSONTask `json:"task"`
+}
+
+// Validate checks that query is set and Task fields are valid for update.
+// Query is parsed by bulkFields.
+func (b *JSONBulk) Validate() error {
+	if strings.TrimSpace(b.Query) == "" {
+		return ErrBulkQueryRequired
+	}
+
+	if b.Task != nil {
+		return b.Task.Validate(NewUpdateValidator())
+	}
+
+	return nil
+}
+
+// bulkFields returns BulkFields with parsed query of validated JSONBulk.
+func (b *JSONBulk) bulkFields() (BulkFields, error) {
+	query, err := ParseSearchQuery(b.Query)
+	if err != nil {
+		return BulkFields{}, err
+	}
+
+	return BulkFields{Query: query, DryRun: b.DryRun}, nil
+}
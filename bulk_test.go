@@ -0,0 +1,150 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTaskServiceBulkUpdate(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo", Tags: []string{"sprint-12"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bar, err := service.Create([]TaskID{foo.ID}, CreateFields{Label: "bar", Tags: []string{"sprint-12"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	baz, err := service.Create([]TaskID{}, CreateFields{Label: "baz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	qux, err := service.Create([]TaskID{}, CreateFields{Label: "qux", Tags: []string{"sprint-12"}, BlockedBy: []TaskID{baz.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query, err := ParseSearchQuery("tag:sprint-12")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := func(results []BulkResult) []TaskIDPath {
+		res := []TaskIDPath{}
+		for _, result := range results {
+			res = append(res, result.Path)
+		}
+		return res
+	}
+	expected := []TaskIDPath{
+		TaskIDPath{foo.ID},
+		TaskIDPath{foo.ID, bar.ID},
+		TaskIDPath{qux.ID},
+	}
+
+	completed := true
+	results, err := service.BulkUpdate(BulkFields{Query: query, DryRun: true}, UpdateFields{Completed: &completed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := paths(results); !reflect.DeepEqual(expected, res) {
+		t.Fatalf("expected paths %v got %v", expected, res)
+	}
+
+	if task, _ := service.Find([]TaskID{foo.ID}); task.Completed {
+		t.Fatalf("expected dry run to keep Task %v", task)
+	}
+
+	results, err = service.BulkUpdate(BulkFields{Query: query}, UpdateFields{Completed: &completed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := paths(results); !reflect.DeepEqual(expected, res) {
+		t.Fatalf("expected paths %v got %v", expected, res)
+	}
+
+	// Blocked Task fails alone.
+	for i, result := range results[:2] {
+		if result.Error != "" || result.Task == nil || !result.Task.Completed || result.Task.Children != nil {
+			t.Fatalf("expected completed Task in result %d got %v", i, result)
+		}
+	}
+	if results[2].Task != nil || results[2].Error != ErrTaskBlockedByOpenTask.Error() {
+		t.Fatalf("expected error %v got %v", ErrTaskBlockedByOpenTask, results[2])
+	}
+
+	// Whole bulk update is undone as one operation.
+	if _, err := service.Undo(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range expected[:2] {
+		if task, _ := service.Find(path); task.Completed {
+			t.Fatalf("expected not completed Task got %v", task)
+		}
+	}
+}
+
+func TestTaskServiceBulkDelete(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo", Status: StatusDone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Create([]TaskID{foo.ID}, CreateFields{Label: "bar", Status: StatusDone}); err != nil {
+		t.Fatal(err)
+	}
+	baz, err := service.Create([]TaskID{}, CreateFields{Label: "baz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	qux, err := service.Create([]TaskID{baz.ID}, CreateFields{Label: "qux", Status: StatusDone})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query, err := ParseSearchQuery("completed:true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Matched sub task of matched Task is deleted with it.
+	results, err := service.BulkDelete(BulkFields{Query: query})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []TaskIDPath{TaskIDPath{foo.ID}, TaskIDPath{baz.ID, qux.ID}}
+	res := []TaskIDPath{}
+	for _, result := range results {
+		if result.Error != "" {
+			t.Fatalf("expected deleted Task got %v", result)
+		}
+		res = append(res, result.Path)
+	}
+	if !reflect.DeepEqual(expected, res) {
+		t.Fatalf("expected paths %v got %v", expected, res)
+	}
+
+	tasks, err := service.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != baz.ID || len(tasks[0].Children) != 0 {
+		t.Fatalf("expected only Task %d got %v", baz.ID, tasks)
+	}
+
+	if trash, _ := service.FindTrash(); len(trash) != 2 {
+		t.Fatalf("expected 2 trashed Tasks got %v", trash)
+	}
+
+	if _, err := service.Undo(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tasks, _ := service.FindAll(); len(tasks) != 2 {
+		t.Fatalf("expected restored Tasks got %v", tasks)
+	}
+}
@@ -0,0 +1,27 @@
+package tasks
+
+import (
+	"time"
+)
+
+// Clock is interface which provides current time. It's used by TaskService
+// for Task timestamps so time can be controlled in tests.
+type Clock interface {
+	// Now returns current time.
+	Now() time.Time
+}
+
+// SystemClock is implementation of Clock which returns system time.
+// SystemClock implements Clock interface.
+type SystemClock struct{}
+
+// NewSystemClock returns new instance of SystemClock
+func NewSystemClock() *SystemClock {
+	return &SystemClock{}
+}
+
+// Now returns current system time in UTC.
+// Now implements Clock interface.
+func (c *SystemClock) Now() time.Time {
+	return time.Now().UTC()
+}
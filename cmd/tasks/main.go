@@ -1,21 +1,87 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/czertbytes/tasks"
 )
 
 func main() {
-	taskMemoryStorage := tasks.NewTaskMemoryStorage()
-	taskService := tasks.NewTaskStorageService(taskMemoryStorage)
+	dataDir := flag.String("data-dir", "", "directory where tasks are persisted (in memory only if empty)")
+	completion := flag.String("completion-policy", "", "comma separated completion rules: cascade-down, complete-parent, reopen-parent")
+	trashRetention := flag.Duration("trash-retention", 30*24*time.Hour, "how long deleted tasks are kept in trash (forever if 0)")
+	flag.Parse()
+
+	completionPolicy, err := tasks.ParseCompletionPolicy(*completion)
+	if err != nil {
+		log.Fatalf("(FATAL) main: parsing completion policy %q failed: %s\n", *completion, err)
+	}
+
+	var taskStorage tasks.TaskStorage
+	if *dataDir != "" {
+		taskFileStorage, err := tasks.NewTaskFileStorage(*dataDir)
+		if err != nil {
+			log.Fatalf("(FATAL) main: opening data directory %q failed: %s\n", *dataDir, err)
+		}
+		// Every change is synced in the log before it is applied so there is
+		// no need to close the storage on exit.
+		taskStorage = taskFileStorage
+	} else {
+		taskStorage = tasks.NewTaskMemoryStorage()
+	}
+
+	taskService := tasks.NewTaskStorageService(taskStorage, tasks.NewSystemClock())
+	taskService.SetCompletionPolicy(completionPolicy)
 	tasksHandler := tasks.NewTasksHandler(taskService)
 	taskHandler := tasks.NewTaskHandler(taskService)
+	taskIDHandler := tasks.NewTaskIDHandler(taskService)
+	tagsHandler := tasks.NewTagsHandler(taskService)
+	searchHandler := tasks.NewSearchHandler(taskService)
+	trashHandler := tasks.NewTrashHandler(taskService)
+	undoHandler := tasks.NewUndoHandler(taskService)
+	batchHandler := tasks.NewBatchHandler(taskService)
+	bulkHandler := tasks.NewBulkHandler(taskService)
 
 	mux := http.NewServeMux()
 	mux.Handle("/tasks", tasksHandler)
 	mux.Handle("/tasks/", taskHandler)
+	mux.Handle("/tasks/ids/", taskIDHandler)
+	mux.Handle("/tags", tagsHandler)
+	mux.Handle("/search", searchHandler)
+	mux.Handle("/trash", trashHandler)
+	mux.Handle("/trash/", trashHandler)
+	mux.Handle("/undo", undoHandler)
+	mux.Handle("/batch", batchHandler)
+	mux.Handle("/tasks/bulk-update", bulkHandler)
+	mux.Handle("/tasks/bulk-delete", bulkHandler)
+
+	if *trashRetention > 0 {
+		go purgeTrash(taskService, *trashRetention)
+	}
 
 	log.Fatal(http.ListenAndServe(":8080", mux))
 }
+
+// purgeTrash periodically purges tasks which are in trash longer than given
+// retention.
+func purgeTrash(taskService *tasks.TaskStorageService, retention time.Duration) {
+	interval := time.Hour
+	if retention < interval {
+		interval = retention
+	}
+
+	for range time.Tick(interval) {
+		purged, err := taskService.PurgeExpired(retention)
+		if err != nil {
+			log.Printf("(WARN) main: purging trash failed: %s\n", err)
+			continue
+		}
+
+		if purged > 0 {
+			log.Printf("(INFO) main: purged %d tasks from trash\n", purged)
+		}
+	}
+}
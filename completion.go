@@ -0,0 +1,199 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrCompletionPolicyNotValid is returned when completion policy contains
+	// unknown rule.
+	ErrCompletionPolicyNotValid error = errors.New("Completion policy is not valid")
+)
+
+// CompletionPolicy defines how completion of the Task is propagated in the
+// tree. Zero value of CompletionPolicy doesn't propagate completion at all.
+// Completion is propagated only when the workflow allows the change of Task
+// status and Task blocked by open Task is not completed. Cancelled Tasks are
+// treated as completed.
+type CompletionPolicy struct {
+	// CascadeDown completes all descendants of completed Task.
+	CascadeDown bool
+	// CompleteParent completes parent Task when all its children are
+	// completed.
+	CompleteParent bool
+	// ReopenParent reopens completed parent Task when any of its children is
+	// reopened.
+	ReopenParent bool
+}
+
+// ParseCompletionPolicy parses comma separated list of completion policy
+// rules: cascade-down, complete-parent and reopen-parent. Empty value is
+// policy without any rule.
+func ParseCompletionPolicy(value string) (CompletionPolicy, error) {
+	policy := CompletionPolicy{}
+	if value == "" {
+		return policy, nil
+	}
+
+	for _, rule := range strings.Split(value, ",") {
+		switch strings.TrimSpace(rule) {
+		case "cascade-down":
+			policy.CascadeDown = true
+		case "complete-parent":
+			policy.CompleteParent = true
+		case "reopen-parent":
+			policy.ReopenParent = true
+		default:
+			fmt.Printf("(DEBUG) completion: Parsing completion policy failed. Unknown rule %q.\n", rule)
+			return CompletionPolicy{}, ErrCompletionPolicyNotValid
+		}
+	}
+
+	return policy, nil
+}
+
+// TaskProgress is number of completed and all descendants of the Task.
+type TaskProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// progress returns progress of the Task computed from its descendants or nil
+// if the Task has no children. Copy of the Task whose children were removed
+// or truncated returns progress which was computed before (see withProgress
+// and withDepth).
+func (t *Task) progress() *TaskProgress {
+	if len(t.Children) == 0 || t.computedProgress != nil {
+		return t.computedProgress
+	}
+
+	progress := &TaskProgress{}
+	var walk func(children SubTasks)
+	walk = func(children SubTasks) {
+		for _, child := range children {
+			progress.Total++
+			if child.Completed {
+				progress.Done++
+			}
+			walk(child.Children)
+		}
+	}
+	walk(t.Children)
+
+	return progress
+}
+
+// withProgress returns copy of the Task without children which keeps progress
+// computed from the children.
+func (t *Task) withProgress() *Task {
+	task := t.withChildren(nil)
+	task.computedProgress = t.progress()
+
+	return task
+}
+
+// propagateCompletion applies completion policy on the tree after completion
+// of the Task at given TaskID path was changed. Every changed Task gets new
+// revision.
+func (s *TaskStorageService) propagateCompletion(path []TaskID, completed bool) error {
+	if completed && s.completionPolicy.CascadeDown {
+		task, err := s.storage.Find(path)
+		if err != nil {
+			return err
+		}
+
+		if err := s.completeChildren(path, &task); err != nil {
+			return err
+		}
+	}
+
+	if (completed && !s.completionPolicy.CompleteParent) || (!completed && !s.completionPolicy.ReopenParent) {
+		return nil
+	}
+
+	// Walk ancestors from the parent to the root while they change.
+	for i := len(path) - 1; i > 0; i-- {
+		parent, err := s.storage.Find(path[:i])
+		if err != nil {
+			return err
+		}
+
+		if parent.Completed == completed {
+			return nil
+		}
+
+		if completed {
+			for _, child := range parent.Children {
+				if !child.finished() {
+					return nil
+				}
+			}
+		}
+
+		if err := s.setCompleted(path[:i], &parent, completed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// completeChildren completes all not completed descendants of the Task at
+// given TaskID path. Cancelled descendants stay cancelled.
+func (s *TaskStorageService) completeChildren(path []TaskID, task *Task) error {
+	for taskID, child := range task.Children {
+		if !child.finished() {
+			if err := s.setCompleted(childPath(path, taskID), child, true); err != nil {
+				return err
+			}
+		}
+
+		if err := s.completeChildren(childPath(path, taskID), child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setCompleted stores new revision of the Task at given TaskID path with
+// changed completion. Task is not changed if the workflow doesn't allow it or
+// if it would be completed while it's blocked by open Task. Completed
+// recurring Task gets its next occurrence.
+func (s *TaskStorageService) setCompleted(path []TaskID, task *Task, completed bool) error {
+	target := targetStatus(task.status(), nil, &completed)
+	if !s.workflow.allowed(task.status(), target) {
+		return nil
+	}
+
+	if completed && len(task.BlockedBy) > 0 {
+		tasks, err := s.storage.FindAll()
+		if err != nil {
+			return err
+		}
+
+		if newDependencyGraph(tasks).open(task.BlockedBy) {
+			return nil
+		}
+	}
+
+	updatedTask := task.withChildren(nil)
+	updatedTask.setStatus(target)
+	updatedTask.Revision++
+	s.touch(updatedTask, task.Completed)
+	recurrence := takeRecurrence(task, updatedTask)
+
+	if err := s.storage.Update(path, updatedTask); err != nil {
+		return err
+	}
+
+	if recurrence != "" {
+		if _, err := s.recur(path, task, recurrence); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package tasks
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseCompletionPolicy(t *testing.T) {
+	tests := map[string]struct {
+		value  string
+		policy CompletionPolicy
+		err    error
+	}{
+		"empty": {
+			value: "",
+		},
+		"all": {
+			value:  "cascade-down, complete-parent,reopen-parent",
+			policy: CompletionPolicy{CascadeDown: true, CompleteParent: true, ReopenParent: true},
+		},
+		"unknown": {
+			value: "cascade-down,kekeke",
+			err:   ErrCompletionPolicyNotValid,
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		policy, err := ParseCompletionPolicy(tc.value)
+		if err != tc.err {
+			t.Fatalf("expected err %s got %s", tc.err, err)
+		}
+
+		if tc.policy != policy {
+			t.Fatalf("expected policy %+v got %+v", tc.policy, policy)
+		}
+	}
+}
+
+func TestTaskServiceCompletionPolicy(t *testing.T) {
+	completed := true
+	reopened := false
+
+	tests := map[string]struct {
+		policy CompletionPolicy
+		// update is index of Task in the tree root, child, grand child 1 and
+		// grand child 2 which is updated.
+		update    int
+		completed *bool
+		res       [4]bool
+	}{
+		"no policy": {
+			policy:    CompletionPolicy{},
+			update:    1,
+			completed: &completed,
+			res:       [4]bool{false, true, false, false},
+		},
+		"cascade down": {
+			policy:    CompletionPolicy{CascadeDown: true},
+			update:    1,
+			completed: &completed,
+			res:       [4]bool{false, true, true, true},
+		},
+		"cascade down and complete parent": {
+			policy:    CompletionPolicy{CascadeDown: true, CompleteParent: true},
+			update:    1,
+			completed: &completed,
+			res:       [4]bool{true, true, true, true},
+		},
+		"complete parent with open sibling": {
+			policy:    CompletionPolicy{CompleteParent: true},
+			update:    2,
+			completed: &completed,
+			res:       [4]bool{false, false, true, false},
+		},
+		"reopen parent": {
+			policy:    CompletionPolicy{CascadeDown: true, ReopenParent: true},
+			update:    3,
+			completed: &reopened,
+			res:       [4]bool{false, false, true, false},
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		service := NewTaskStorageService(NewTaskMemoryStorage(), &mockClock{})
+		service.SetCompletionPolicy(tc.policy)
+
+		root, _ := service.Create([]TaskID{}, CreateFields{Label: "root"})
+		child, _ := service.Create([]TaskID{root.ID}, CreateFields{Label: "child"})
+		grandChild1, _ := service.Create([]TaskID{root.ID, child.ID}, CreateFields{Label: "grand child 1"})
+		grandChild2, _ := service.Create([]TaskID{root.ID, child.ID}, CreateFields{Label: "grand child 2"})
+		paths := [4][]TaskID{
+			[]TaskID{root.ID},
+			[]TaskID{root.ID, child.ID},
+			[]TaskID{root.ID, child.ID, grandChild1.ID},
+			[]TaskID{root.ID, child.ID, grandChild2.ID},
+		}
+
+		// Reopening needs completed tree.
+		if !*tc.completed {
+			if _, err := service.Update(paths[0], UpdateFields{Completed: &completed}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := service.Update(paths[tc.update], UpdateFields{Completed: tc.completed}); err != nil {
+			t.Fatal(err)
+		}
+
+		for i, path := range paths {
+			task, err := service.Find(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if task.Completed != tc.res[i] {
+				t.Fatalf("expected task %d completed %t got %t", i, tc.res[i], task.Completed)
+			}
+		}
+	}
+}
+
+func TestTaskProgress(t *testing.T) {
+	task := Task{
+		ID:    TaskID(1),
+		Label: "foo",
+		Children: SubTasks{
+			TaskID(2): &Task{
+				ID:        TaskID(2),
+				Label:     "bar",
+				Completed: true,
+				Children: SubTasks{
+					TaskID(3): &Task{ID: TaskID(3), Label: "baz", Completed: true},
+					TaskID(4): &Task{ID: TaskID(4), Label: "qux"},
+				},
+			},
+		},
+	}
+
+	res, err := json.Marshal(task.Children[TaskID(2)].withProgress())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"id":"2","label":"bar","completed":true,"progress":{"done":1,"total":2}}`
+	if expected != string(res) {
+		t.Fatalf("expected \n%s\n got \n%s\n", expected, res)
+	}
+
+	if progress := task.progress(); *progress != (TaskProgress{Done: 2, Total: 3}) {
+		t.Fatalf("expected progress %+v got %+v", TaskProgress{Done: 2, Total: 3}, *progress)
+	}
+}
@@ -0,0 +1,420 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	// ErrTaskBlockerNotFound
+	ErrTaskBlockerNotFound error = errors.New("Task field BlockedBy contains unknown Task")
+	// ErrTaskDependencyCycle
+	ErrTaskDependencyCycle error = errors.New("Task dependencies can't contain a cycle")
+	// ErrTaskBlockedByOpenTask
+	ErrTaskBlockedByOpenTask error = errors.New("Task can't be completed while it's blocked by open Task")
+)
+
+// TaskDependencies is dependency graph of the Task. Upstream contains Tasks
+// which block the Task directly or transitively and downstream contains Tasks
+// blocked by the Task directly or transitively. Edges of the graph are given
+// by BlockedBy of returned Tasks.
+type TaskDependencies struct {
+	Task       TaskWithPath   `json:"task"`
+	Upstream   []TaskWithPath `json:"upstream"`
+	Downstream []TaskWithPath `json:"downstream"`
+}
+
+// dependencyGraph holds blocked by links of all Tasks in the tree.
+type dependencyGraph struct {
+	// tasks are Tasks (without children) with their TaskID paths.
+	tasks map[TaskID]TaskWithPath
+	// blockedBy maps TaskID to TaskIDs of its blockers.
+	blockedBy map[TaskID][]TaskID
+	// finished contains TaskIDs of done and cancelled Tasks.
+	finished map[TaskID]bool
+}
+
+// newDependencyGraph returns dependency graph of given Tasks and all their
+// children.
+func newDependencyGraph(tasks []Task) *dependencyGraph {
+	g := &dependencyGraph{
+		tasks:     map[TaskID]TaskWithPath{},
+		blockedBy: map[TaskID][]TaskID{},
+		finished:  map[TaskID]bool{},
+	}
+
+	var walk func(parent []TaskID, tasks []Task)
+	walk = func(parent []TaskID, tasks []Task) {
+		for i := range tasks {
+			task := &tasks[i]
+			path := childPath(parent, task.ID)
+			g.tasks[task.ID] = TaskWithPath{Path: path, Task: *task.withProgress()}
+			g.blockedBy[task.ID] = task.BlockedBy
+			g.finished[task.ID] = task.finished()
+
+			walk(path, task.Children.list())
+		}
+	}
+	walk([]TaskID{}, tasks)
+
+	return g
+}
+
+// contains returns true if Task with given TaskID is in the graph.
+func (g *dependencyGraph) contains(taskID TaskID) bool {
+	_, found := g.tasks[taskID]
+	return found
+}
+
+// open returns true if any of given blockers is not finished. Unknown
+// blockers are not open.
+func (g *dependencyGraph) open(blockers []TaskID) bool {
+	for _, blocker := range blockers {
+		if g.contains(blocker) && !g.finished[blocker] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasCycle returns true if blocked by links form a cycle. Task blocked by
+// itself is a cycle too.
+func (g *dependencyGraph) hasCycle() bool {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[TaskID]int{}
+
+	var visit func(taskID TaskID) bool
+	visit = func(taskID TaskID) bool {
+		switch state[taskID] {
+		case visiting:
+			return true
+		case visited:
+			return false
+		}
+
+		state[taskID] = visiting
+		for _, blocker := range g.blockedBy[taskID] {
+			if visit(blocker) {
+				return true
+			}
+		}
+		state[taskID] = visited
+
+		return false
+	}
+
+	for taskID := range g.blockedBy {
+		if visit(taskID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dependencies returns dependency graph of Task with given TaskID.
+func (g *dependencyGraph) dependencies(taskID TaskID) TaskDependencies {
+	blocks := map[TaskID][]TaskID{}
+	for blocked, blockers := range g.blockedBy {
+		for _, blocker := range blockers {
+			blocks[blocker] = append(blocks[blocker], blocked)
+		}
+	}
+
+	return TaskDependencies{
+		Task:       g.tasks[taskID],
+		Upstream:   g.reachable(taskID, g.blockedBy),
+		Downstream: g.reachable(taskID, blocks),
+	}
+}
+
+// reachable returns Tasks reachable from Task with given TaskID by given
+// edges (without the Task itself) ordered by TaskID.
+func (g *dependencyGraph) reachable(taskID TaskID, edges map[TaskID][]TaskID) []TaskWithPath {
+	seen := map[TaskID]bool{taskID: true}
+	queue := []TaskID{taskID}
+	found := []TaskID{}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, next := range edges[current] {
+			if seen[next] || !g.contains(next) {
+				continue
+			}
+			seen[next] = true
+			queue = append(queue, next)
+			found = append(found, next)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i] < found[j] })
+
+	result := make([]TaskWithPath, len(found))
+	for i, id := range found {
+		result[i] = g.tasks[id]
+	}
+
+	return result
+}
+
+// validateBlockers validates blocked by links of created or updated Task.
+// Blockers must be in the tree, links must not form a cycle and Task which is
+// being completed must not be blocked by open Task.
+func (s *TaskStorageService) validateBlockers(task *Task, completing bool) error {
+	if len(task.BlockedBy) == 0 {
+		return nil
+	}
+
+	tasks, err := s.storage.FindAll()
+	if err != nil {
+		return err
+	}
+
+	g := newDependencyGraph(tasks)
+	for _, blocker := range task.BlockedBy {
+		if !g.contains(blocker) {
+			fmt.Printf("(DEBUG) dependency: Blocker %d of Task %d not found.\n", blocker, task.ID)
+			return ErrTaskBlockerNotFound
+		}
+	}
+
+	g.blockedBy[task.ID] = task.BlockedBy
+	if g.hasCycle() {
+		fmt.Printf("(DEBUG) dependency: Blockers of Task %d form a cycle.\n", task.ID)
+		return ErrTaskDependencyCycle
+	}
+
+	if completing && g.open(task.BlockedBy) {
+		fmt.Printf("(DEBUG) dependency: Task %d is blocked by open Task.\n", task.ID)
+		return ErrTaskBlockedByOpenTask
+	}
+
+	return nil
+}
+
+// validateTreeBlockers validates blocked by links of new Task and all its sub
+// tasks. New Tasks can't block each other (their TaskIDs were not known) so
+// they can't form a cycle.
+func (s *TaskStorageService) validateTreeBlockers(task *Task) error {
+	if !task.hasBlockers() {
+		return nil
+	}
+
+	tasks, err := s.storage.FindAll()
+	if err != nil {
+		return err
+	}
+	g := newDependencyGraph(tasks)
+
+	var walk func(task *Task) error
+	walk = func(task *Task) error {
+		for _, blocker := range task.BlockedBy {
+			if !g.contains(blocker) {
+				fmt.Printf("(DEBUG) dependency: Blocker %d of Task %d not found.\n", blocker, task.ID)
+				return ErrTaskBlockerNotFound
+			}
+		}
+
+		if task.Completed && g.open(task.BlockedBy) {
+			fmt.Printf("(DEBUG) dependency: Task %d is blocked by open Task.\n", task.ID)
+			return ErrTaskBlockedByOpenTask
+		}
+
+		for _, child := range task.Children {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return walk(task)
+}
+
+// hasBlockers returns true if the Task or any of its sub tasks is blocked by
+// other Task.
+func (t *Task) hasBlockers() bool {
+	if len(t.BlockedBy) > 0 {
+		return true
+	}
+
+	for _, child := range t.Children {
+		if child.hasBlockers() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateDocumentBlockers validates blocked by links of all Tasks in
+// validated patched document. Links and statuses of Tasks in the document
+// replace stored ones so the document may complete blocker together with
+// blocked Task.
+func (s *TaskStorageService) validateDocumentBlockers(doc *JSONTaskDocument) error {
+	tasks, err := s.storage.FindAll()
+	if err != nil {
+		return err
+	}
+
+	g := newDependencyGraph(tasks)
+	// completing contains blockers of Tasks which are completed by the
+	// document.
+	completing := [][]TaskID{}
+
+	var walk func(doc *JSONTaskDocument) error
+	walk = func(doc *JSONTaskDocument) error {
+		var blockers []TaskID
+		if doc.BlockedBy != nil {
+			blockers = normalizeTaskIDs(*doc.BlockedBy)
+		}
+
+		for _, blocker := range blockers {
+			if !g.contains(blocker) {
+				fmt.Printf("(DEBUG) dependency: Blocker %d in patched document not found.\n", blocker)
+				return ErrTaskBlockerNotFound
+			}
+		}
+
+		current := StatusTodo
+		if doc.ID != nil {
+			task := g.tasks[*doc.ID].Task
+			current = task.status()
+			g.blockedBy[*doc.ID] = blockers
+		}
+
+		target := targetStatus(current, doc.Status, doc.Completed)
+		if doc.ID != nil {
+			g.finished[*doc.ID] = target == StatusDone || target == StatusCancelled
+		}
+		if target == StatusDone && current != StatusDone {
+			completing = append(completing, blockers)
+		}
+
+		for _, childDoc := range doc.Children {
+			if err := walk(childDoc); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+	if err := walk(doc); err != nil {
+		return err
+	}
+
+	if g.hasCycle() {
+		fmt.Println("(DEBUG) dependency: Blockers in patched document form a cycle.")
+		return ErrTaskDependencyCycle
+	}
+
+	for _, blockers := range completing {
+		if g.open(blockers) {
+			fmt.Println("(DEBUG) dependency: Task in patched document is blocked by open Task.")
+			return ErrTaskBlockedByOpenTask
+		}
+	}
+
+	return nil
+}
+
+// removeBlockers removes links to deleted Tasks with given TaskIDs from all
+// Tasks in the tree. Every changed Task gets new revision.
+func (s *TaskStorageService) removeBlockers(deleted map[TaskID]bool) error {
+	if len(deleted) == 0 {
+		return nil
+	}
+
+	tasks, err := s.storage.FindAll()
+	if err != nil {
+		return err
+	}
+
+	g := newDependencyGraph(tasks)
+	for taskID, blockers := range g.blockedBy {
+		kept := []TaskID{}
+		for _, blocker := range blockers {
+			if !deleted[blocker] {
+				kept = append(kept, blocker)
+			}
+		}
+		if len(kept) == len(blockers) {
+			continue
+		}
+
+		path := g.tasks[taskID].Path
+		task, err := s.storage.Find(path)
+		if err != nil {
+			return err
+		}
+
+		updatedTask := task.withChildren(nil)
+		updatedTask.BlockedBy = normalizeTaskIDs(kept)
+		updatedTask.Revision++
+		s.touch(updatedTask, updatedTask.Completed)
+		if err := s.storage.Update(path, updatedTask); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// finished returns true if the Task is done or cancelled.
+func (t *Task) finished() bool {
+	return t.Completed || t.status() == StatusCancelled
+}
+
+// collectTaskIDs adds TaskIDs of the Task and all its descendants into ids.
+func (t *Task) collectTaskIDs(ids map[TaskID]bool) {
+	ids[t.ID] = true
+	for _, child := range t.Children {
+		child.collectTaskIDs(ids)
+	}
+}
+
+// normalizeTaskIDs returns sorted copy of given TaskIDs without duplicates or
+// nil if there are no TaskIDs.
+func normalizeTaskIDs(ids []TaskID) TaskIDPath {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	set := map[TaskID]bool{}
+	normalized := TaskIDPath{}
+	for _, id := range ids {
+		if !set[id] {
+			set[id] = true
+			normalized = append(normalized, id)
+		}
+	}
+	sort.Slice(normalized, func(i, j int) bool { return normalized[i] < normalized[j] })
+
+	return normalized
+}
+
+// remapBlockers changes blocked by links of copied Task and its descendants
+// which point to copied Tasks to the copies. Mapping of original TaskIDs to
+// new ones is given in ids.
+func remapBlockers(task *Task, ids map[TaskID]TaskID) {
+	if len(task.BlockedBy) > 0 {
+		blockers := make([]TaskID, len(task.BlockedBy))
+		for i, blocker := range task.BlockedBy {
+			if newID, found := ids[blocker]; found {
+				blocker = newID
+			}
+			blockers[i] = blocker
+		}
+		task.BlockedBy = normalizeTaskIDs(blockers)
+	}
+
+	for _, child := range task.Children {
+		remapBlockers(child, ids)
+	}
+}
@@ -0,0 +1,103 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	tasks := []Task{
+		Task{
+			ID:    TaskID(1),
+			Label: "foo",
+			Children: SubTasks{
+				TaskID(2): &Task{
+					ID:        TaskID(2),
+					Label:     "bar",
+					BlockedBy: TaskIDPath{TaskID(3)},
+				},
+			},
+		},
+		Task{
+			ID:        TaskID(3),
+			Label:     "baz",
+			Completed: true,
+			BlockedBy: TaskIDPath{TaskID(4)},
+		},
+		Task{
+			ID:     TaskID(4),
+			Label:  "qux",
+			Status: StatusCancelled,
+		},
+		Task{
+			ID:        TaskID(5),
+			Label:     "quux",
+			BlockedBy: TaskIDPath{TaskID(2)},
+		},
+	}
+
+	g := newDependencyGraph(tasks)
+
+	if g.hasCycle() {
+		t.Fatal("expected graph without cycle")
+	}
+
+	if g.open([]TaskID{TaskID(3), TaskID(4), TaskID(42)}) {
+		t.Fatal("expected finished blockers")
+	}
+
+	if !g.open([]TaskID{TaskID(2)}) {
+		t.Fatal("expected open blocker")
+	}
+
+	deps := g.dependencies(TaskID(2))
+	if !reflect.DeepEqual(TaskIDPath{TaskID(1), TaskID(2)}, deps.Task.Path) {
+		t.Fatalf("expected path %v got %v", TaskIDPath{TaskID(1), TaskID(2)}, deps.Task.Path)
+	}
+
+	upstream := []TaskID{}
+	for _, task := range deps.Upstream {
+		upstream = append(upstream, task.Task.ID)
+	}
+	if !reflect.DeepEqual([]TaskID{TaskID(3), TaskID(4)}, upstream) {
+		t.Fatalf("expected upstream %v got %v", []TaskID{TaskID(3), TaskID(4)}, upstream)
+	}
+
+	if len(deps.Downstream) != 1 || deps.Downstream[0].Task.ID != TaskID(5) {
+		t.Fatalf("expected downstream Task 5 got %v", deps.Downstream)
+	}
+
+	g.blockedBy[TaskID(4)] = []TaskID{TaskID(5)}
+	if !g.hasCycle() {
+		t.Fatal("expected graph with cycle")
+	}
+
+	g.blockedBy[TaskID(4)] = []TaskID{TaskID(4)}
+	if !g.hasCycle() {
+		t.Fatal("expected Task blocked by itself to be a cycle")
+	}
+}
+
+func TestRemapBlockers(t *testing.T) {
+	task := &Task{
+		ID:        TaskID(10),
+		BlockedBy: TaskIDPath{TaskID(3)},
+		Children: SubTasks{
+			TaskID(11): &Task{
+				ID:        TaskID(11),
+				BlockedBy: TaskIDPath{TaskID(1), TaskID(3)},
+			},
+		},
+	}
+
+	remapBlockers(task, map[TaskID]TaskID{TaskID(1): TaskID(10), TaskID(2): TaskID(11)})
+
+	if !reflect.DeepEqual(TaskIDPath{TaskID(3)}, task.BlockedBy) {
+		t.Fatalf("expected blockers %v got %v", TaskIDPath{TaskID(3)}, task.BlockedBy)
+	}
+
+	expected := TaskIDPath{TaskID(3), TaskID(10)}
+	if !reflect.DeepEqual(expected, task.Children[TaskID(11)].BlockedBy) {
+		t.Fatalf("expected blockers %v got %v", expected, task.Children[TaskID(11)].BlockedBy)
+	}
+}
@@ -0,0 +1,94 @@
+package tasks
+
+import (
+	"sort"
+	"time"
+)
+
+// TaskFilter holds conditions Tasks are filtered by. All set conditions must
+// match. Zero value of TaskFilter matches every Task.
+type TaskFilter struct {
+	// Overdue matches Tasks which are not completed nor cancelled and their
+	// DueAt is before current time.
+	Overdue bool
+	// DueBefore matches Tasks with DueAt before given time.
+	DueBefore *time.Time
+	// Tags matches Tasks which have all given tags.
+	Tags []string
+}
+
+// IsEmpty returns true if no filter condition is set.
+func (f TaskFilter) IsEmpty() bool {
+	return !f.Overdue && f.DueBefore == nil && len(f.Tags) == 0
+}
+
+// match returns true if Task matches all conditions of the filter. Now is
+// current time used for time relative conditions.
+func (f TaskFilter) match(task *Task, now time.Time) bool {
+	if f.Overdue {
+		if task.finished() || task.DueAt == nil || !task.DueAt.Before(now) {
+			return false
+		}
+	}
+
+	if f.DueBefore != nil {
+		if task.DueAt == nil || !task.DueAt.Before(*f.DueBefore) {
+			return false
+		}
+	}
+
+	if !task.hasTags(f.Tags) {
+		return false
+	}
+
+	return true
+}
+
+// filterTasks walks Tasks with their children depth first (ordered
+// ByPosition) and returns every Task matching the filter with its TaskID
+// path. Returned Tasks don't contain children but keep their progress.
+func filterTasks(tasks []Task, filter TaskFilter, now time.Time) []TaskWithPath {
+	result := []TaskWithPath{}
+
+	var walk func(parent []TaskID, tasks []Task)
+	walk = func(parent []TaskID, tasks []Task) {
+		sort.Sort(ByPosition(tasks))
+		for i := range tasks {
+			task := &tasks[i]
+			path := childPath(parent, task.ID)
+			if filter.match(task, now) {
+				result = append(result, TaskWithPath{Path: path, Task: *task.withProgress()})
+			}
+
+			walk(path, task.Children.list())
+		}
+	}
+	walk([]TaskID{}, tasks)
+
+	return result
+}
+
+// countTags walks Tasks with their children and returns every used tag with
+// number of Tasks which have the tag. Tags are sorted alphabetically.
+func countTags(tasks []Task) []TagCount {
+	counts := map[string]int{}
+
+	var walk func(tasks []Task)
+	walk = func(tasks []Task) {
+		for _, task := range tasks {
+			for _, tag := range task.Tags {
+				counts[tag]++
+			}
+			walk(task.Children.list())
+		}
+	}
+	walk(tasks)
+
+	result := []TagCount{}
+	for tag, count := range counts {
+		result = append(result, TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Tag < result[j].Tag })
+
+	return result
+}
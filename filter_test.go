@@ -0,0 +1,113 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFilterTasks(t *testing.T) {
+	now := time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC)
+	yesterday := now.Add(-24 * time.Hour)
+	tomorrow := now.Add(24 * time.Hour)
+
+	tasks := []Task{
+		Task{
+			ID:    TaskID(2),
+			Label: "bar",
+			DueAt: &tomorrow,
+			Tags:  []string{"backend", "urgent"},
+		},
+		Task{
+			ID:    TaskID(1),
+			Label: "foo",
+			Children: SubTasks{
+				TaskID(4): &Task{
+					ID:    TaskID(4),
+					Label: "qux",
+					DueAt: &yesterday,
+					Tags:  []string{"backend"},
+				},
+				TaskID(3): &Task{
+					ID:        TaskID(3),
+					Label:     "baz",
+					Completed: true,
+					DueAt:     &yesterday,
+				},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		filter TaskFilter
+		res    []TaskIDPath
+	}{
+		"empty": {
+			filter: TaskFilter{},
+			res: []TaskIDPath{
+				TaskIDPath{TaskID(1)},
+				TaskIDPath{TaskID(1), TaskID(3)},
+				TaskIDPath{TaskID(1), TaskID(4)},
+				TaskIDPath{TaskID(2)},
+			},
+		},
+		"overdue": {
+			filter: TaskFilter{Overdue: true},
+			res: []TaskIDPath{
+				TaskIDPath{TaskID(1), TaskID(4)},
+			},
+		},
+		"due before": {
+			filter: TaskFilter{DueBefore: &now},
+			res: []TaskIDPath{
+				TaskIDPath{TaskID(1), TaskID(3)},
+				TaskIDPath{TaskID(1), TaskID(4)},
+			},
+		},
+		"tag": {
+			filter: TaskFilter{Tags: []string{"backend"}},
+			res: []TaskIDPath{
+				TaskIDPath{TaskID(1), TaskID(4)},
+				TaskIDPath{TaskID(2)},
+			},
+		},
+		"tags": {
+			filter: TaskFilter{Tags: []string{"urgent", "backend"}},
+			res: []TaskIDPath{
+				TaskIDPath{TaskID(2)},
+			},
+		},
+		"tags and overdue": {
+			filter: TaskFilter{Overdue: true, Tags: []string{"urgent"}},
+			res:    []TaskIDPath{},
+		},
+		"due before yesterday": {
+			filter: TaskFilter{DueBefore: &yesterday},
+			res:    []TaskIDPath{},
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		res := []TaskIDPath{}
+		for _, taskWithPath := range filterTasks(tasks, tc.filter, now) {
+			if taskWithPath.Task.Children != nil {
+				t.Fatalf("expected task %d without children", taskWithPath.Task.ID)
+			}
+			res = append(res, taskWithPath.Path)
+		}
+
+		if !reflect.DeepEqual(tc.res, res) {
+			t.Fatalf("expected paths %v got %v", tc.res, res)
+		}
+	}
+
+	expected := []TagCount{
+		TagCount{Tag: "backend", Count: 2},
+		TagCount{Tag: "urgent", Count: 1},
+	}
+	if res := countTags(tasks); !reflect.DeepEqual(expected, res) {
+		t.Fatalf("expected tags %v got %v", expected, res)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"sort"
+	"strconv"
 )
 
 var (
@@ -33,6 +34,14 @@ func NewTaskHandler(service TaskService) *TaskHandler {
 // handlers.
 // ServeHTTP implements http.Handler interface
 func (h *TaskHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Changes are recorded in history with actor of the request.
+	h = &TaskHandler{service: h.service.WithActor(parseActor(r))}
+
+	if action := parseTaskAction(r); action != "" {
+		h.serveAction(w, r, action)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		h.get(w, r)
@@ -40,6 +49,8 @@ func (h *TaskHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.post(w, r)
 	case http.MethodPut:
 		h.put(w, r)
+	case http.MethodPatch:
+		h.patch(w, r)
 	case http.MethodDelete:
 		h.remove(w, r)
 	case http.MethodOptions:
@@ -49,6 +60,63 @@ func (h *TaskHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// ServeAction dispatches requests for actions on Task (eg. "/tasks/1/move")
+// to proper function handlers.
+func (h *TaskHandler) serveAction(w http.ResponseWriter, r *http.Request, action string) {
+	if r.Method == http.MethodOptions {
+		options(w, r)
+		return
+	}
+
+	switch action {
+	case "move":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w)
+			return
+		}
+		h.move(w, r)
+	case "clone":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w)
+			return
+		}
+		h.clone(w, r)
+	case "notes.html":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		h.notes(w, r)
+	case "reorder":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w)
+			return
+		}
+		h.reorder(w, r)
+	case "dependencies":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		h.dependencies(w, r)
+	case "history":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		h.history(w, r)
+	case "revert":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w)
+			return
+		}
+		h.revert(w, r)
+	default:
+		log.Printf("(DEBUG) handler: unknown task action %q\n", action)
+		ErrorAsJSON(w, http.StatusBadRequest, ErrHandlerURLNotValid)
+	}
+}
+
 // Get is handler for GET requests for non top level Tasks.
 func (h *TaskHandler) get(w http.ResponseWriter, r *http.Request) {
 	taskIDPath, err := parseTaskIDPath(r)
@@ -58,6 +126,20 @@ func (h *TaskHandler) get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	depth, err := parseTaskDepth(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: getting child task failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	fields, err := parseTaskFields(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: getting child task failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
 	task, err := h.service.Find(taskIDPath)
 	if err != nil {
 		switch err {
@@ -72,6 +154,144 @@ func (h *TaskHandler) get(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	etag := task.ETag()
+	if matchETagWeak(r.Header.Get("If-None-Match"), etag) {
+		ResponseNotModified(w, etag)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	ResponseOK(w, task.withDepth(depth).withFields(fields))
+}
+
+// Notes is handler for GET requests which return notes of the Task rendered
+// from Markdown as HTML.
+func (h *TaskHandler) notes(w http.ResponseWriter, r *http.Request) {
+	taskIDPath, err := parseTaskIDPath(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: getting task notes failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, ErrHandlerURLNotValid)
+		return
+	}
+
+	task, err := h.service.Find(taskIDPath)
+	if err != nil {
+		switch err {
+		case ErrTaskNotFound:
+			log.Printf("(INFO) handler: getting task notes failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusNotFound, err)
+			return
+		default:
+			log.Printf("(WARN) handler: getting task notes failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	etag := task.ETag()
+	if matchETagWeak(r.Header.Get("If-None-Match"), etag) {
+		ResponseNotModified(w, etag)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	ResponseHTML(w, renderMarkdown(task.Notes))
+}
+
+// Dependencies is handler for GET requests which return dependency graph of
+// the Task.
+func (h *TaskHandler) dependencies(w http.ResponseWriter, r *http.Request) {
+	taskIDPath, err := parseTaskIDPath(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: getting task dependencies failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, ErrHandlerURLNotValid)
+		return
+	}
+
+	dependencies, err := h.service.FindDependencies(taskIDPath)
+	if err != nil {
+		switch err {
+		case ErrTaskNotFound:
+			log.Printf("(INFO) handler: getting task dependencies failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusNotFound, err)
+			return
+		default:
+			log.Printf("(WARN) handler: getting task dependencies failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	ResponseOK(w, dependencies)
+}
+
+// History is handler for GET requests which return all recorded changes of
+// the Task.
+func (h *TaskHandler) history(w http.ResponseWriter, r *http.Request) {
+	taskIDPath, err := parseTaskIDPath(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: getting task history failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, ErrHandlerURLNotValid)
+		return
+	}
+
+	history, err := h.service.FindHistory(taskIDPath)
+	if err != nil {
+		switch err {
+		case ErrTaskNotFound:
+			log.Printf("(INFO) handler: getting task history failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusNotFound, err)
+			return
+		default:
+			log.Printf("(WARN) handler: getting task history failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	response := map[string]interface{}{
+		"history": history,
+	}
+
+	ResponseOK(w, response)
+}
+
+// Revert is handler for POST requests which revert fields of the Task to
+// version given in query (eg. "/tasks/1/revert?version=2").
+func (h *TaskHandler) revert(w http.ResponseWriter, r *http.Request) {
+	taskIDPath, err := parseTaskIDPath(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: reverting task failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, ErrHandlerURLNotValid)
+		return
+	}
+
+	version, err := strconv.Atoi(r.URL.Query().Get("version"))
+	if err != nil || version < 1 {
+		log.Printf("(DEBUG) handler: reverting task failed: version %q is not valid\n", r.URL.Query().Get("version"))
+		ErrorAsJSON(w, http.StatusBadRequest, ErrHandlerQueryNotValid)
+		return
+	}
+
+	task, err := h.service.Revert(taskIDPath, version)
+	if err != nil {
+		switch err {
+		case ErrTaskNotFound, ErrHistoryVersionNotFound:
+			log.Printf("(INFO) handler: reverting task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusNotFound, err)
+			return
+		case ErrTaskDependencyCycle, ErrTaskBlockedByOpenTask, ErrTaskStatusTransitionNotValid:
+			log.Printf("(INFO) handler: reverting task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusConflict, err)
+			return
+		default:
+			log.Printf("(WARN) handler: reverting task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	w.Header().Set("ETag", task.ETag())
 	ResponseOK(w, task)
 }
 
@@ -97,10 +317,8 @@ func (h *TaskHandler) put(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updateFields := UpdateFields{
-		Label:     jsonTask.Label,
-		Completed: jsonTask.Completed,
-	}
+	updateFields := jsonTask.updateFields()
+	updateFields.IfMatch = parseETags(r.Header.Get("If-Match"))
 
 	updatedTask, err := h.service.Update(taskIDPath, updateFields)
 	if err != nil {
@@ -109,6 +327,18 @@ func (h *TaskHandler) put(w http.ResponseWriter, r *http.Request) {
 			log.Printf("(INFO) handler: updating child task failed: %s\n", err)
 			ErrorAsJSON(w, http.StatusNotFound, err)
 			return
+		case ErrTaskPreconditionFailed:
+			log.Printf("(INFO) handler: updating child task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusPreconditionFailed, err)
+			return
+		case ErrTaskStartAtAfterDueAt, ErrTaskBlockerNotFound:
+			log.Printf("(DEBUG) handler: updating child task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusBadRequest, err)
+			return
+		case ErrTaskStatusTransitionNotValid, ErrTaskDependencyCycle, ErrTaskBlockedByOpenTask:
+			log.Printf("(INFO) handler: updating child task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusConflict, err)
+			return
 		default:
 			log.Printf("(WARN) handler: updating child task failed: %s\n", err)
 			ErrorAsJSON(w, http.StatusInternalServerError, err)
@@ -116,9 +346,63 @@ func (h *TaskHandler) put(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	w.Header().Set("ETag", updatedTask.ETag())
 	ResponseOK(w, updatedTask)
 }
 
+// Patch is handler for PATCH requests for non top level Tasks. Patch is
+// applied on Task JSON with its sub tasks.
+func (h *TaskHandler) patch(w http.ResponseWriter, r *http.Request) {
+	taskIDPath, err := parseTaskIDPath(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: patching child task failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, ErrHandlerURLNotValid)
+		return
+	}
+
+	var jsonTaskPatch JSONTaskPatch
+	if err := parseBody(r, &jsonTaskPatch); err != nil {
+		log.Printf("(DEBUG) handler: patching child task failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	patchFields := PatchFields{
+		Type:    jsonTaskPatch.Type,
+		Patch:   jsonTaskPatch.Patch,
+		IfMatch: parseETags(r.Header.Get("If-Match")),
+	}
+
+	patchedTask, err := h.service.Patch(taskIDPath, patchFields)
+	if err != nil {
+		switch err {
+		case ErrTaskNotFound:
+			log.Printf("(INFO) handler: patching child task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusNotFound, err)
+			return
+		case ErrTaskPreconditionFailed:
+			log.Printf("(INFO) handler: patching child task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusPreconditionFailed, err)
+			return
+		case ErrTaskPatchTestFailed, ErrTaskStatusTransitionNotValid, ErrTaskDependencyCycle, ErrTaskBlockedByOpenTask:
+			log.Printf("(INFO) handler: patching child task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusConflict, err)
+			return
+		case ErrTaskPatchNotValid, ErrTaskFieldIsReadOnly, ErrTaskLabelIsRequired, ErrTaskLabelIsNotValid, ErrTaskStartAtAfterDueAt, ErrTaskPriorityIsNotValid, ErrTaskTagsAreNotValid, ErrTaskNotesAreNotValid, ErrTaskStatusIsNotValid, ErrTaskBlockerNotFound, ErrTaskRecurrenceIsNotValid:
+			log.Printf("(DEBUG) handler: patching child task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusBadRequest, err)
+			return
+		default:
+			log.Printf("(WARN) handler: patching child task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	w.Header().Set("ETag", patchedTask.ETag())
+	ResponseOK(w, patchedTask)
+}
+
 // Post is handler for POST requests for non top level Tasks
 func (h *TaskHandler) post(w http.ResponseWriter, r *http.Request) {
 	taskIDPath, err := parseTaskIDPath(r)
@@ -141,9 +425,7 @@ func (h *TaskHandler) post(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	createFields := CreateFields{
-		Label: *jsonTask.Label,
-	}
+	createFields := jsonTask.createFields()
 
 	newTask, err := h.service.Create(taskIDPath, createFields)
 	if err != nil {
@@ -152,6 +434,14 @@ func (h *TaskHandler) post(w http.ResponseWriter, r *http.Request) {
 			log.Printf("(INFO) handler: creating child task failed: %s\n", err)
 			ErrorAsJSON(w, http.StatusNotFound, err)
 			return
+		case ErrTaskBlockerNotFound:
+			log.Printf("(DEBUG) handler: creating child task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusBadRequest, err)
+			return
+		case ErrTaskBlockedByOpenTask:
+			log.Printf("(INFO) handler: creating child task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusConflict, err)
+			return
 		default:
 			log.Printf("(WARN) handler: creating child task failed: %s\n", err)
 			ErrorAsJSON(w, http.StatusInternalServerError, err)
@@ -160,6 +450,7 @@ func (h *TaskHandler) post(w http.ResponseWriter, r *http.Request) {
 	}
 
 	url := fmt.Sprintf("%s/%d", r.URL.Path, newTask.ID)
+	w.Header().Set("ETag", newTask.ETag())
 	ResponseCreated(w, url, newTask)
 }
 
@@ -172,13 +463,21 @@ func (h *TaskHandler) remove(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task, err := h.service.Delete(taskIDPath)
+	deleteFields := DeleteFields{
+		IfMatch: parseETags(r.Header.Get("If-Match")),
+	}
+
+	task, err := h.service.Delete(taskIDPath, deleteFields)
 	if err != nil {
 		switch err {
 		case ErrTaskNotFound:
 			log.Printf("(INFO) handler: deleting child task failed: %s\n", err)
 			ErrorAsJSON(w, http.StatusNotFound, err)
 			return
+		case ErrTaskPreconditionFailed:
+			log.Printf("(INFO) handler: deleting child task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusPreconditionFailed, err)
+			return
 		default:
 			log.Printf("(INFO) handler: deleting child task failed: %s\n", err)
 			ErrorAsJSON(w, http.StatusInternalServerError, err)
@@ -189,90 +488,931 @@ func (h *TaskHandler) remove(w http.ResponseWriter, r *http.Request) {
 	ResponseOK(w, task)
 }
 
-// TasksHandler is simple Handler which handles top level Tasks in tree
-// hierarchy. Handler provides only CR operations.
-// TasksHandler implements http.Handler interface.
-type TasksHandler struct {
-	service TaskService
-}
+// Move is handler for POST requests which move Task under another parent.
+func (h *TaskHandler) move(w http.ResponseWriter, r *http.Request) {
+	taskIDPath, err := parseTaskIDPath(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: moving task failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, ErrHandlerURLNotValid)
+		return
+	}
 
-// NewTasksHandler returns new instance of TasksHandler
-func NewTasksHandler(service TaskService) *TasksHandler {
-	return &TasksHandler{
-		service: service,
+	var jsonMove JSONMove
+	if err := parseBody(r, &jsonMove); err != nil {
+		log.Printf("(DEBUG) handler: moving task failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
 	}
-}
 
-// ServeHTTP is simple function which dispatches requests to proper function
-// handlers.
-// ServeHTTP implements http.Handler interface
-func (h *TasksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		h.get(w, r)
-	case http.MethodPost:
-		h.post(w, r)
-	case http.MethodOptions:
-		options(w, r)
-	default:
-		methodNotAllowed(w)
+	if err := jsonMove.Validate(); err != nil {
+		log.Printf("(DEBUG) handler: moving task failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
 	}
-}
 
-// Get is handler for GET requests for top level Tasks.
-func (h *TasksHandler) get(w http.ResponseWriter, r *http.Request) {
-	tasks, err := h.service.FindAll()
+	target := []TaskID(*jsonMove.Target)
+	task, err := h.service.Move(taskIDPath, target)
 	if err != nil {
 		switch err {
 		case ErrTaskNotFound:
-			log.Printf("(INFO) handler: getting task failed: %s\n", err)
+			log.Printf("(INFO) handler: moving task failed: %s\n", err)
 			ErrorAsJSON(w, http.StatusNotFound, err)
 			return
+		case ErrTaskMoveNotValid:
+			log.Printf("(INFO) handler: moving task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusConflict, err)
+			return
 		default:
-			log.Printf("(WARN) handler: getting task failed: %s\n", err)
+			log.Printf("(WARN) handler: moving task failed: %s\n", err)
 			ErrorAsJSON(w, http.StatusInternalServerError, err)
 			return
 		}
 	}
 
-	sort.Sort(ByTaskID(tasks))
+	ResponseOK(w, TaskWithPath{Path: append(target, task.ID), Task: task})
+}
 
-	// Do not return array in response - it would break future extensions
-	// Better to return object which wraps tasks.
-	response := map[string]interface{}{
-		"tasks": tasks,
+// Clone is handler for POST requests which copy Task with its sub tasks under
+// another parent.
+func (h *TaskHandler) clone(w http.ResponseWriter, r *http.Request) {
+	taskIDPath, err := parseTaskIDPath(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: cloning task failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, ErrHandlerURLNotValid)
+		return
 	}
 
-	ResponseOK(w, response)
-}
-
-// Post is handler for POST requests for top level Tasks.
-func (h *TasksHandler) post(w http.ResponseWriter, r *http.Request) {
-	var jsonTask JSONTask
-	if err := parseBody(r, &jsonTask); err != nil {
-		log.Printf("(DEBUG) handler: creating task failed: %s\n", err)
+	var jsonClone JSONClone
+	if err := parseBody(r, &jsonClone); err != nil {
+		log.Printf("(DEBUG) handler: cloning task failed: %s\n", err)
 		ErrorAsJSON(w, http.StatusBadRequest, err)
 		return
 	}
 
-	if err := jsonTask.Validate(NewCreateValidator()); err != nil {
-		log.Printf("(DEBUG) handler: creating task failed: %s\n", err)
+	if err := jsonClone.Validate(); err != nil {
+		log.Printf("(DEBUG) handler: cloning task failed: %s\n", err)
 		ErrorAsJSON(w, http.StatusBadRequest, err)
 		return
 	}
 
-	createFields := CreateFields{
-		Label: *jsonTask.Label,
+	cloneFields := CloneFields{
+		ResetCompleted: jsonClone.ResetCompleted,
 	}
 
-	// Creating op level Task - TaskID path will always be empty.
-	newTask, err := h.service.Create([]TaskID{}, createFields)
+	target := []TaskID(*jsonClone.Target)
+	newTask, ids, err := h.service.Clone(taskIDPath, target, cloneFields)
 	if err != nil {
-		log.Printf("(WARN) handler: creating task failed: %s\n", err)
-		ErrorAsJSON(w, http.StatusInternalServerError, err)
+		switch err {
+		case ErrTaskNotFound:
+			log.Printf("(INFO) handler: cloning task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusNotFound, err)
+			return
+		default:
+			log.Printf("(WARN) handler: cloning task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	// TaskIDs are serialized as strings everywhere else.
+	jsonIDs := map[string]string{}
+	for oldID, newID := range ids {
+		jsonIDs[strconv.Itoa(int(oldID))] = strconv.Itoa(int(newID))
+	}
+
+	newPath := append(target, newTask.ID)
+	response := map[string]interface{}{
+		"path": TaskIDPath(newPath),
+		"task": newTask,
+		"ids":  jsonIDs,
+	}
+
+	ResponseCreated(w, taskURL(newPath), response)
+}
+
+// Reorder is handler for POST requests which change order of sub tasks of
+// the Task.
+func (h *TaskHandler) reorder(w http.ResponseWriter, r *http.Request) {
+	taskIDPath, err := parseTaskIDPath(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: reordering task failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, ErrHandlerURLNotValid)
 		return
 	}
 
-	url := fmt.Sprintf("%s/%d", r.URL.Path, newTask.ID)
-	ResponseCreated(w, url, newTask)
+	var jsonReorder JSONReorder
+	if err := parseBody(r, &jsonReorder); err != nil {
+		log.Printf("(DEBUG) handler: reordering task failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := jsonReorder.Validate(); err != nil {
+		log.Printf("(DEBUG) handler: reordering task failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	task, err := h.service.Reorder(taskIDPath, []TaskID(*jsonReorder.IDs))
+	if err != nil {
+		switch err {
+		case ErrTaskNotFound:
+			log.Printf("(INFO) handler: reordering task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusNotFound, err)
+			return
+		case ErrTaskReorderNotValid:
+			log.Printf("(INFO) handler: reordering task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusConflict, err)
+			return
+		default:
+			log.Printf("(WARN) handler: reordering task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	w.Header().Set("ETag", task.ETag())
+	ResponseOK(w, task)
+}
+
+// TasksHandler is simple Handler which handles top level Tasks in tree
+// hierarchy. Handler provides only CR operations.
+// TasksHandler implements http.Handler interface.
+type TasksHandler struct {
+	service TaskService
+}
+
+// NewTasksHandler returns new instance of TasksHandler
+func NewTasksHandler(service TaskService) *TasksHandler {
+	return &TasksHandler{
+		service: service,
+	}
+}
+
+// ServeHTTP is simple function which dispatches requests to proper function
+// handlers.
+// ServeHTTP implements http.Handler interface
+func (h *TasksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Changes are recorded in history with actor of the request.
+	h = &TasksHandler{service: h.service.WithActor(parseActor(r))}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.get(w, r)
+	case http.MethodPost:
+		h.post(w, r)
+	case http.MethodOptions:
+		options(w, r)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+// Get is handler for GET requests for top level Tasks.
+func (h *TasksHandler) get(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseTaskFilter(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: getting tasks failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	order, err := parseTaskOrder(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: getting tasks failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	page, err := parseTaskPage(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: getting tasks failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	depth, err := parseTaskDepth(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: getting tasks failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	fields, err := parseTaskFields(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: getting tasks failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Filtered and full-text results are not paginated, limit would be
+	// silently ignored.
+	query := r.URL.Query()
+	if (query["q"] != nil || !filter.IsEmpty()) && !page.IsEmpty() {
+		log.Printf("(DEBUG) handler: getting tasks failed: %s\n", ErrTaskPageNotSupported)
+		ErrorAsJSON(w, http.StatusBadRequest, ErrTaskPageNotSupported)
+		return
+	}
+
+	if query["q"] != nil {
+		h.text(w, query.Get("q"), filter, order, fields)
+		return
+	}
+
+	if !filter.IsEmpty() {
+		h.filter(w, filter, order, fields)
+		return
+	}
+
+	tasks, err := h.service.FindAll()
+	if err != nil {
+		switch err {
+		case ErrTaskNotFound:
+			log.Printf("(INFO) handler: getting task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusNotFound, err)
+			return
+		default:
+			log.Printf("(WARN) handler: getting task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	sort.Sort(ByPosition(tasks))
+	sortTasks(tasks, order)
+
+	tasks, cursor, err := paginateTasks(tasks, order, page)
+	if err != nil {
+		log.Printf("(DEBUG) handler: getting tasks failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Do not return array in response - it would break future extensions
+	// Better to return object which wraps tasks.
+	response := map[string]interface{}{
+		"tasks": tasksWithFields(tasksWithDepth(tasks, depth), fields),
+	}
+	if cursor != "" {
+		response["next_cursor"] = cursor
+	}
+
+	ResponseOK(w, response)
+}
+
+// Filter returns Tasks from the whole tree matching given TaskFilter with
+// their TaskID paths sorted by given TaskOrder. Tasks are marshaled only with
+// given fields.
+func (h *TasksHandler) filter(w http.ResponseWriter, filter TaskFilter, order TaskOrder, fields TaskFields) {
+	tasks, err := h.service.FindByFilter(filter)
+	if err != nil {
+		log.Printf("(WARN) handler: filtering tasks failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusInternalServerError, err)
+		return
+	}
+	sortTasksWithPath(tasks, order)
+
+	for i := range tasks {
+		tasks[i].Task.fields = fields
+	}
+
+	response := map[string]interface{}{
+		"tasks": tasks,
+	}
+
+	ResponseOK(w, response)
+}
+
+// Text returns Tasks from the whole tree matching given full-text query and
+// TaskFilter with their TaskID paths and scores. Tasks are ordered by score
+// unless TaskOrder is given and they are marshaled only with given fields.
+func (h *TasksHandler) text(w http.ResponseWriter, query string, filter TaskFilter, order TaskOrder, fields TaskFields) {
+	matches, err := h.service.FindByText(query, filter)
+	if err != nil {
+		log.Printf("(WARN) handler: searching tasks by text failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusInternalServerError, err)
+		return
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return order.less(&matches[i].Task, &matches[j].Task) })
+
+	for i := range matches {
+		matches[i].Task.fields = fields
+	}
+
+	response := map[string]interface{}{
+		"tasks": matches,
+	}
+
+	ResponseOK(w, response)
+}
+
+// Post is handler for POST requests for top level Tasks.
+func (h *TasksHandler) post(w http.ResponseWriter, r *http.Request) {
+	var jsonTask JSONTask
+	if err := parseBody(r, &jsonTask); err != nil {
+		log.Printf("(DEBUG) handler: creating task failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := jsonTask.Validate(NewCreateValidator()); err != nil {
+		log.Printf("(DEBUG) handler: creating task failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	createFields := jsonTask.createFields()
+
+	// Creating op level Task - TaskID path will always be empty.
+	newTask, err := h.service.Create([]TaskID{}, createFields)
+	if err != nil {
+		switch err {
+		case ErrTaskBlockerNotFound:
+			log.Printf("(DEBUG) handler: creating task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusBadRequest, err)
+			return
+		case ErrTaskBlockedByOpenTask:
+			log.Printf("(INFO) handler: creating task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusConflict, err)
+			return
+		default:
+			log.Printf("(WARN) handler: creating task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	url := fmt.Sprintf("%s/%d", r.URL.Path, newTask.ID)
+	w.Header().Set("ETag", newTask.ETag())
+	ResponseCreated(w, url, newTask)
+}
+
+// TaskIDHandler is simple Handler which handles Tasks by their TaskID without
+// knowledge of the TaskID path. Handler provides only R operation.
+// TaskIDHandler implements http.Handler interface.
+type TaskIDHandler struct {
+	service TaskService
+}
+
+// NewTaskIDHandler returns new instance of TaskIDHandler
+func NewTaskIDHandler(service TaskService) *TaskIDHandler {
+	return &TaskIDHandler{
+		service: service,
+	}
+}
+
+// ServeHTTP is simple function which dispatches requests to proper function
+// handlers.
+// ServeHTTP implements http.Handler interface
+func (h *TaskIDHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.get(w, r)
+	case http.MethodOptions:
+		options(w, r)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+// Get is handler for GET requests for Task by TaskID.
+func (h *TaskIDHandler) get(w http.ResponseWriter, r *http.Request) {
+	taskID, err := parseTaskID(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: getting task by id failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, ErrHandlerURLNotValid)
+		return
+	}
+
+	depth, err := parseTaskDepth(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: getting task by id failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	fields, err := parseTaskFields(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: getting task by id failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	task, path, err := h.service.FindByID(taskID)
+	if err != nil {
+		switch err {
+		case ErrTaskNotFound:
+			log.Printf("(INFO) handler: getting task by id failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusNotFound, err)
+			return
+		default:
+			log.Printf("(WARN) handler: getting task by id failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	ResponseOK(w, TaskWithPath{Path: path, Task: *task.withDepth(depth).withFields(fields)})
+}
+
+// TagsHandler is simple Handler which handles tags used by Tasks. Handler
+// provides only R operation.
+// TagsHandler implements http.Handler interface.
+type TagsHandler struct {
+	service TaskService
+}
+
+// NewTagsHandler returns new instance of TagsHandler
+func NewTagsHandler(service TaskService) *TagsHandler {
+	return &TagsHandler{
+		service: service,
+	}
+}
+
+// ServeHTTP is simple function which dispatches requests to proper function
+// handlers.
+// ServeHTTP implements http.Handler interface
+func (h *TagsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.get(w, r)
+	case http.MethodOptions:
+		options(w, r)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+// Get is handler for GET requests for all tags with their counts.
+func (h *TagsHandler) get(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.service.FindTags()
+	if err != nil {
+		log.Printf("(WARN) handler: getting tags failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"tags": tags,
+	}
+
+	ResponseOK(w, response)
+}
+
+// SearchHandler is simple Handler which searches Tasks in the whole tree by
+// query given in URL (eg. "/search?q=tag:ops").
+// SearchHandler implements http.Handler interface.
+type SearchHandler struct {
+	service TaskService
+}
+
+// NewSearchHandler returns new instance of SearchHandler
+func NewSearchHandler(service TaskService) *SearchHandler {
+	return &SearchHandler{
+		service: service,
+	}
+}
+
+// ServeHTTP is simple function which dispatches requests to proper function
+// handlers.
+// ServeHTTP implements http.Handler interface
+func (h *SearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.get(w, r)
+	case http.MethodOptions:
+		options(w, r)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+// Get is handler for GET requests for Tasks matching search query.
+func (h *SearchHandler) get(w http.ResponseWriter, r *http.Request) {
+	query, err := ParseSearchQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		log.Printf("(DEBUG) handler: searching tasks failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	results, err := h.service.Search(query)
+	if err != nil {
+		log.Printf("(WARN) handler: searching tasks failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"results": results,
+	}
+
+	ResponseOK(w, response)
+}
+
+// TrashHandler is simple Handler which handles trashed Tasks. Handler lists
+// trashed Tasks, restores them (POST /trash/:id/restore) and purges them
+// permanently (DELETE /trash/:id).
+// TrashHandler implements http.Handler interface.
+type TrashHandler struct {
+	service TaskService
+}
+
+// NewTrashHandler returns new instance of TrashHandler
+func NewTrashHandler(service TaskService) *TrashHandler {
+	return &TrashHandler{
+		service: service,
+	}
+}
+
+// ServeHTTP is simple function which dispatches requests to proper function
+// handlers.
+// ServeHTTP implements http.Handler interface
+func (h *TrashHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Changes are recorded in history with actor of the request.
+	h = &TrashHandler{service: h.service.WithActor(parseActor(r))}
+
+	if r.Method == http.MethodOptions {
+		options(w, r)
+		return
+	}
+
+	// Known URL with other method is not allowed, unknown URL is not valid.
+	parts := urlParts(r)
+	switch {
+	case len(parts) == 1:
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		h.get(w, r)
+	case len(parts) == 2:
+		if r.Method != http.MethodDelete {
+			methodNotAllowed(w)
+			return
+		}
+		h.remove(w, r, parts[1])
+	case len(parts) == 3 && parts[2] == "restore":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w)
+			return
+		}
+		h.restore(w, r, parts[1])
+	default:
+		log.Printf("(DEBUG) handler: unknown trash URL %q\n", r.URL.Path)
+		ErrorAsJSON(w, http.StatusBadRequest, ErrHandlerURLNotValid)
+	}
+}
+
+// Get is handler for GET requests for all trashed Tasks.
+func (h *TrashHandler) get(w http.ResponseWriter, r *http.Request) {
+	trash, err := h.service.FindTrash()
+	if err != nil {
+		log.Printf("(WARN) handler: getting trash failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"trash": trash,
+	}
+
+	ResponseOK(w, response)
+}
+
+// Restore is handler for POST requests which restore trashed Task back to
+// the tree.
+func (h *TrashHandler) restore(w http.ResponseWriter, r *http.Request, value string) {
+	taskID, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("(DEBUG) handler: restoring task failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, ErrHandlerURLNotValid)
+		return
+	}
+
+	task, path, err := h.service.Restore(TaskID(taskID))
+	if err != nil {
+		switch err {
+		case ErrTrashedTaskNotFound:
+			log.Printf("(INFO) handler: restoring task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusNotFound, err)
+			return
+		default:
+			log.Printf("(WARN) handler: restoring task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	w.Header().Set("ETag", task.ETag())
+	ResponseOK(w, TaskWithPath{Path: path, Task: task})
+}
+
+// Remove is handler for DELETE requests which purge trashed Task
+// permanently.
+func (h *TrashHandler) remove(w http.ResponseWriter, r *http.Request, value string) {
+	taskID, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("(DEBUG) handler: purging task failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, ErrHandlerURLNotValid)
+		return
+	}
+
+	trashed, err := h.service.Purge(TaskID(taskID))
+	if err != nil {
+		switch err {
+		case ErrTrashedTaskNotFound:
+			log.Printf("(INFO) handler: purging task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusNotFound, err)
+			return
+		default:
+			log.Printf("(WARN) handler: purging task failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	ResponseOK(w, trashed)
+}
+
+// UndoHandler is simple Handler which reverts the latest operation
+// (POST /undo).
+// UndoHandler implements http.Handler interface.
+type UndoHandler struct {
+	service TaskService
+}
+
+// NewUndoHandler returns new instance of UndoHandler
+func NewUndoHandler(service TaskService) *UndoHandler {
+	return &UndoHandler{
+		service: service,
+	}
+}
+
+// ServeHTTP is simple function which dispatches requests to proper function
+// handlers.
+// ServeHTTP implements http.Handler interface
+func (h *UndoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Changes are recorded in history with actor of the request.
+	h = &UndoHandler{service: h.service.WithActor(parseActor(r))}
+
+	switch r.Method {
+	case http.MethodPost:
+		h.post(w, r)
+	case http.MethodOptions:
+		options(w, r)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+// Post is handler for POST requests which revert the latest operation.
+func (h *UndoHandler) post(w http.ResponseWriter, r *http.Request) {
+	history, err := h.service.Undo()
+	if err != nil {
+		switch err {
+		case ErrHistoryNothingToUndo, ErrTaskNotFound, ErrTaskMoveNotValid:
+			log.Printf("(INFO) handler: undoing operation failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusConflict, err)
+			return
+		default:
+			log.Printf("(WARN) handler: undoing operation failed: %s\n", err)
+			ErrorAsJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	response := map[string]interface{}{
+		"history": history,
+	}
+
+	ResponseOK(w, response)
+}
+
+// BatchHandler is simple Handler which applies ordered list of operations on
+// Tasks atomically (POST /batch).
+// BatchHandler implements http.Handler interface.
+type BatchHandler struct {
+	service TaskService
+}
+
+// NewBatchHandler returns new instance of BatchHandler
+func NewBatchHandler(service TaskService) *BatchHandler {
+	return &BatchHandler{
+		service: service,
+	}
+}
+
+// ServeHTTP is simple function which dispatches requests to proper function
+// handlers.
+// ServeHTTP implements http.Handler interface
+func (h *BatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Changes are recorded in history with actor of the request.
+	h = &BatchHandler{service: h.service.WithActor(parseActor(r))}
+
+	switch r.Method {
+	case http.MethodPost:
+		h.post(w, r)
+	case http.MethodOptions:
+		options(w, r)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+// Post is handler for POST requests which apply batch of operations. Failed
+// operation is returned with its index and no operation is applied.
+func (h *BatchHandler) post(w http.ResponseWriter, r *http.Request) {
+	var jsonBatch JSONBatch
+	if err := parseBody(r, &jsonBatch); err != nil {
+		log.Printf("(DEBUG) handler: applying batch failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := jsonBatch.Validate(); err != nil {
+		log.Printf("(DEBUG) handler: applying batch failed: %s\n", err)
+		batchErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	results, err := h.service.Batch(jsonBatch.batchOperations())
+	if err != nil {
+		cause := err
+		if batchErr, ok := err.(*BatchError); ok {
+			cause = batchErr.Err
+		}
+
+		switch cause {
+		case ErrTaskNotFound:
+			log.Printf("(INFO) handler: applying batch failed: %s\n", err)
+			batchErrorAsJSON(w, http.StatusNotFound, err)
+			return
+		case ErrTaskPreconditionFailed:
+			log.Printf("(INFO) handler: applying batch failed: %s\n", err)
+			batchErrorAsJSON(w, http.StatusPreconditionFailed, err)
+			return
+		case ErrTaskStartAtAfterDueAt, ErrTaskBlockerNotFound, ErrBatchReferenceNotValid:
+			log.Printf("(DEBUG) handler: applying batch failed: %s\n", err)
+			batchErrorAsJSON(w, http.StatusBadRequest, err)
+			return
+		case ErrTaskStatusTransitionNotValid, ErrTaskDependencyCycle, ErrTaskBlockedByOpenTask, ErrTaskMoveNotValid:
+			log.Printf("(INFO) handler: applying batch failed: %s\n", err)
+			batchErrorAsJSON(w, http.StatusConflict, err)
+			return
+		default:
+			log.Printf("(WARN) handler: applying batch failed: %s\n", err)
+			batchErrorAsJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	response := map[string]interface{}{
+		"results": results,
+	}
+
+	ResponseOK(w, response)
+}
+
+// batchErrorAsJSON returns given error as JSON payload with given status
+// code. BatchError has also index of the failed operation.
+func batchErrorAsJSON(w http.ResponseWriter, statusCode int, err error) {
+	batchErr, ok := err.(*BatchError)
+	if !ok {
+		ErrorAsJSON(w, statusCode, err)
+		return
+	}
+
+	ResponseAsJSON(w, statusCode, map[string]interface{}{
+		"error":     batchErr.Error(),
+		"operation": batchErr.Index,
+	})
+}
+
+// BulkHandler is simple Handler which updates (POST /tasks/bulk-update) or
+// deletes (POST /tasks/bulk-delete) all Tasks matching search query.
+// BulkHandler implements http.Handler interface.
+type BulkHandler struct {
+	service TaskService
+}
+
+// NewBulkHandler returns new instance of BulkHandler
+func NewBulkHandler(service TaskService) *BulkHandler {
+	return &BulkHandler{
+		service: service,
+	}
+}
+
+// ServeHTTP is simple function which dispatches requests to proper function
+// handlers.
+// ServeHTTP implements http.Handler interface
+func (h *BulkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Changes are recorded in history with actor of the request.
+	h = &BulkHandler{service: h.service.WithActor(parseActor(r))}
+
+	if r.Method == http.MethodOptions {
+		options(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+
+	operation := ""
+	if parts := urlParts(r); len(parts) == 2 {
+		operation = parts[1]
+	}
+
+	switch operation {
+	case "bulk-update":
+		h.update(w, r)
+	case "bulk-delete":
+		h.remove(w, r)
+	default:
+		log.Printf("(DEBUG) handler: unknown bulk operation %q\n", r.URL.Path)
+		ErrorAsJSON(w, http.StatusBadRequest, ErrHandlerURLNotValid)
+	}
+}
+
+// Update is handler for POST requests which update all matching Tasks.
+func (h *BulkHandler) update(w http.ResponseWriter, r *http.Request) {
+	jsonBulk, bulk, err := parseBulk(r)
+	if err == nil && jsonBulk.Task == nil {
+		err = ErrTaskLabelOrCompletedRequired
+	}
+	if err != nil {
+		log.Printf("(DEBUG) handler: updating tasks in bulk failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	results, err := h.service.BulkUpdate(bulk, jsonBulk.Task.updateFields())
+	if err != nil {
+		log.Printf("(WARN) handler: updating tasks in bulk failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	bulkResponse(w, bulk, results)
+}
+
+// Remove is handler for POST requests which delete all matching Tasks.
+func (h *BulkHandler) remove(w http.ResponseWriter, r *http.Request) {
+	_, bulk, err := parseBulk(r)
+	if err != nil {
+		log.Printf("(DEBUG) handler: deleting tasks in bulk failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	results, err := h.service.BulkDelete(bulk)
+	if err != nil {
+		log.Printf("(WARN) handler: deleting tasks in bulk failed: %s\n", err)
+		ErrorAsJSON(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	bulkResponse(w, bulk, results)
+}
+
+// parseBulk returns validated JSONBulk from request body with its
+// BulkFields.
+func parseBulk(r *http.Request) (*JSONBulk, BulkFields, error) {
+	var jsonBulk JSONBulk
+	if err := parseBody(r, &jsonBulk); err != nil {
+		return nil, BulkFields{}, err
+	}
+
+	if err := jsonBulk.Validate(); err != nil {
+		return nil, BulkFields{}, err
+	}
+
+	bulk, err := jsonBulk.bulkFields()
+	if err != nil {
+		return nil, BulkFields{}, err
+	}
+
+	return &jsonBulk, bulk, nil
+}
+
+// bulkResponse returns results of bulk operation with number of changed and
+// failed Tasks.
+func bulkResponse(w http.ResponseWriter, bulk BulkFields, results []BulkResult) {
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+
+	response := map[string]interface{}{
+		"dry_run": bulk.DryRun,
+		"matched": len(results),
+		"failed":  failed,
+		"results": results,
+	}
+
+	ResponseOK(w, response)
 }
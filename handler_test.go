@@ -1,18 +1,24 @@
 package tasks
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestTaskHandler(t *testing.T) {
 	tests := map[string]struct {
 		method        string
 		path          string
+		contentType   string
 		body          io.Reader
 		res           string
 		resStatusCode int
@@ -30,6 +36,13 @@ func TestTaskHandler(t *testing.T) {
 			res:           `{"id":"1","label":"foo","completed":false}`,
 			resStatusCode: 201,
 		},
+		"PUT /tasks/1 recurrence not valid": {
+			method:        "PUT",
+			path:          "/tasks/1",
+			body:          strings.NewReader(`{"recurrence":"FREQ=YEARLY"}`),
+			res:           `{"error":"Task field Recurrence is not valid"}`,
+			resStatusCode: 400,
+		},
 		"PUT/tasks/1": {
 			method:        "PUT",
 			path:          "/tasks/1",
@@ -49,9 +62,93 @@ func TestTaskHandler(t *testing.T) {
 			res:           "",
 			resStatusCode: 200,
 		},
+		"POST /tasks/1/2/move": {
+			method:        "POST",
+			path:          "/tasks/1/2/move",
+			body:          strings.NewReader(`{"target":["3"]}`),
+			res:           `{"path":["3","2"],"task":{"id":"2","label":"bar","completed":false}}`,
+			resStatusCode: 200,
+		},
+		"POST /tasks/1/reorder": {
+			method:        "POST",
+			path:          "/tasks/1/reorder",
+			body:          strings.NewReader(`{"ids":["3","2"]}`),
+			res:           `{"id":"1","label":"foo","completed":false,"sub_tasks":[{"id":"3","label":"baz","completed":false,"position":1},{"id":"2","label":"bar","completed":false,"position":2}],"progress":{"done":0,"total":2}}`,
+			resStatusCode: 200,
+		},
+		"POST /tasks/1/reorder missing ids": {
+			method:        "POST",
+			path:          "/tasks/1/reorder",
+			body:          strings.NewReader(`{}`),
+			res:           `{"error":"Task reorder field IDs is required"}`,
+			resStatusCode: 400,
+		},
+		"POST /tasks/1/reorder not valid": {
+			method:        "POST",
+			path:          "/tasks/1/reorder",
+			body:          strings.NewReader(`{"ids":["2"]}`),
+			res:           `{"error":"Task reorder IDs must contain every sub task exactly once"}`,
+			resStatusCode: 409,
+		},
+		"POST /tasks/1/2/move under itself": {
+			method:        "POST",
+			path:          "/tasks/1/2/move",
+			body:          strings.NewReader(`{"target":["2"]}`),
+			res:           `{"error":"Task can't be moved under itself or its sub task"}`,
+			resStatusCode: 409,
+		},
+		"POST /tasks/1/2/move no target": {
+			method:        "POST",
+			path:          "/tasks/1/2/move",
+			body:          strings.NewReader(`{}`),
+			res:           `{"error":"Task move field Target is required"}`,
+			resStatusCode: 400,
+		},
+		"POST /tasks/1/2/clone": {
+			method:        "POST",
+			path:          "/tasks/1/2/clone",
+			body:          strings.NewReader(`{"target":[],"reset_completed":true}`),
+			res:           `{"ids":{"2":"4"},"path":["4"],"task":{"id":"4","label":"bar","completed":false}}`,
+			resStatusCode: 201,
+		},
+		"GET /tasks/1/2/move": {
+			method:        "GET",
+			path:          "/tasks/1/2/move",
+			res:           `{"error":"method not allowed"}`,
+			resStatusCode: 405,
+		},
+		"GET /tasks/1/kekeke": {
+			method:        "GET",
+			path:          "/tasks/1/kekeke",
+			res:           `{"error":"URL parameters are not valid number(s)"}`,
+			resStatusCode: 400,
+		},
 		"PATCH /tasks/1": {
 			method:        "PATCH",
 			path:          "/tasks/1",
+			contentType:   "application/merge-patch+json",
+			body:          strings.NewReader(`{"label":"foo_new"}`),
+			res:           `{"id":"1","label":"foo_new","completed":false}`,
+			resStatusCode: 200,
+		},
+		"PATCH /tasks/1 json patch": {
+			method:        "PATCH",
+			path:          "/tasks/1",
+			contentType:   "application/json-patch+json",
+			body:          strings.NewReader(`[{"op":"test","path":"/label","value":"bar"}]`),
+			res:           `{"error":"Task patch test operation failed"}`,
+			resStatusCode: 409,
+		},
+		"PATCH /tasks/1 application/json": {
+			method:        "PATCH",
+			path:          "/tasks/1",
+			body:          strings.NewReader(`{"label":"foo_new"}`),
+			res:           `{"error":"Bad media type"}`,
+			resStatusCode: 400,
+		},
+		"TRACE /tasks/1": {
+			method:        "TRACE",
+			path:          "/tasks/1",
 			res:           `{"error":"method not allowed"}`,
 			resStatusCode: 405,
 		},
@@ -64,7 +161,9 @@ func TestTaskHandler(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		if tc.method == "POST" || tc.method == "PUT" {
+		if tc.contentType != "" {
+			r.Header.Add("Content-Type", tc.contentType)
+		} else if tc.method == "POST" || tc.method == "PUT" || tc.method == "PATCH" {
 			r.Header.Add("Content-Type", "application/json")
 		}
 
@@ -87,6 +186,7 @@ func TestTaskHandler(t *testing.T) {
 func TestTasksHandler(t *testing.T) {
 	tests := map[string]struct {
 		method        string
+		query         string
 		body          io.Reader
 		res           string
 		resStatusCode int
@@ -96,6 +196,102 @@ func TestTasksHandler(t *testing.T) {
 			res:           `{"tasks":[{"id":"1","label":"foo","completed":true},{"id":"2","label":"bar","completed":false}]}`,
 			resStatusCode: 200,
 		},
+		"GET /tasks?overdue=true": {
+			method:        "GET",
+			query:         "?overdue=true",
+			res:           `{"tasks":[{"path":["1","3"],"task":{"id":"3","label":"baz","completed":false,"due_at":"2020-01-02T00:00:00Z"}}]}`,
+			resStatusCode: 200,
+		},
+		"GET /tasks?due_before=2020-01-03T00:00:00Z": {
+			method:        "GET",
+			query:         "?due_before=2020-01-03T00:00:00Z",
+			res:           `{"tasks":[{"path":["1","3"],"task":{"id":"3","label":"baz","completed":false,"due_at":"2020-01-02T00:00:00Z"}}]}`,
+			resStatusCode: 200,
+		},
+		"GET /tasks?tag=backend&tag=urgent": {
+			method:        "GET",
+			query:         "?tag=backend&tag=urgent",
+			res:           `{"tasks":[{"path":["1","3"],"task":{"id":"3","label":"baz","completed":false,"due_at":"2020-01-02T00:00:00Z"}}]}`,
+			resStatusCode: 200,
+		},
+		"GET /tasks?tag=Backend": {
+			method:        "GET",
+			query:         "?tag=Backend",
+			res:           `{"error":"URL query parameters are not valid"}`,
+			resStatusCode: 400,
+		},
+		"GET /tasks?sort=-created_at": {
+			method:        "GET",
+			query:         "?sort=-created_at",
+			res:           `{"tasks":[{"id":"1","label":"foo","completed":true},{"id":"2","label":"bar","completed":false}]}`,
+			resStatusCode: 200,
+		},
+		"GET /tasks?sort=label": {
+			method:        "GET",
+			query:         "?sort=label",
+			res:           `{"tasks":[{"id":"2","label":"bar","completed":false},{"id":"1","label":"foo","completed":true}]}`,
+			resStatusCode: 200,
+		},
+		"GET /tasks?limit=0": {
+			method:        "GET",
+			query:         "?limit=0",
+			res:           `{"error":"URL query parameters are not valid"}`,
+			resStatusCode: 400,
+		},
+		"GET /tasks?cursor=kekeke": {
+			method:        "GET",
+			query:         "?cursor=kekeke",
+			res:           `{"error":"Task page cursor is not valid"}`,
+			resStatusCode: 400,
+		},
+		"GET /tasks?tag=backend&limit=10": {
+			method:        "GET",
+			query:         "?tag=backend&limit=10",
+			res:           `{"error":"Task page is not supported for filtered or searched Tasks"}`,
+			resStatusCode: 400,
+		},
+		"GET /tasks?q=foo&cursor=kekeke": {
+			method:        "GET",
+			query:         "?q=foo&cursor=kekeke",
+			res:           `{"error":"Task page is not supported for filtered or searched Tasks"}`,
+			resStatusCode: 400,
+		},
+		"GET /tasks?depth=-1": {
+			method:        "GET",
+			query:         "?depth=-1",
+			res:           `{"error":"URL query parameters are not valid"}`,
+			resStatusCode: 400,
+		},
+		"GET /tasks?fields=id": {
+			method:        "GET",
+			query:         "?fields=id",
+			res:           `{"tasks":[{"id":"1"},{"id":"2"}]}`,
+			resStatusCode: 200,
+		},
+		"GET /tasks?fields=id(label)": {
+			method:        "GET",
+			query:         "?fields=id(label)",
+			res:           `{"error":"URL query parameters are not valid"}`,
+			resStatusCode: 400,
+		},
+		"GET /tasks?sort=status": {
+			method:        "GET",
+			query:         "?sort=status",
+			res:           `{"error":"URL query parameters are not valid"}`,
+			resStatusCode: 400,
+		},
+		"GET /tasks?overdue=kekeke": {
+			method:        "GET",
+			query:         "?overdue=kekeke",
+			res:           `{"error":"URL query parameters are not valid"}`,
+			resStatusCode: 400,
+		},
+		"GET /tasks?due_before=tomorrow": {
+			method:        "GET",
+			query:         "?due_before=tomorrow",
+			res:           `{"error":"URL query parameters are not valid"}`,
+			resStatusCode: 400,
+		},
 		"POST /tasks": {
 			method:        "POST",
 			body:          strings.NewReader(`{"label":"foo"}`),
@@ -117,7 +313,7 @@ func TestTasksHandler(t *testing.T) {
 	for desc, tc := range tests {
 		t.Log(desc)
 
-		r, err := http.NewRequest(tc.method, "http://foo.com/tasks", tc.body)
+		r, err := http.NewRequest(tc.method, "http://foo.com/tasks"+tc.query, tc.body)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -141,51 +337,1123 @@ func TestTasksHandler(t *testing.T) {
 	}
 }
 
-type mockService struct{}
+// TestHandlersConcurrent hammers handlers backed by real service and storage
+// from many goroutines. Run it with -race flag to detect data races.
+func TestHandlersConcurrent(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
 
-func (s *mockService) Create(path []TaskID, cf CreateFields) (Task, error) {
-	return Task{
-		ID:        TaskID(1),
-		Label:     "foo",
-		Completed: false,
-	}, nil
-}
+	mux := http.NewServeMux()
+	mux.Handle("/tasks", NewTasksHandler(service))
+	mux.Handle("/tasks/", NewTaskHandler(service))
 
-func (s *mockService) Find(path []TaskID) (Task, error) {
-	return Task{
-		ID:        TaskID(2),
-		Label:     "bar",
-		Completed: true,
-	}, nil
+	do := func(method, path, body string) (int, Task) {
+		r := httptest.NewRequest(method, fmt.Sprintf("http://foo.com%s", path), strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+
+		var task Task
+		json.Unmarshal(w.Body.Bytes(), &task)
+
+		return w.Code, task
+	}
+
+	const workers = 16
+	const iterations = 20
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				code, root := do("POST", "/tasks", `{"label":"root"}`)
+				if code != http.StatusCreated {
+					t.Errorf("expected status code %d got %d", http.StatusCreated, code)
+					return
+				}
+				rootPath := fmt.Sprintf("/tasks/%d", root.ID)
+
+				code, child := do("POST", rootPath, `{"label":"child"}`)
+				if code != http.StatusCreated {
+					t.Errorf("expected status code %d got %d", http.StatusCreated, code)
+					return
+				}
+				childPath := fmt.Sprintf("%s/%d", rootPath, child.ID)
+
+				for _, req := range []struct{ method, path, body string }{
+					{"GET", "/tasks", ""},
+					{"GET", rootPath, ""},
+					{"PUT", rootPath, `{"completed":true}`},
+					{"PUT", childPath, `{"label":"child_new"}`},
+					{"GET", childPath, ""},
+					{"DELETE", childPath, ""},
+				} {
+					if code, _ := do(req.method, req.path, req.body); code != http.StatusOK {
+						t.Errorf("%s %s: expected status code %d got %d", req.method, req.path, http.StatusOK, code)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	tasks, err := service.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tasks) != workers*iterations {
+		t.Fatalf("expected %d tasks got %d", workers*iterations, len(tasks))
+	}
+
+	for _, task := range tasks {
+		if !task.Completed || len(task.Children) != 0 {
+			t.Fatalf("expected completed task without children got %v", task)
+		}
+	}
 }
 
-func (s *mockService) FindAll() ([]Task, error) {
-	return []Task{
-		Task{
-			ID:        TaskID(1),
-			Label:     "foo",
-			Completed: true,
+func TestTaskIDHandler(t *testing.T) {
+	tests := map[string]struct {
+		method        string
+		path          string
+		res           string
+		resStatusCode int
+	}{
+		"GET /tasks/ids/2": {
+			method:        "GET",
+			path:          "/tasks/ids/2",
+			res:           `{"path":["1","2"],"task":{"id":"2","label":"bar","completed":true}}`,
+			resStatusCode: 200,
 		},
-		Task{
-			ID:        TaskID(2),
-			Label:     "bar",
-			Completed: false,
+		"GET /tasks/ids/kekeke": {
+			method:        "GET",
+			path:          "/tasks/ids/kekeke",
+			res:           `{"error":"URL parameters are not valid number(s)"}`,
+			resStatusCode: 400,
 		},
-	}, nil
+		"POST /tasks/ids/2": {
+			method:        "POST",
+			path:          "/tasks/ids/2",
+			res:           `{"error":"method not allowed"}`,
+			resStatusCode: 405,
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		r, err := http.NewRequest(tc.method, fmt.Sprintf("http://foo.com%s", tc.path), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		service := &mockService{}
+		handler := NewTaskIDHandler(service)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if tc.resStatusCode != w.Code {
+			t.Fatalf("expected status code %d got %d", tc.resStatusCode, w.Code)
+		}
+
+		if tc.res != w.Body.String() {
+			t.Fatalf("expected response \n%s\n got \n%s\n", tc.res, w.Body.String())
+		}
+	}
 }
 
-func (s *mockService) Update(path []TaskID, uf UpdateFields) (Task, error) {
-	return Task{
-		ID:        TaskID(1),
-		Label:     *uf.Label,
-		Completed: false,
-	}, nil
+func TestTaskHandlerNotes(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+	handler := NewTaskHandler(service)
+
+	task, err := service.Create([]TaskID{}, CreateFields{Label: "foo", Notes: "# Foo\n<b>bar</b>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", fmt.Sprintf("http://foo.com/tasks/%d/notes.html", task.ID), nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+	}
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		t.Fatalf("expected content type %s got %s", "text/html; charset=utf-8", contentType)
+	}
+
+	expected := "<h1>Foo</h1>\n<p>&lt;b&gt;bar&lt;/b&gt;</p>\n"
+	if w.Body.String() != expected {
+		t.Fatalf("expected response \n%s\n got \n%s\n", expected, w.Body.String())
+	}
+
+	r = httptest.NewRequest("PUT", fmt.Sprintf("http://foo.com/tasks/%d/notes.html", task.ID), nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status code %d got %d", http.StatusMethodNotAllowed, w.Code)
+	}
 }
 
-func (s *mockService) Delete(path []TaskID) (Task, error) {
-	return Task{
-		ID:        TaskID(3),
-		Label:     "baz",
+func TestTasksHandlerSubTasks(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+	handler := NewTasksHandler(service)
+
+	r := httptest.NewRequest("POST", "http://foo.com/tasks", strings.NewReader(`{"label":"checklist","sub_tasks":[{"label":"first","sub_tasks":[{"label":"nested"}]},{"label":"second"}]}`))
+	r.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d got %d", http.StatusCreated, w.Code)
+	}
+
+	tasks, err := service.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || len(tasks[0].Children) != 2 {
+		t.Fatalf("expected Task with 2 sub tasks got %v", tasks)
+	}
+
+	if location := w.Header().Get("Location"); location != fmt.Sprintf("/tasks/%d", tasks[0].ID) {
+		t.Fatalf("expected location /tasks/%d got %s", tasks[0].ID, location)
+	}
+
+	if !strings.Contains(w.Body.String(), `"label":"nested"`) {
+		t.Fatalf("expected response with sub tasks got \n%s\n", w.Body.String())
+	}
+
+	r = httptest.NewRequest("POST", "http://foo.com/tasks", strings.NewReader(`{"label":"checklist","sub_tasks":[{"label":""}]}`))
+	r.Header.Add("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status code %d got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestTaskHandlerStatus(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+	handler := NewTaskHandler(service)
+
+	task, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("PUT", fmt.Sprintf("http://foo.com/tasks/%d", task.ID), strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	if w := do(`{"status":"kekeke"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status code %d got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if w := do(`{"status":"blocked"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+	}
+
+	if w := do(`{"completed":true}`); w.Code != http.StatusConflict {
+		t.Fatalf("expected status code %d got %d", http.StatusConflict, w.Code)
+	}
+
+	if w := do(`{"status":"in_progress"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+	}
+
+	w := do(`{"completed":true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+	}
+
+	var res Task
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Status != StatusDone || !res.Completed {
+		t.Fatalf("expected status %s and completed got %s and %t", StatusDone, res.Status, res.Completed)
+	}
+}
+
+func TestTaskHandlerDependencies(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+	handler := NewTaskHandler(service)
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bar, err := service.Create([]TaskID{foo.ID}, CreateFields{Label: "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	baz, err := service.Create([]TaskID{}, CreateFields{Label: "baz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://foo.com"+path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	barPath := fmt.Sprintf("/tasks/%d/%d", foo.ID, bar.ID)
+	bazPath := fmt.Sprintf("/tasks/%d", baz.ID)
+
+	if w := do("PUT", barPath, fmt.Sprintf(`{"blocked_by":["%d"]}`, baz.ID)); w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+	}
+
+	if w := do("PUT", bazPath, fmt.Sprintf(`{"blocked_by":["%d"]}`, bar.ID)); w.Code != http.StatusConflict {
+		t.Fatalf("expected status code %d got %d", http.StatusConflict, w.Code)
+	}
+
+	if w := do("PUT", bazPath, `{"blocked_by":["42"]}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status code %d got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if w := do("PUT", barPath, `{"completed":true}`); w.Code != http.StatusConflict {
+		t.Fatalf("expected status code %d got %d", http.StatusConflict, w.Code)
+	}
+
+	w := do("GET", bazPath+"/dependencies", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+	}
+
+	var res TaskDependencies
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Upstream) != 0 || len(res.Downstream) != 1 || res.Downstream[0].Task.ID != bar.ID {
+		t.Fatalf("expected only downstream Task %d got %s", bar.ID, w.Body.String())
+	}
+
+	if w := do("POST", bazPath+"/dependencies", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status code %d got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestTrashHandler(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+	taskHandler := NewTaskHandler(service)
+	handler := NewTrashHandler(service)
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bar, err := service.Create([]TaskID{foo.ID}, CreateFields{Label: "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(h http.Handler, method, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://foo.com"+path, nil)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		return w
+	}
+
+	if w := do(taskHandler, "DELETE", fmt.Sprintf("/tasks/%d/%d", foo.ID, bar.ID)); w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+	}
+
+	w := do(handler, "GET", "/trash")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+	}
+
+	var res struct {
+		Trash []TrashedTask `json:"trash"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Trash) != 1 || res.Trash[0].Task.ID != bar.ID || !reflect.DeepEqual(TaskIDPath{foo.ID, bar.ID}, res.Trash[0].Path) {
+		t.Fatalf("expected trashed Task %d got %s", bar.ID, w.Body.String())
+	}
+
+	if w := do(handler, "POST", fmt.Sprintf("/trash/%d/restore", bar.ID)); w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+	}
+
+	if w := do(handler, "POST", fmt.Sprintf("/trash/%d/restore", bar.ID)); w.Code != http.StatusNotFound {
+		t.Fatalf("expected status code %d got %d", http.StatusNotFound, w.Code)
+	}
+
+	if w := do(taskHandler, "DELETE", fmt.Sprintf("/tasks/%d", foo.ID)); w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+	}
+
+	if w := do(handler, "DELETE", fmt.Sprintf("/trash/%d", foo.ID)); w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+	}
+
+	if w := do(handler, "DELETE", fmt.Sprintf("/trash/%d", foo.ID)); w.Code != http.StatusNotFound {
+		t.Fatalf("expected status code %d got %d", http.StatusNotFound, w.Code)
+	}
+
+	if w := do(handler, "DELETE", "/trash/foo"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status code %d got %d", http.StatusBadRequest, w.Code)
+	}
+
+	notAllowed := map[string]string{
+		"PUT":    "/trash",
+		"POST":   "/trash/5",
+		"DELETE": "/trash/5/restore",
+	}
+	for method, path := range notAllowed {
+		if w := do(handler, method, path); w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("expected status code %d for %s %s got %d", http.StatusMethodNotAllowed, method, path, w.Code)
+		}
+	}
+
+	// Unknown trash action is not valid for any method.
+	for _, method := range []string{"GET", "POST", "DELETE"} {
+		if w := do(handler, method, "/trash/5/foo"); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d for %s got %d", http.StatusBadRequest, method, w.Code)
+		}
+	}
+}
+
+func TestTaskHandlerHistory(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+	handler := NewTaskHandler(service)
+	undoHandler := NewUndoHandler(service)
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	label := "bar"
+	if _, err := service.Update([]TaskID{foo.ID}, UpdateFields{Label: &label}); err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(h http.Handler, method, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://foo.com"+path, nil)
+		r.Header.Set("X-Actor", "alice")
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		return w
+	}
+
+	w := do(handler, "GET", fmt.Sprintf("/tasks/%d/history", foo.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+	}
+
+	var res struct {
+		History []HistoryEntry `json:"history"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.History) != 2 || res.History[0].Action != HistoryActionInsert || res.History[1].Op != OperationUpdate {
+		t.Fatalf("expected insert and update of Task %d got %s", foo.ID, w.Body.String())
+	}
+
+	if w := do(handler, "POST", fmt.Sprintf("/tasks/%d/revert?version=1", foo.ID)); w.Code != http.StatusOK || w.Header().Get("ETag") == "" {
+		t.Fatalf("expected status code %d with ETag got %d", http.StatusOK, w.Code)
+	}
+
+	// Revert is recorded with actor of the request.
+	history, err := service.FindHistory([]TaskID{foo.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || history[0].Actor != "" || history[2].Op != OperationRevert || history[2].Actor != "alice" {
+		t.Fatalf("expected revert by %q got %v", "alice", history)
+	}
+
+	if w := do(handler, "POST", fmt.Sprintf("/tasks/%d/revert?version=42", foo.ID)); w.Code != http.StatusNotFound {
+		t.Fatalf("expected status code %d got %d", http.StatusNotFound, w.Code)
+	}
+
+	if w := do(handler, "POST", fmt.Sprintf("/tasks/%d/revert?version=foo", foo.ID)); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status code %d got %d", http.StatusBadRequest, w.Code)
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := do(undoHandler, "POST", "/undo"); w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+		}
+	}
+
+	if w := do(undoHandler, "POST", "/undo"); w.Code != http.StatusConflict {
+		t.Fatalf("expected status code %d got %d", http.StatusConflict, w.Code)
+	}
+
+	if w := do(undoHandler, "GET", "/undo"); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status code %d got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestBatchHandler(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+	handler := NewBatchHandler(service)
+
+	tests := map[string]struct {
+		method     string
+		body       string
+		statusCode int
+		res        string
+	}{
+		"create checklist": {
+			method:     "POST",
+			body:       `{"operations":[{"op":"create","ref":"$list","task":{"label":"checklist"}},{"op":"create","path":["$list"],"task":{"label":"step"}}]}`,
+			statusCode: http.StatusOK,
+		},
+		"not valid ref": {
+			method:     "POST",
+			body:       `{"operations":[{"op":"create","task":{"label":"foo"}},{"op":"delete","path":["$list"]}]}`,
+			statusCode: http.StatusBadRequest,
+			res:        `{"error":"Batch operation 1 failed: Batch reference is not valid","operation":1}`,
+		},
+		"not found": {
+			method:     "POST",
+			body:       `{"operations":[{"op":"create","task":{"label":"foo"}},{"op":"move","path":["42"],"target":[]}]}`,
+			statusCode: http.StatusNotFound,
+			res:        `{"error":"Batch operation 1 failed: Task not found","operation":1}`,
+		},
+		"method not allowed": {
+			method:     "GET",
+			statusCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, "http://foo.com/batch", strings.NewReader(test.body))
+			r.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != test.statusCode {
+				t.Fatalf("expected status code %d got %d: %s", test.statusCode, w.Code, w.Body.String())
+			}
+
+			if test.res != "" && test.res != w.Body.String() {
+				t.Fatalf("expected response \n%s\n got \n%s\n", test.res, w.Body.String())
+			}
+		})
+	}
+
+	// Only the successful batch was applied.
+	tasks, err := service.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tasks) != 1 || tasks[0].Label != "checklist" || len(tasks[0].Children) != 1 {
+		t.Fatalf("expected checklist with one step got %v", tasks)
+	}
+}
+
+func TestBulkHandler(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+	handler := NewBulkHandler(service)
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo", Tags: []string{"sprint-12"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Create([]TaskID{}, CreateFields{Label: "bar"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tests run in order, later tests see changes of the earlier ones.
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		statusCode int
+		res        string
+	}{
+		{
+			name:       "dry run update",
+			method:     "POST",
+			path:       "/tasks/bulk-update",
+			body:       `{"query":"tag:sprint-12","dry_run":true,"task":{"label":"baz"}}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "update",
+			method:     "POST",
+			path:       "/tasks/bulk-update",
+			body:       `{"query":"tag:sprint-12","task":{"completed":true}}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "update without fields",
+			method:     "POST",
+			path:       "/tasks/bulk-update",
+			body:       `{"query":"tag:sprint-12"}`,
+			statusCode: http.StatusBadRequest,
+			res:        `{"error":"At least one Task field is required"}`,
+		},
+		{
+			name:       "delete without query",
+			method:     "POST",
+			path:       "/tasks/bulk-delete",
+			body:       `{"query":" "}`,
+			statusCode: http.StatusBadRequest,
+			res:        `{"error":"Bulk field Query is required"}`,
+		},
+		{
+			name:       "delete not valid query",
+			method:     "POST",
+			path:       "/tasks/bulk-delete",
+			body:       `{"query":"owner:me"}`,
+			statusCode: http.StatusBadRequest,
+			res:        `{"error":"Search query is not valid at position 1: unknown field \"owner\""}`,
+		},
+		{
+			name:       "delete",
+			method:     "POST",
+			path:       "/tasks/bulk-delete",
+			body:       `{"query":"label:bar"}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "unknown operation",
+			method:     "POST",
+			path:       "/tasks/bulk-move",
+			body:       `{"query":"label:bar"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "method not allowed",
+			method:     "GET",
+			path:       "/tasks/bulk-update",
+			statusCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, "http://foo.com"+test.path, strings.NewReader(test.body))
+			r.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != test.statusCode {
+				t.Fatalf("expected status code %d got %d: %s", test.statusCode, w.Code, w.Body.String())
+			}
+
+			if test.res != "" && test.res != w.Body.String() {
+				t.Fatalf("expected response \n%s\n got \n%s\n", test.res, w.Body.String())
+			}
+		})
+	}
+
+	// Only foo was updated, bar was deleted.
+	tasks, err := service.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tasks) != 1 || tasks[0].ID != foo.ID || tasks[0].Label != "foo" || !tasks[0].Completed {
+		t.Fatalf("expected completed Task %d got %v", foo.ID, tasks)
+	}
+}
+
+func TestSearchHandler(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+	handler := NewSearchHandler(service)
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bar, err := service.Create([]TaskID{foo.ID}, CreateFields{Label: "Deploy app", Tags: []string{"ops"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Create([]TaskID{foo.ID, bar.ID}, CreateFields{Label: "deploy db", Tags: []string{"ops"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		query      string
+		statusCode int
+		paths      []TaskIDPath
+	}{
+		"label and depth": {
+			query:      `label:~"deploy" depth:<3 tag:ops`,
+			statusCode: http.StatusOK,
+			paths:      []TaskIDPath{TaskIDPath{foo.ID, bar.ID}},
+		},
+		"empty query": {
+			query:      "",
+			statusCode: http.StatusOK,
+			paths:      []TaskIDPath{TaskIDPath{foo.ID}, TaskIDPath{foo.ID, bar.ID}, TaskIDPath{foo.ID, bar.ID, bar.ID + 1}},
+		},
+		"unknown field": {
+			query:      "owner:me",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://foo.com/search?q="+url.QueryEscape(test.query), nil)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != test.statusCode {
+				t.Fatalf("expected status code %d got %d", test.statusCode, w.Code)
+			}
+
+			if test.statusCode != http.StatusOK {
+				return
+			}
+
+			var res struct {
+				Results []SearchResult `json:"results"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+
+			paths := []TaskIDPath{}
+			for _, result := range res.Results {
+				paths = append(paths, result.Path)
+			}
+
+			if !reflect.DeepEqual(test.paths, paths) {
+				t.Fatalf("expected paths %v got %v", test.paths, paths)
+			}
+		})
+	}
+}
+
+func TestTasksHandlerText(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+	handler := NewTasksHandler(service)
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo", Notes: "deploy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bar, err := service.Create([]TaskID{foo.ID}, CreateFields{Label: "Deploy app", Tags: []string{"ops"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		query string
+		paths []TaskIDPath
+	}{
+		"ranked": {
+			query: "q=depl",
+			paths: []TaskIDPath{TaskIDPath{foo.ID, bar.ID}, TaskIDPath{foo.ID}},
+		},
+		"filtered": {
+			query: "q=deploy&tag=ops",
+			paths: []TaskIDPath{TaskIDPath{foo.ID, bar.ID}},
+		},
+		"sorted": {
+			query: "q=deploy&sort=created_at",
+			paths: []TaskIDPath{TaskIDPath{foo.ID}, TaskIDPath{foo.ID, bar.ID}},
+		},
+		"empty": {
+			query: "q=",
+			paths: []TaskIDPath{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://foo.com/tasks?"+test.query, nil)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+			}
+
+			var res struct {
+				Tasks []TextMatch `json:"tasks"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+
+			paths := []TaskIDPath{}
+			for _, match := range res.Tasks {
+				paths = append(paths, match.Path)
+			}
+
+			if !reflect.DeepEqual(test.paths, paths) {
+				t.Fatalf("expected paths %v got %v", test.paths, paths)
+			}
+		})
+	}
+}
+
+func TestTagsHandler(t *testing.T) {
+	tests := map[string]struct {
+		method        string
+		res           string
+		resStatusCode int
+	}{
+		"GET /tags": {
+			method:        "GET",
+			res:           `{"tags":[{"tag":"backend","count":2},{"tag":"urgent","count":1}]}`,
+			resStatusCode: 200,
+		},
+		"POST /tags": {
+			method:        "POST",
+			res:           `{"error":"method not allowed"}`,
+			resStatusCode: 405,
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		r, err := http.NewRequest(tc.method, "http://foo.com/tags", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		service := &mockService{}
+		handler := NewTagsHandler(service)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if tc.resStatusCode != w.Code {
+			t.Fatalf("expected status code %d got %d", tc.resStatusCode, w.Code)
+		}
+
+		if tc.res != w.Body.String() {
+			t.Fatalf("expected response \n%s\n got \n%s\n", tc.res, w.Body.String())
+		}
+	}
+}
+
+func TestTaskHandlerETag(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+	handler := NewTaskHandler(service)
+
+	task, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := task.ETag()
+
+	do := func(method, ifMatch, ifNoneMatch, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, fmt.Sprintf("http://foo.com/tasks/%d", task.ID), strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	if w := do("GET", "", "", ""); w.Code != http.StatusOK || w.Header().Get("ETag") != etag {
+		t.Fatalf("expected status code %d and etag %s got %d and %s", http.StatusOK, etag, w.Code, w.Header().Get("ETag"))
+	}
+
+	if w := do("GET", "", fmt.Sprintf(`"foo", W/%s`, etag), ""); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("expected status code %d without body got %d and %q", http.StatusNotModified, w.Code, w.Body.String())
+	}
+
+	if w := do("PUT", `"foo"`, "", `{"label":"bar"}`); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected status code %d got %d", http.StatusPreconditionFailed, w.Code)
+	}
+
+	w := do("PUT", etag, "", `{"label":"bar"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+	}
+
+	newETag := w.Header().Get("ETag")
+	if newETag == etag {
+		t.Fatalf("expected new etag got %s", newETag)
+	}
+
+	// Sub task changes ETag of the parent.
+	if _, err := service.Create([]TaskID{task.ID}, CreateFields{Label: "baz"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := do("GET", "", newETag, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+	}
+
+	if w := do("DELETE", newETag, "", ""); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected status code %d got %d", http.StatusPreconditionFailed, w.Code)
+	}
+
+	if w := do("DELETE", "*", "", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d got %d", http.StatusOK, w.Code)
+	}
+
+	// Created root Task has ETag as created sub task.
+	r := httptest.NewRequest("POST", "http://foo.com/tasks", strings.NewReader(`{"label":"qux"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	NewTasksHandler(service).ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d got %d", http.StatusCreated, w.Code)
+	}
+
+	tasks, err := service.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || w.Header().Get("ETag") != tasks[0].ETag() {
+		t.Fatalf("expected etag of Task %v got %s", tasks, w.Header().Get("ETag"))
+	}
+}
+
+type mockService struct{}
+
+func (s *mockService) Create(path []TaskID, cf CreateFields) (Task, error) {
+	return Task{
+		ID:        TaskID(1),
+		Label:     "foo",
+		Completed: false,
+	}, nil
+}
+
+func (s *mockService) Find(path []TaskID) (Task, error) {
+	return Task{
+		ID:        TaskID(2),
+		Label:     "bar",
+		Completed: true,
+	}, nil
+}
+
+func (s *mockService) FindByID(taskID TaskID) (Task, []TaskID, error) {
+	return Task{
+		ID:        taskID,
+		Label:     "bar",
+		Completed: true,
+	}, []TaskID{TaskID(1), taskID}, nil
+}
+
+func (s *mockService) FindAll() ([]Task, error) {
+	return []Task{
+		Task{
+			ID:        TaskID(1),
+			Label:     "foo",
+			Completed: true,
+		},
+		Task{
+			ID:        TaskID(2),
+			Label:     "bar",
+			Completed: false,
+		},
+	}, nil
+}
+
+func (s *mockService) FindByText(query string, filter TaskFilter) ([]TextMatch, error) {
+	return []TextMatch{}, nil
+}
+
+func (s *mockService) Search(query SearchQuery) ([]SearchResult, error) {
+	return []SearchResult{}, nil
+}
+
+func (s *mockService) FindByFilter(filter TaskFilter) ([]TaskWithPath, error) {
+	dueAt := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	return []TaskWithPath{
+		TaskWithPath{
+			Path: TaskIDPath{TaskID(1), TaskID(3)},
+			Task: Task{
+				ID:        TaskID(3),
+				Label:     "baz",
+				Completed: false,
+				DueAt:     &dueAt,
+			},
+		},
+	}, nil
+}
+
+func (s *mockService) FindTags() ([]TagCount, error) {
+	return []TagCount{
+		TagCount{Tag: "backend", Count: 2},
+		TagCount{Tag: "urgent", Count: 1},
+	}, nil
+}
+
+func (s *mockService) Update(path []TaskID, uf UpdateFields) (Task, error) {
+	return Task{
+		ID:        TaskID(1),
+		Label:     *uf.Label,
+		Completed: false,
+	}, nil
+}
+
+func (s *mockService) Delete(path []TaskID, df DeleteFields) (Task, error) {
+	return Task{
+		ID:        TaskID(3),
+		Label:     "baz",
+		Completed: false,
+	}, nil
+}
+
+func (s *mockService) Clone(path []TaskID, toParent []TaskID, cf CloneFields) (Task, map[TaskID]TaskID, error) {
+	return Task{
+		ID:        TaskID(4),
+		Label:     "bar",
+		Completed: false,
+	}, map[TaskID]TaskID{path[len(path)-1]: TaskID(4)}, nil
+}
+
+func (s *mockService) Patch(path []TaskID, pf PatchFields) (Task, error) {
+	task := Task{
+		ID:        TaskID(1),
+		Label:     "foo",
+		Completed: false,
+	}
+
+	doc, err := taskDocument(&task)
+	if err != nil {
+		return Task{}, err
+	}
+
+	patchedDoc, err := applyPatch(pf.Type, doc, pf.Patch)
+	if err != nil {
+		return Task{}, err
+	}
+
+	taskDoc, err := parseTaskDocument(patchedDoc)
+	if err != nil {
+		return Task{}, err
+	}
+	task.Label = *taskDoc.Label
+
+	return task, nil
+}
+
+func (s *mockService) Reorder(path []TaskID, order []TaskID) (Task, error) {
+	if len(order) != 2 {
+		return Task{}, ErrTaskReorderNotValid
+	}
+
+	task := Task{
+		ID:        path[len(path)-1],
+		Label:     "foo",
+		Completed: false,
+		Children:  SubTasks{},
+	}
+	for i, taskID := range order {
+		task.Children[taskID] = &Task{
+			ID:       taskID,
+			Label:    map[TaskID]string{2: "bar", 3: "baz"}[taskID],
+			Position: i + 1,
+		}
+	}
+
+	return task, nil
+}
+
+func (s *mockService) FindDependencies(path []TaskID) (TaskDependencies, error) {
+	return TaskDependencies{}, nil
+}
+
+func (s *mockService) FindTrash() ([]TrashedTask, error) {
+	return []TrashedTask{}, nil
+}
+
+func (s *mockService) Restore(taskID TaskID) (Task, []TaskID, error) {
+	return Task{}, nil, ErrTrashedTaskNotFound
+}
+
+func (s *mockService) Purge(taskID TaskID) (TrashedTask, error) {
+	return TrashedTask{}, ErrTrashedTaskNotFound
+}
+
+func (s *mockService) FindHistory(path []TaskID) ([]HistoryEntry, error) {
+	return []HistoryEntry{}, nil
+}
+
+func (s *mockService) Revert(path []TaskID, version int) (Task, error) {
+	return Task{}, ErrHistoryVersionNotFound
+}
+
+func (s *mockService) Undo() ([]HistoryEntry, error) {
+	return nil, ErrHistoryNothingToUndo
+}
+
+func (s *mockService) Batch(operations []BatchOperation) ([]BatchResult, error) {
+	return nil, &BatchError{Index: 0, Err: ErrTaskNotFound}
+}
+
+func (s *mockService) WithActor(actor string) TaskService {
+	return s
+}
+
+func (s *mockService) BulkUpdate(bulk BulkFields, fields UpdateFields) ([]BulkResult, error) {
+	return []BulkResult{}, nil
+}
+
+func (s *mockService) BulkDelete(bulk BulkFields) ([]BulkResult, error) {
+	return []BulkResult{}, nil
+}
+
+func (s *mockService) Move(from []TaskID, toParent []TaskID) (Task, error) {
+	if len(toParent) > 0 && toParent[0] == from[len(from)-1] {
+		return Task{}, ErrTaskMoveNotValid
+	}
+
+	return Task{
+		ID:        from[len(from)-1],
+		Label:     "bar",
 		Completed: false,
 	}, nil
 }
@@ -0,0 +1,533 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrHistoryVersionNotFound
+	ErrHistoryVersionNotFound error = errors.New("Task version not found")
+	// ErrHistoryNothingToUndo
+	ErrHistoryNothingToUndo error = errors.New("There is no operation to undo")
+)
+
+// Operations of TaskService which are recorded in history.
+const (
+	OperationCreate  = "create"
+	OperationUpdate  = "update"
+	OperationPatch   = "patch"
+	OperationDelete  = "delete"
+	OperationMove    = "move"
+	OperationClone   = "clone"
+	OperationReorder = "reorder"
+	OperationRestore = "restore"
+	OperationPurge   = "purge"
+	OperationRevert  = "revert"
+	OperationUndo    = "undo"
+)
+
+// Changes of the Tasks in TaskStorage which are recorded in history.
+const (
+	HistoryActionInsert  = "insert"
+	HistoryActionUpdate  = "update"
+	HistoryActionDelete  = "delete"
+	HistoryActionMove    = "move"
+	HistoryActionTrash   = "trash"
+	HistoryActionUntrash = "untrash"
+)
+
+// HistoryEntry is single change of the Task made by operation of
+// TaskService. One operation may change more Tasks (eg. completion is
+// propagated), all its entries have the same Operation number.
+type HistoryEntry struct {
+	// Operation is sequence number of the operation.
+	Operation int `json:"operation"`
+	// Op is name of the operation.
+	Op string `json:"op"`
+	// Reverts is sequence number of the operation reverted by undo.
+	Reverts int `json:"reverts,omitempty"`
+	// Action is kind of the change.
+	Action string `json:"action"`
+	// TaskID is TaskID of changed Task.
+	TaskID TaskID `json:"task_id,string"`
+	// Version is sequence number of the change of the Task.
+	Version int `json:"version"`
+	// Actor is who made the change (empty if it's not known).
+	Actor string `json:"actor,omitempty"`
+	// Path is TaskID path of the Task before the change (after the change
+	// for insert).
+	Path TaskIDPath `json:"path"`
+	// Target is TaskID path of the new parent (move only).
+	Target TaskIDPath `json:"target,omitempty"`
+	// Before is the Task before the change. Deleted Task has its children.
+	Before *Task `json:"before,omitempty"`
+	// After is the Task after the change. Inserted Task has its children.
+	After *Task `json:"after,omitempty"`
+	// Trashed is Task inserted into or removed from trash.
+	Trashed *TrashedTask `json:"trashed,omitempty"`
+	// At is time of the change.
+	At time.Time `json:"at"`
+}
+
+// clone returns deep copy of the HistoryEntry.
+func (e *HistoryEntry) clone() *HistoryEntry {
+	entry := *e
+	if e.Before != nil {
+		entry.Before = e.Before.clone()
+	}
+	if e.After != nil {
+		entry.After = e.After.clone()
+	}
+	if e.Trashed != nil {
+		entry.Trashed = e.Trashed.clone()
+	}
+
+	return &entry
+}
+
+// historyStorage is TaskStorage which records changes of Tasks made by
+// operation of TaskStorageService. Changes are recorded only between begin and
+// end which are called under the service lock.
+type historyStorage struct {
+	TaskStorage
+
+	// recording is true while operation is in progress.
+	recording bool
+	// changes are changes recorded since begin.
+	changes []*HistoryEntry
+	// lastOperation is sequence number of the latest recorded operation,
+	// negative until it's loaded from history.
+	lastOperation int
+}
+
+// begin starts recording of changes.
+func (s *historyStorage) begin() {
+	s.recording = true
+	s.changes = nil
+}
+
+// end stops recording and returns recorded changes.
+func (s *historyStorage) end() []*HistoryEntry {
+	changes := s.changes
+	s.recording = false
+	s.changes = nil
+
+	return changes
+}
+
+// recorded returns number of changes recorded since begin.
+func (s *historyStorage) recorded() int {
+	return len(s.changes)
+}
+
+// Insert stores new Task and records the change.
+// Insert implements TaskStorage interface.
+func (s *historyStorage) Insert(path []TaskID, task *Task) error {
+	if err := s.TaskStorage.Insert(path, task); err != nil {
+		return err
+	}
+
+	if s.recording {
+		s.changes = append(s.changes, &HistoryEntry{
+			Action: HistoryActionInsert,
+			TaskID: task.ID,
+			Path:   childPath(path, task.ID),
+			After:  task.clone(),
+		})
+	}
+
+	return nil
+}
+
+// Update updates Task and records the change.
+// Update implements TaskStorage interface.
+func (s *historyStorage) Update(path []TaskID, task *Task) error {
+	if !s.recording {
+		return s.TaskStorage.Update(path, task)
+	}
+
+	before, err := s.TaskStorage.Find(path)
+	if err != nil {
+		return err
+	}
+
+	if err := s.TaskStorage.Update(path, task); err != nil {
+		return err
+	}
+
+	s.changes = append(s.changes, &HistoryEntry{
+		Action: HistoryActionUpdate,
+		TaskID: task.ID,
+		Path:   append(TaskIDPath{}, path...),
+		Before: before.withChildren(nil),
+		After:  task.withChildren(nil),
+	})
+
+	return nil
+}
+
+// Delete removes Task and records the change.
+// Delete implements TaskStorage interface.
+func (s *historyStorage) Delete(path []TaskID) error {
+	if !s.recording {
+		return s.TaskStorage.Delete(path)
+	}
+
+	before, err := s.TaskStorage.Find(path)
+	if err != nil {
+		return err
+	}
+
+	if err := s.TaskStorage.Delete(path); err != nil {
+		return err
+	}
+
+	s.changes = append(s.changes, &HistoryEntry{
+		Action: HistoryActionDelete,
+		TaskID: before.ID,
+		Path:   append(TaskIDPath{}, path...),
+		Before: &before,
+	})
+
+	return nil
+}
+
+// Move moves Task and records the change.
+// Move implements TaskStorage interface.
+func (s *historyStorage) Move(from []TaskID, toParent []TaskID) error {
+	if !s.recording {
+		return s.TaskStorage.Move(from, toParent)
+	}
+
+	task, err := s.TaskStorage.Find(from)
+	if err != nil {
+		return err
+	}
+
+	if err := s.TaskStorage.Move(from, toParent); err != nil {
+		return err
+	}
+
+	s.changes = append(s.changes, &HistoryEntry{
+		Action: HistoryActionMove,
+		TaskID: task.ID,
+		Path:   append(TaskIDPath{}, from...),
+		Target: append(TaskIDPath{}, toParent...),
+		Before: task.withChildren(nil),
+		After:  task.withChildren(nil),
+	})
+
+	return nil
+}
+
+// InsertTrash stores Task in trash and records the change.
+// InsertTrash implements TaskStorage interface.
+func (s *historyStorage) InsertTrash(trashed *TrashedTask) error {
+	if err := s.TaskStorage.InsertTrash(trashed); err != nil {
+		return err
+	}
+
+	if s.recording {
+		s.changes = append(s.changes, &HistoryEntry{
+			Action:  HistoryActionTrash,
+			TaskID:  trashed.Task.ID,
+			Path:    append(TaskIDPath{}, trashed.Path...),
+			Trashed: trashed.clone(),
+		})
+	}
+
+	return nil
+}
+
+// DeleteTrash removes Task from trash and records the change.
+// DeleteTrash implements TaskStorage interface.
+func (s *historyStorage) DeleteTrash(taskID TaskID) error {
+	if !s.recording {
+		return s.TaskStorage.DeleteTrash(taskID)
+	}
+
+	trashed, err := s.TaskStorage.FindTrash(taskID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.TaskStorage.DeleteTrash(taskID); err != nil {
+		return err
+	}
+
+	s.changes = append(s.changes, &HistoryEntry{
+		Action:  HistoryActionUntrash,
+		TaskID:  taskID,
+		Path:    append(TaskIDPath{}, trashed.Path...),
+		Trashed: &trashed,
+	})
+
+	return nil
+}
+
+// operation starts recording of changes made by operation with given name and
+// returns function which stores the changes in history. It must be called
+// under the lock, eg. defer s.operation(OperationUpdate)().
+func (s *TaskStorageService) operation(op string) func() {
+	s.history.begin()
+
+	return func() {
+		s.commit(op, 0)
+	}
+}
+
+// transaction runs function in storage transaction and stores its changes in
+// history as one operation with given name (reverting given operation for
+// undo) and returns them. If the function fails, all its changes are rolled
+// back so operation is never applied partially. Caller must hold the lock.
+func (s *TaskStorageService) transaction(op string, reverts int, fn func() error) ([]HistoryEntry, error) {
+	if err := s.storage.Begin(); err != nil {
+		fmt.Printf("(DEBUG) service: Starting transaction of %s failed: %s\n", op, err)
+		return nil, err
+	}
+	s.history.begin()
+
+	if err := fn(); err != nil {
+		s.history.end()
+		if err := s.storage.Rollback(); err != nil {
+			fmt.Printf("(WARN) service: Rolling back %s failed: %s\n", op, err)
+		}
+		return nil, err
+	}
+
+	// History is stored in the same transaction so it's rolled back
+	// together with the changes.
+	entries := s.commit(op, reverts)
+	if err := s.storage.Commit(); err != nil {
+		fmt.Printf("(WARN) service: Committing %s failed: %s\n", op, err)
+		s.history.lastOperation = -1
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// commit stores changes recorded since the operation started in history as
+// new operation and returns them. Failure of the history doesn't fail the
+// operation, the changes are already stored.
+func (s *TaskStorageService) commit(op string, reverts int) []HistoryEntry {
+	changes := s.history.end()
+	if len(changes) == 0 {
+		return []HistoryEntry{}
+	}
+
+	if s.history.lastOperation < 0 {
+		history, err := s.storage.FindAllHistory()
+		if err != nil {
+			fmt.Printf("(WARN) service: Loading history failed: %s\n", err)
+			return []HistoryEntry{}
+		}
+
+		s.history.lastOperation = 0
+		if len(history) > 0 {
+			s.history.lastOperation = history[len(history)-1].Operation
+		}
+	}
+	s.history.lastOperation++
+
+	now := s.clock.Now().UTC()
+	entries := []HistoryEntry{}
+	for _, change := range changes {
+		history, err := s.storage.FindHistory(change.TaskID)
+		if err != nil {
+			fmt.Printf("(WARN) service: Recording history of Task %d failed: %s\n", change.TaskID, err)
+			continue
+		}
+
+		change.Operation = s.history.lastOperation
+		change.Op = op
+		change.Reverts = reverts
+		change.Actor = s.actor
+		change.Version = len(history) + 1
+		change.At = now
+		if err := s.storage.InsertHistory(change); err != nil {
+			fmt.Printf("(WARN) service: Recording history of Task %d failed: %s\n", change.TaskID, err)
+			continue
+		}
+		entries = append(entries, *change)
+	}
+
+	return entries
+}
+
+// FindHistory returns all changes of the Task at given TaskID path ordered by
+// version.
+// FindHistory implements TaskService interface.
+func (s *TaskStorageService) FindHistory(path []TaskID) ([]HistoryEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	task, err := s.storage.Find(path)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Finding Task history failed: %s\n", err)
+		return nil, err
+	}
+
+	return s.storage.FindHistory(task.ID)
+}
+
+// Revert changes fields of the Task at given TaskID path to the values they
+// had after the change with given version. Position of the Task, its parent
+// and its children are not changed and links to Tasks which are not in the
+// tree anymore are removed. Reverted Task is validated and stored as update
+// (its status must be changed in the workflow, completion is propagated and
+// completed recurring Task gets next occurrence).
+// Revert implements TaskService interface.
+func (s *TaskStorageService) Revert(path []TaskID, version int) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var task Task
+	_, err := s.transaction(OperationRevert, 0, func() error {
+		var err error
+		task, err = s.revertTask(path, version)
+		return err
+	})
+
+	return task, err
+}
+
+// revertTask reverts Task at given TaskID path to given version. Caller must
+// hold the lock and apply it in transaction.
+func (s *TaskStorageService) revertTask(path []TaskID, version int) (Task, error) {
+	task, err := s.storage.Find(path)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Reverting Task failed: %s\n", err)
+		return Task{}, err
+	}
+
+	history, err := s.storage.FindHistory(task.ID)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Reverting Task failed: %s\n", err)
+		return Task{}, err
+	}
+
+	var target *Task
+	for _, entry := range history {
+		if entry.Version == version && entry.After != nil {
+			target = entry.After
+		}
+	}
+	if target == nil {
+		fmt.Printf("(DEBUG) service: Reverting Task failed. Version %d not found.\n", version)
+		return task, ErrHistoryVersionNotFound
+	}
+
+	tasks, err := s.storage.FindAll()
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Reverting Task failed: %s\n", err)
+		return Task{}, err
+	}
+	g := newDependencyGraph(tasks)
+
+	revertedTask := target.withChildren(nil)
+	revertedTask.computedProgress = nil
+	revertedTask.Position = task.Position
+	revertedTask.CreatedAt = task.CreatedAt
+	revertedTask.CompletedAt = task.CompletedAt
+	blockers := []TaskID{}
+	for _, blocker := range revertedTask.BlockedBy {
+		if g.contains(blocker) {
+			blockers = append(blockers, blocker)
+		}
+	}
+	revertedTask.BlockedBy = normalizeTaskIDs(blockers)
+
+	// Status must be changed in the workflow as by update.
+	if !s.workflow.allowed(task.status(), revertedTask.status()) {
+		fmt.Printf("(DEBUG) service: Reverting Task failed. Status %s can't be changed to %s.\n", task.status(), revertedTask.status())
+		return task, ErrTaskStatusTransitionNotValid
+	}
+	revertedTask.setStatus(revertedTask.status())
+
+	return s.storeUpdate(path, &task, revertedTask)
+}
+
+// Undo reverts all changes of the latest operation which was not undone yet
+// and returns changes made by the undo. Undo itself can't be undone.
+// Undo implements TaskService interface.
+func (s *TaskStorageService) Undo() ([]HistoryEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history, err := s.storage.FindAllHistory()
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Undoing operation failed: %s\n", err)
+		return nil, err
+	}
+
+	undone := map[int]bool{}
+	for _, entry := range history {
+		if entry.Op == OperationUndo {
+			undone[entry.Reverts] = true
+		}
+	}
+
+	operation := 0
+	for i := len(history) - 1; i >= 0 && operation == 0; i-- {
+		if history[i].Op != OperationUndo && !undone[history[i].Operation] {
+			operation = history[i].Operation
+		}
+	}
+	if operation == 0 {
+		fmt.Println("(DEBUG) service: Undoing operation failed. Nothing to undo.")
+		return nil, ErrHistoryNothingToUndo
+	}
+
+	// Undo is applied in transaction so failed undo doesn't leave the
+	// operation undone partially.
+	return s.transaction(OperationUndo, operation, func() error {
+		for i := len(history) - 1; i >= 0; i-- {
+			if history[i].Operation != operation {
+				continue
+			}
+
+			if err := s.undoChange(&history[i]); err != nil {
+				fmt.Printf("(DEBUG) service: Undoing operation %d failed: %s\n", operation, err)
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// undoChange applies inverse of the change on the storage.
+func (s *TaskStorageService) undoChange(entry *HistoryEntry) error {
+	path := []TaskID(entry.Path)
+
+	switch entry.Action {
+	case HistoryActionInsert:
+		return s.storage.Delete(path)
+	case HistoryActionUpdate:
+		// Fields are reverted but it's new revision of the Task.
+		task, err := s.storage.Find(path)
+		if err != nil {
+			return err
+		}
+		revertedTask := entry.Before.withChildren(nil)
+		revertedTask.Revision = task.Revision + 1
+		return s.storage.Update(path, revertedTask)
+	case HistoryActionDelete:
+		return s.storage.Insert(path[:len(path)-1], entry.Before)
+	case HistoryActionMove:
+		return s.storage.Move(childPath(entry.Target, entry.TaskID), path[:len(path)-1])
+	case HistoryActionTrash:
+		// Trashed Task may be purged already.
+		if err := s.storage.DeleteTrash(entry.TaskID); err != ErrTrashedTaskNotFound {
+			return err
+		}
+		return nil
+	case HistoryActionUntrash:
+		return s.storage.InsertTrash(entry.Trashed)
+	}
+
+	return fmt.Errorf("unknown history action %q", entry.Action)
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // MethodNotAllowed is simple util function which writes MethodNotAllowed
@@ -28,27 +29,28 @@ func options(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Add("Access-Control-Allow-Origin", origin)
-	w.Header().Add("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-	w.Header().Add("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding")
+	w.Header().Add("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE")
+	w.Header().Add("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, If-Match, If-None-Match")
+	w.Header().Add("Access-Control-Expose-Headers", "ETag, Location")
+	w.Header().Add("Accept-Patch", "application/merge-patch+json, application/json-patch+json")
 }
 
 // ParseTaskIDPath parses request URL and returns slice of TaskIDs or error
-// if url is not valid.
+// if url is not valid. Action at the end of the URL is not part of the path.
 func parseTaskIDPath(r *http.Request) ([]TaskID, error) {
 	taskIDs := []TaskID{}
 
-	parts := []string{}
-	for _, part := range strings.Split(r.URL.Path, "/") {
-		if len(part) > 0 {
-			parts = append(parts, part)
-		}
-	}
+	parts := urlParts(r)
 
 	// it must have at least 1 part "/tasks"
 	if len(parts) < 1 {
 		return nil, ErrHandlerURLNotValid
 	}
 
+	if parseTaskAction(r) != "" {
+		parts = parts[:len(parts)-1]
+	}
+
 	// Tasks endpoint - skip first 2 values for part "/tasks/"
 	for _, value := range parts[1:] {
 		if len(value) > 0 {
@@ -65,14 +67,235 @@ func parseTaskIDPath(r *http.Request) ([]TaskID, error) {
 	return taskIDs, nil
 }
 
+// ParseTaskAction parses request URL and returns name of the action on Task
+// (eg. "move" for "/tasks/1/2/move") or empty string if URL has no action.
+// Action can follow only after at least one TaskID.
+func parseTaskAction(r *http.Request) string {
+	parts := urlParts(r)
+	if len(parts) < 3 {
+		return ""
+	}
+
+	last := parts[len(parts)-1]
+	if _, err := strconv.Atoi(last); err == nil {
+		return ""
+	}
+
+	return last
+}
+
+// TaskURL returns URL path of Task with given TaskID path.
+func taskURL(path []TaskID) string {
+	url := "/tasks"
+	for _, taskID := range path {
+		url += "/" + strconv.Itoa(int(taskID))
+	}
+
+	return url
+}
+
+// UrlParts returns non empty parts of request URL path.
+func urlParts(r *http.Request) []string {
+	parts := []string{}
+	for _, part := range strings.Split(r.URL.Path, "/") {
+		if len(part) > 0 {
+			parts = append(parts, part)
+		}
+	}
+
+	return parts
+}
+
+// ParseTaskID parses request URL and returns TaskID from the last part of the
+// URL or error if url is not valid.
+func parseTaskID(r *http.Request) (TaskID, error) {
+	parts := strings.Split(strings.TrimRight(r.URL.Path, "/"), "/")
+
+	value := parts[len(parts)-1]
+	val, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("(DEBUG) http: parsing TaskID on value %q failed: %s\n", value, err)
+		return TaskID(0), ErrHandlerURLNotValid
+	}
+
+	return TaskID(val), nil
+}
+
+// ParseTaskFilter parses request URL query and returns TaskFilter. Supported
+// query parameters are overdue (boolean), due_before (RFC 3339 time) and tag
+// (may be repeated).
+func parseTaskFilter(r *http.Request) (TaskFilter, error) {
+	query := r.URL.Query()
+	filter := TaskFilter{}
+
+	if value := query.Get("overdue"); value != "" {
+		overdue, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Printf("(DEBUG) http: parsing overdue on value %q failed: %s\n", value, err)
+			return TaskFilter{}, ErrHandlerQueryNotValid
+		}
+		filter.Overdue = overdue
+	}
+
+	if value := query.Get("due_before"); value != "" {
+		dueBefore, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			log.Printf("(DEBUG) http: parsing due_before on value %q failed: %s\n", value, err)
+			return TaskFilter{}, ErrHandlerQueryNotValid
+		}
+		filter.DueBefore = &dueBefore
+	}
+
+	if tags := query["tag"]; len(tags) > 0 {
+		for _, tag := range tags {
+			if !validTag(tag) {
+				log.Printf("(DEBUG) http: parsing tag on value %q failed\n", tag)
+				return TaskFilter{}, ErrHandlerQueryNotValid
+			}
+		}
+		filter.Tags = tags
+	}
+
+	return filter, nil
+}
+
+// ParseTaskOrder parses sort parameter of request URL query and returns
+// TaskOrder. Value is the sort field with optional "-" prefix for descending
+// order.
+func parseTaskOrder(r *http.Request) (TaskOrder, error) {
+	value := r.URL.Query().Get("sort")
+	if value == "" {
+		return TaskOrder{}, nil
+	}
+
+	order := TaskOrder{
+		Field: strings.TrimPrefix(value, "-"),
+		Desc:  strings.HasPrefix(value, "-"),
+	}
+
+	if !validSortField(order.Field) {
+		log.Printf("(DEBUG) http: parsing sort on value %q failed\n", value)
+		return TaskOrder{}, ErrHandlerQueryNotValid
+	}
+
+	return order, nil
+}
+
+// ParseTaskPage parses limit and cursor parameters of request URL query and
+// returns TaskPage. Limit must be between 1 and maxPageLimit.
+func parseTaskPage(r *http.Request) (TaskPage, error) {
+	query := r.URL.Query()
+	page := TaskPage{Cursor: query.Get("cursor")}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			log.Printf("(DEBUG) http: parsing limit on value %q failed\n", value)
+			return TaskPage{}, ErrHandlerQueryNotValid
+		}
+		page.Limit = limit
+	}
+
+	return page, nil
+}
+
+// ParseTaskDepth parses depth parameter of request URL query. It returns -1
+// (all sub tasks) if the parameter is not set.
+func parseTaskDepth(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("depth")
+	if value == "" {
+		return -1, nil
+	}
+
+	depth, err := strconv.Atoi(value)
+	if err != nil || depth < 0 {
+		log.Printf("(DEBUG) http: parsing depth on value %q failed\n", value)
+		return 0, ErrHandlerQueryNotValid
+	}
+
+	return depth, nil
+}
+
+// ParseTaskFields parses fields parameter of request URL query and returns
+// TaskFields. It returns nil (all fields) if the parameter is not set.
+func parseTaskFields(r *http.Request) (TaskFields, error) {
+	value := r.URL.Query().Get("fields")
+	if value == "" {
+		return nil, nil
+	}
+
+	fields, err := ParseTaskFields(value)
+	if err != nil {
+		log.Printf("(DEBUG) http: parsing fields on value %q failed: %s\n", value, err)
+		return nil, ErrHandlerQueryNotValid
+	}
+
+	return fields, nil
+}
+
+// ParseActor returns actor of the request recorded in history of changes:
+// value of X-Actor header or user name of basic authentication. It returns
+// empty string if actor is not known.
+func parseActor(r *http.Request) string {
+	if actor := strings.TrimSpace(r.Header.Get("X-Actor")); actor != "" {
+		return actor
+	}
+
+	if user, _, ok := r.BasicAuth(); ok {
+		return user
+	}
+
+	return ""
+}
+
+// ParseETags parses entity tags from If-Match header value. It returns nil if
+// header is not set or contains "*" because then any entity tag matches.
+func parseETags(header string) []string {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil
+	}
+
+	etags := []string{}
+	for _, value := range strings.Split(header, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			etags = append(etags, value)
+		}
+	}
+
+	return etags
+}
+
+// MatchETagWeak returns true if given entity tag matches any entity tag in
+// If-None-Match header value. Weak comparison is used so weak entity tags
+// (prefixed with W/) match too.
+func matchETagWeak(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	for _, value := range parseETags(header) {
+		if strings.TrimPrefix(value, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
 var (
 	// ErrBadMediaType is returned when request contains not supported
 	// Content-Type.
 	ErrBadMediaType error = errors.New("Bad media type")
+	// ErrHandlerQueryNotValid is returned when URL query contains filter
+	// values which are not valid.
+	ErrHandlerQueryNotValid error = errors.New("URL query parameters are not valid")
 )
 
-// ParseBody parses a request body into an interface. Currently it supports
-// only application/json Content-Type.
+// ParseBody parses a request body into an interface. It supports
+// application/json Content-Type for all requests and
+// application/merge-patch+json and application/json-patch+json Content-Types
+// for JSONTaskPatch.
 func parseBody(r *http.Request, v interface{}) error {
 	contentType := r.Header.Get("Content-Type")
 	mediaType, _, err := mime.ParseMediaType(contentType)
@@ -81,8 +304,15 @@ func parseBody(r *http.Request, v interface{}) error {
 		return ErrBadMediaType
 	}
 
+	patch, isPatch := v.(*JSONTaskPatch)
+
 	switch mediaType {
 	case "application/json":
+		if isPatch {
+			log.Printf("(DEBUG) http: unsupported Content-Type %q for patch\n", mediaType)
+			return ErrBadMediaType
+		}
+
 		dec := json.NewDecoder(r.Body)
 		for {
 			if err := dec.Decode(v); err == io.EOF {
@@ -94,12 +324,34 @@ func parseBody(r *http.Request, v interface{}) error {
 		}
 		return nil
 
+	case "application/merge-patch+json", "application/json-patch+json":
+		if !isPatch {
+			log.Printf("(DEBUG) http: unsupported Content-Type %q\n", mediaType)
+			return ErrBadMediaType
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Printf("(DEBUG) http: reading request failed: %s\n", err)
+			return err
+		}
+
+		if !json.Valid(body) {
+			log.Printf("(DEBUG) http: parsing patch request failed: not valid JSON\n")
+			return ErrTaskPatchNotValid
+		}
+
+		patch.Type = PatchTypeMerge
+		if mediaType == "application/json-patch+json" {
+			patch.Type = PatchTypeJSON
+		}
+		patch.Patch = body
+		return nil
+
 	default:
 		log.Printf("(DEBUG) http: unsupported Content-Type %q\n", mediaType)
 		return ErrBadMediaType
 	}
-
-	return nil
 }
 
 // JSONError is wrapper struct for errors thrown in business logic and returned
@@ -120,6 +372,27 @@ func ResponseOK(w http.ResponseWriter, v interface{}) {
 	ResponseAsJSON(w, http.StatusOK, v)
 }
 
+// ResponseHTML is simple util function which returns given HTML fragment as
+// payload with status code OK (200). Browser is not allowed to run any script
+// or load any resource from the fragment.
+func ResponseHTML(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(body)); err != nil {
+		log.Printf("(WARN) http: writting HTML payload failed: %s", err)
+	}
+}
+
+// ResponseNotModified is simple util function which returns status code Not
+// Modified (304) with given entity tag and without payload.
+func ResponseNotModified(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusNotModified)
+}
+
 // ResponseCreated is simple util function which returns given struct as JSON
 // paylaod with status code Created (201) and set's location header.
 func ResponseCreated(w http.ResponseWriter, url string, v interface{}) {
@@ -28,6 +28,13 @@ func TestParseTaskIDPath(t *testing.T) {
 				TaskID(2),
 			},
 		},
+		"/tasks/1/2/move": {
+			path: "http://foo.com/tasks/1/2/move",
+			res: []TaskID{
+				TaskID(1),
+				TaskID(2),
+			},
+		},
 		"/tasks/kekeke": {
 			path: "http://foo.com/tasks/kekeke",
 			err:  ErrHandlerURLNotValid,
@@ -70,3 +77,79 @@ func TestParseTaskIDPath(t *testing.T) {
 		}
 	}
 }
+
+func TestParseTaskAction(t *testing.T) {
+	tests := map[string]struct {
+		path string
+		res  string
+	}{
+		"/tasks": {
+			path: "http://foo.com/tasks",
+		},
+		"/tasks/kekeke": {
+			path: "http://foo.com/tasks/kekeke",
+		},
+		"/tasks/1/2": {
+			path: "http://foo.com/tasks/1/2",
+		},
+		"/tasks/1/move": {
+			path: "http://foo.com/tasks/1/move",
+			res:  "move",
+		},
+		"/tasks/1/2/move/": {
+			path: "http://foo.com/tasks/1/2/move/",
+			res:  "move",
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		r, err := http.NewRequest("GET", tc.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res := parseTaskAction(r); res != tc.res {
+			t.Fatalf("expected action %q got %q", tc.res, res)
+		}
+	}
+}
+
+func TestParseActor(t *testing.T) {
+	tests := map[string]struct {
+		header string
+		user   string
+		res    string
+	}{
+		"unknown": {},
+		"header": {
+			header: " alice ",
+			user:   "bob",
+			res:    "alice",
+		},
+		"basic auth": {
+			user: "bob",
+			res:  "bob",
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		r, err := http.NewRequest("POST", "http://foo.com/tasks", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.header != "" {
+			r.Header.Set("X-Actor", tc.header)
+		}
+		if tc.user != "" {
+			r.SetBasicAuth(tc.user, "secret")
+		}
+
+		if res := parseActor(r); res != tc.res {
+			t.Fatalf("expected actor %q got %q", tc.res, res)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package tasks
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Markdown renderer supports only small subset of Markdown which is enough for
+// Task notes: headings, paragraphs, lists, block quotes, fenced code blocks,
+// code spans, strong and emphasis text and links. Raw HTML is not supported,
+// every character of the source is escaped before it is rendered so the output
+// contains only HTML tags produced by the renderer.
+var (
+	markdownHeading     = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*$`)
+	markdownUnordered   = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	markdownOrdered     = regexp.MustCompile(`^\d+[.)]\s+(.*)$`)
+	markdownQuote       = regexp.MustCompile(`^>\s?(.*)$`)
+	markdownLink        = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	markdownStrong      = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	markdownEmphasis    = regexp.MustCompile(`\*([^*]+)\*`)
+	markdownLinkSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+)
+
+// markdownRenderer holds state of block which is being rendered. Block is
+// rendered when block of another kind starts or at the end of the source.
+type markdownRenderer struct {
+	out strings.Builder
+	// kind is HTML element of current block (p, ul, ol or blockquote) or
+	// empty string if there is no current block.
+	kind string
+	// lines are lines of current paragraph or block quote or list items.
+	lines []string
+}
+
+// renderMarkdown renders Markdown source as sanitized HTML fragment.
+func renderMarkdown(src string) string {
+	r := &markdownRenderer{}
+
+	var code []string
+	inCode := false
+	for _, line := range strings.Split(strings.Replace(src, "\r\n", "\n", -1), "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if inCode {
+			if strings.HasPrefix(trimmed, "```") {
+				r.code(code)
+				code, inCode = nil, false
+				continue
+			}
+			code = append(code, line)
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "```") {
+			r.flush()
+			inCode = true
+			continue
+		}
+
+		if trimmed == "" {
+			r.flush()
+			continue
+		}
+
+		if m := markdownHeading.FindStringSubmatch(trimmed); m != nil {
+			r.flush()
+			fmt.Fprintf(&r.out, "<h%d>%s</h%d>\n", len(m[1]), renderMarkdownInline(m[2]), len(m[1]))
+			continue
+		}
+
+		if m := markdownUnordered.FindStringSubmatch(trimmed); m != nil {
+			r.add("ul", m[1])
+			continue
+		}
+
+		if m := markdownOrdered.FindStringSubmatch(trimmed); m != nil {
+			r.add("ol", m[1])
+			continue
+		}
+
+		if m := markdownQuote.FindStringSubmatch(trimmed); m != nil {
+			r.add("blockquote", m[1])
+			continue
+		}
+
+		r.add("p", trimmed)
+	}
+
+	// Code block which is not closed ends with the source.
+	if inCode {
+		r.code(code)
+	}
+	r.flush()
+
+	return r.out.String()
+}
+
+// add adds line to the block of given kind. Current block is rendered first if
+// it's of another kind.
+func (r *markdownRenderer) add(kind, line string) {
+	if r.kind != kind {
+		r.flush()
+		r.kind = kind
+	}
+	r.lines = append(r.lines, line)
+}
+
+// flush renders current block.
+func (r *markdownRenderer) flush() {
+	switch r.kind {
+	case "p":
+		fmt.Fprintf(&r.out, "<p>%s</p>\n", renderMarkdownInline(strings.Join(r.lines, "\n")))
+	case "blockquote":
+		fmt.Fprintf(&r.out, "<blockquote><p>%s</p></blockquote>\n", renderMarkdownInline(strings.Join(r.lines, "\n")))
+	case "ul", "ol":
+		fmt.Fprintf(&r.out, "<%s>\n", r.kind)
+		for _, line := range r.lines {
+			fmt.Fprintf(&r.out, "<li>%s</li>\n", renderMarkdownInline(line))
+		}
+		fmt.Fprintf(&r.out, "</%s>\n", r.kind)
+	}
+
+	r.kind = ""
+	r.lines = nil
+}
+
+// code renders code block with given lines.
+func (r *markdownRenderer) code(lines []string) {
+	fmt.Fprintf(&r.out, "<pre><code>%s</code></pre>\n", html.EscapeString(strings.Join(lines, "\n")))
+}
+
+// renderMarkdownInline renders inline Markdown of the text. Text in code spans
+// is only escaped.
+func renderMarkdownInline(text string) string {
+	parts := strings.Split(text, "`")
+	// Backtick without its pair is not a code span.
+	if len(parts)%2 == 0 {
+		parts[len(parts)-2] += "`" + parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+
+	var b strings.Builder
+	for i, part := range parts {
+		if i%2 == 1 {
+			fmt.Fprintf(&b, "<code>%s</code>", html.EscapeString(part))
+			continue
+		}
+
+		escaped := html.EscapeString(part)
+		escaped = markdownLink.ReplaceAllStringFunc(escaped, renderMarkdownLink)
+		escaped = markdownStrong.ReplaceAllString(escaped, "<strong>$1</strong>")
+		escaped = markdownEmphasis.ReplaceAllString(escaped, "<em>$1</em>")
+		b.WriteString(escaped)
+	}
+
+	return b.String()
+}
+
+// renderMarkdownLink renders escaped Markdown link. Links with other than
+// allowed schemes are rendered as text only.
+func renderMarkdownLink(link string) string {
+	m := markdownLink.FindStringSubmatch(link)
+	text, href := m[1], m[2]
+
+	u, err := url.Parse(html.UnescapeString(href))
+	if err != nil || !markdownLinkSchemes[strings.ToLower(u.Scheme)] {
+		return text
+	}
+
+	// Asterisks would be rendered as emphasis inside of the attribute.
+	href = strings.Replace(href, "*", "%2A", -1)
+
+	return fmt.Sprintf(`<a href="%s" rel="nofollow noopener">%s</a>`, href, text)
+}
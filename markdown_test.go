@@ -0,0 +1,67 @@
+package tasks
+
+import "testing"
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		html string
+	}{
+		"empty": {
+			src:  "",
+			html: "",
+		},
+		"paragraphs": {
+			src:  "foo\nbar\n\nbaz",
+			html: "<p>foo\nbar</p>\n<p>baz</p>\n",
+		},
+		"heading": {
+			src:  "## Foo ##\nbar",
+			html: "<h2>Foo</h2>\n<p>bar</p>\n",
+		},
+		"lists": {
+			src:  "- foo\n* bar\n1. baz\n2) qux",
+			html: "<ul>\n<li>foo</li>\n<li>bar</li>\n</ul>\n<ol>\n<li>baz</li>\n<li>qux</li>\n</ol>\n",
+		},
+		"quote": {
+			src:  "> foo\n> bar",
+			html: "<blockquote><p>foo\nbar</p></blockquote>\n",
+		},
+		"code block": {
+			src:  "```go\nif a < b {\n```\nfoo",
+			html: "<pre><code>if a &lt; b {</code></pre>\n<p>foo</p>\n",
+		},
+		"code block not closed": {
+			src:  "```\n**foo**",
+			html: "<pre><code>**foo**</code></pre>\n",
+		},
+		"inline": {
+			src:  "**foo** *bar* `*baz*` qux`",
+			html: "<p><strong>foo</strong> <em>bar</em> <code>*baz*</code> qux`</p>\n",
+		},
+		"link": {
+			src:  "[foo](https://example.com/?a=1&b=*2*)",
+			html: "<p><a href=\"https://example.com/?a=1&amp;b=%2A2%2A\" rel=\"nofollow noopener\">foo</a></p>\n",
+		},
+		"html": {
+			src:  "<script>alert(\"foo\")</script>",
+			html: "<p>&lt;script&gt;alert(&#34;foo&#34;)&lt;/script&gt;</p>\n",
+		},
+		"javascript link": {
+			src:  "[foo](javascript:alert(1))",
+			html: "<p>foo)</p>\n",
+		},
+		"attribute injection": {
+			src:  "[foo](https://example.com/\"onmouseover=\"alert(1))",
+			html: "<p><a href=\"https://example.com/&#34;onmouseover=&#34;alert(1\" rel=\"nofollow noopener\">foo</a>)</p>\n",
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		if res := renderMarkdown(tc.src); tc.html != res {
+			t.Fatalf("expected html \n%q\n got \n%q\n", tc.html, res)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package tasks
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Fields Tasks can be sorted by.
+const (
+	SortByCreatedAt   = "created_at"
+	SortByUpdatedAt   = "updated_at"
+	SortByCompletedAt = "completed_at"
+	SortByID          = "id"
+	SortByLabel       = "label"
+	SortByPriority    = "priority"
+)
+
+// TaskOrder defines how Tasks are sorted. Zero value of TaskOrder keeps
+// default order ByPosition.
+type TaskOrder struct {
+	// Field is one of the sort fields.
+	Field string
+	// Desc sorts Tasks in descending order.
+	Desc bool
+}
+
+// validSortField returns true if Tasks can be sorted by given field.
+func validSortField(field string) bool {
+	switch field {
+	case SortByCreatedAt, SortByUpdatedAt, SortByCompletedAt, SortByID, SortByLabel, SortByPriority:
+		return true
+	}
+
+	return false
+}
+
+// IsEmpty returns true if no sort field is set.
+func (o TaskOrder) IsEmpty() bool {
+	return o.Field == ""
+}
+
+// less returns true if Task a is sorted before Task b. Tasks without value
+// of the sort field are always sorted last. Labels are compared case
+// insensitively.
+func (o TaskOrder) less(a, b *Task) bool {
+	var at, bt *time.Time
+	switch o.Field {
+	case SortByID:
+		return o.Desc != (a.ID < b.ID) && a.ID != b.ID
+	case SortByLabel:
+		al, bl := strings.ToLower(a.Label), strings.ToLower(b.Label)
+		return o.Desc != (al < bl) && al != bl
+	case SortByPriority:
+		return o.Desc != (a.Priority < b.Priority) && a.Priority != b.Priority
+	case SortByCreatedAt:
+		at, bt = a.CreatedAt, b.CreatedAt
+	case SortByUpdatedAt:
+		at, bt = a.UpdatedAt, b.UpdatedAt
+	case SortByCompletedAt:
+		at, bt = a.CompletedAt, b.CompletedAt
+	default:
+		return false
+	}
+
+	if at == nil || bt == nil {
+		return at != nil
+	}
+
+	if o.Desc {
+		return at.After(*bt)
+	}
+
+	return at.Before(*bt)
+}
+
+// sortTasks sorts Tasks by the order. Tasks with equal values keep their
+// order.
+func sortTasks(tasks []Task, order TaskOrder) {
+	sort.SliceStable(tasks, func(i, j int) bool { return order.less(&tasks[i], &tasks[j]) })
+}
+
+// sortTasksWithPath sorts Tasks with their TaskID paths by the order. Tasks
+// with equal values keep their order.
+func sortTasksWithPath(tasks []TaskWithPath, order TaskOrder) {
+	sort.SliceStable(tasks, func(i, j int) bool { return order.less(&tasks[i].Task, &tasks[j].Task) })
+}
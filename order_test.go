@@ -0,0 +1,72 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSortTasks(t *testing.T) {
+	first := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	tasks := []Task{
+		Task{ID: TaskID(1), Label: "foo", CreatedAt: &second, UpdatedAt: &second},
+		Task{ID: TaskID(2), Label: "Baz", Priority: PriorityHigh, CreatedAt: &first, UpdatedAt: &second, CompletedAt: &second},
+		Task{ID: TaskID(3), Label: "bar", Priority: PriorityLow, CreatedAt: &second, UpdatedAt: &first, CompletedAt: &first},
+	}
+
+	tests := map[string]struct {
+		order TaskOrder
+		res   []TaskID
+	}{
+		"empty": {
+			order: TaskOrder{},
+			res:   []TaskID{TaskID(1), TaskID(2), TaskID(3)},
+		},
+		"created_at": {
+			order: TaskOrder{Field: SortByCreatedAt},
+			res:   []TaskID{TaskID(2), TaskID(1), TaskID(3)},
+		},
+		"-updated_at": {
+			order: TaskOrder{Field: SortByUpdatedAt, Desc: true},
+			res:   []TaskID{TaskID(1), TaskID(2), TaskID(3)},
+		},
+		"completed_at": {
+			order: TaskOrder{Field: SortByCompletedAt},
+			res:   []TaskID{TaskID(3), TaskID(2), TaskID(1)},
+		},
+		"-completed_at": {
+			order: TaskOrder{Field: SortByCompletedAt, Desc: true},
+			res:   []TaskID{TaskID(2), TaskID(3), TaskID(1)},
+		},
+		"-id": {
+			order: TaskOrder{Field: SortByID, Desc: true},
+			res:   []TaskID{TaskID(3), TaskID(2), TaskID(1)},
+		},
+		"label": {
+			order: TaskOrder{Field: SortByLabel},
+			res:   []TaskID{TaskID(3), TaskID(2), TaskID(1)},
+		},
+		"-priority": {
+			order: TaskOrder{Field: SortByPriority, Desc: true},
+			res:   []TaskID{TaskID(2), TaskID(3), TaskID(1)},
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		sorted := append([]Task{}, tasks...)
+		sortTasks(sorted, tc.order)
+
+		res := []TaskID{}
+		for _, task := range sorted {
+			res = append(res, task.ID)
+		}
+
+		if !reflect.DeepEqual(tc.res, res) {
+			t.Fatalf("expected order %v got %v", tc.res, res)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package tasks
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrTaskCursorNotValid
+	ErrTaskCursorNotValid error = errors.New("Task page cursor is not valid")
+	// ErrTaskPageNotSupported
+	ErrTaskPageNotSupported error = errors.New("Task page is not supported for filtered or searched Tasks")
+)
+
+// Page limits.
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// TaskPage defines which page of sorted Tasks is returned. Zero value of
+// TaskPage returns all Tasks.
+type TaskPage struct {
+	// Limit is maximal number of returned Tasks.
+	Limit int
+	// Cursor is opaque position after which the page starts. It's given with
+	// the previous page.
+	Cursor string
+}
+
+// IsEmpty returns true if neither limit nor cursor is set.
+func (p TaskPage) IsEmpty() bool {
+	return p.Limit == 0 && p.Cursor == ""
+}
+
+// taskCursor holds the order and the fields of the last Task of the page
+// which the order depends on. Page stays consistent when Tasks are added or
+// removed between requests because the next page starts after the Task's
+// position in the order and not after a number of Tasks.
+type taskCursor struct {
+	Sort        string     `json:"s,omitempty"`
+	ID          TaskID     `json:"i"`
+	Label       string     `json:"l,omitempty"`
+	Priority    int        `json:"p,omitempty"`
+	Position    int        `json:"o,omitempty"`
+	CreatedAt   *time.Time `json:"c,omitempty"`
+	UpdatedAt   *time.Time `json:"u,omitempty"`
+	CompletedAt *time.Time `json:"d,omitempty"`
+}
+
+// sortKey returns value of sort parameter of the order.
+func (o TaskOrder) sortKey() string {
+	if o.Desc {
+		return "-" + o.Field
+	}
+
+	return o.Field
+}
+
+// encodeCursor returns cursor pointing after given Task in given order.
+func encodeCursor(task *Task, order TaskOrder) string {
+	b, _ := json.Marshal(taskCursor{
+		Sort:        order.sortKey(),
+		ID:          task.ID,
+		Label:       task.Label,
+		Priority:    task.Priority,
+		Position:    task.Position,
+		CreatedAt:   task.CreatedAt,
+		UpdatedAt:   task.UpdatedAt,
+		CompletedAt: task.CompletedAt,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor returns Task with fields stored in the cursor. Cursor must be
+// created for the same order.
+func decodeCursor(value string, order TaskOrder) (*Task, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrTaskCursorNotValid
+	}
+
+	var cursor taskCursor
+	if err := json.Unmarshal(b, &cursor); err != nil || cursor.Sort != order.sortKey() {
+		return nil, ErrTaskCursorNotValid
+	}
+
+	return &Task{
+		ID:          cursor.ID,
+		Label:       cursor.Label,
+		Priority:    cursor.Priority,
+		Position:    cursor.Position,
+		CreatedAt:   cursor.CreatedAt,
+		UpdatedAt:   cursor.UpdatedAt,
+		CompletedAt: cursor.CompletedAt,
+	}, nil
+}
+
+// before returns true if Task a is before Task b in Tasks sorted ByPosition
+// and then by the order.
+func (o TaskOrder) before(a, b *Task) bool {
+	if o.less(a, b) {
+		return true
+	}
+	if o.less(b, a) {
+		return false
+	}
+
+	return ByPosition([]Task{*a, *b}).Less(0, 1)
+}
+
+// paginateTasks returns page of Tasks sorted ByPosition and then by the order
+// and cursor of the next page (empty if it's the last page).
+func paginateTasks(tasks []Task, order TaskOrder, page TaskPage) ([]Task, string, error) {
+	if page.IsEmpty() {
+		return tasks, "", nil
+	}
+
+	limit := page.Limit
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+
+	start := 0
+	if page.Cursor != "" {
+		last, err := decodeCursor(page.Cursor, order)
+		if err != nil {
+			fmt.Printf("(DEBUG) page: Decoding cursor %q failed.\n", page.Cursor)
+			return nil, "", err
+		}
+
+		start = sort.Search(len(tasks), func(i int) bool { return order.before(last, &tasks[i]) })
+	}
+
+	end := start + limit
+	if end >= len(tasks) {
+		return tasks[start:], "", nil
+	}
+
+	return tasks[start:end], encodeCursor(&tasks[end-1], order), nil
+}
+
+// withDepth returns copy of the Task with sub tasks up to given depth (0 for
+// no sub tasks, negative for all sub tasks). Tasks which lost their sub tasks
+// keep progress computed from them and number of their direct sub tasks.
+func (t *Task) withDepth(depth int) *Task {
+	if depth < 0 {
+		return t
+	}
+
+	if depth == 0 {
+		task := t.withProgress()
+		count := len(t.Children)
+		task.subTaskCount = &count
+
+		return task
+	}
+
+	var children SubTasks
+	if t.Children != nil {
+		children = make(SubTasks, len(t.Children))
+		for id, child := range t.Children {
+			children[id] = child.withDepth(depth - 1)
+		}
+	}
+
+	task := t.withChildren(children)
+	task.computedProgress = t.progress()
+
+	return task
+}
+
+// tasksWithDepth returns copies of given Tasks with sub tasks up to given
+// depth.
+func tasksWithDepth(tasks []Task, depth int) []Task {
+	if depth < 0 {
+		return tasks
+	}
+
+	result := make([]Task, len(tasks))
+	for i := range tasks {
+		result[i] = *tasks[i].withDepth(depth)
+	}
+
+	return result
+}
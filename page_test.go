@@ -0,0 +1,134 @@
+package tasks
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPaginateTasks(t *testing.T) {
+	tasks := []Task{
+		Task{ID: TaskID(1), Label: "foo", Priority: PriorityLow},
+		Task{ID: TaskID(2), Label: "bar"},
+		Task{ID: TaskID(3), Label: "Baz", Priority: PriorityHigh},
+		Task{ID: TaskID(4), Label: "qux", Priority: PriorityLow},
+		Task{ID: TaskID(5), Label: "bar"},
+	}
+
+	// pages returns TaskIDs of all pages of the tasks. Task 6 is added
+	// after the first page.
+	pages := func(order TaskOrder, limit int) [][]TaskID {
+		sorted := append([]Task{}, tasks...)
+		sort.Sort(ByPosition(sorted))
+		sortTasks(sorted, order)
+
+		result := [][]TaskID{}
+		page := TaskPage{Limit: limit}
+		for {
+			res, cursor, err := paginateTasks(sorted, order, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ids := []TaskID{}
+			for _, task := range res {
+				ids = append(ids, task.ID)
+			}
+			result = append(result, ids)
+
+			if cursor == "" {
+				return result
+			}
+			page.Cursor = cursor
+
+			if len(result) == 1 {
+				sorted = append(sorted, Task{ID: TaskID(6), Label: "a", Priority: PriorityHigh})
+				sort.Sort(ByPosition(sorted))
+				sortTasks(sorted, order)
+			}
+		}
+	}
+
+	tests := map[string]struct {
+		order TaskOrder
+		limit int
+		res   [][]TaskID
+	}{
+		"position": {
+			order: TaskOrder{},
+			limit: 2,
+			res:   [][]TaskID{{3, 1}, {4, 2}, {5}},
+		},
+		"label": {
+			order: TaskOrder{Field: SortByLabel},
+			limit: 2,
+			res:   [][]TaskID{{2, 5}, {3, 1}, {4}},
+		},
+		"-priority": {
+			order: TaskOrder{Field: SortByPriority, Desc: true},
+			limit: 3,
+			res:   [][]TaskID{{3, 1, 4}, {2, 5}},
+		},
+		"-id": {
+			order: TaskOrder{Field: SortByID, Desc: true},
+			limit: 5,
+			res:   [][]TaskID{{5, 4, 3, 2, 1}},
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		if res := pages(tc.order, tc.limit); !reflect.DeepEqual(tc.res, res) {
+			t.Fatalf("expected pages %v got %v", tc.res, res)
+		}
+	}
+
+	cursor := encodeCursor(&tasks[0], TaskOrder{Field: SortByLabel})
+	if _, _, err := paginateTasks(tasks, TaskOrder{}, TaskPage{Cursor: cursor}); err != ErrTaskCursorNotValid {
+		t.Fatalf("expected error %v got %v", ErrTaskCursorNotValid, err)
+	}
+}
+
+func TestTaskWithDepth(t *testing.T) {
+	task := &Task{
+		ID:    TaskID(1),
+		Label: "foo",
+		Children: SubTasks{
+			TaskID(2): &Task{
+				ID:        TaskID(2),
+				Label:     "bar",
+				Completed: true,
+				Children: SubTasks{
+					TaskID(3): &Task{ID: TaskID(3), Label: "baz", Completed: true},
+					TaskID(4): &Task{ID: TaskID(4), Label: "qux"},
+				},
+			},
+		},
+	}
+
+	tests := map[int]string{
+		-1: `{"id":"1","label":"foo","completed":false,"sub_tasks":[{"id":"2","label":"bar","completed":true,"sub_tasks":[{"id":"3","label":"baz","completed":true},{"id":"4","label":"qux","completed":false}],"progress":{"done":1,"total":2}}],"progress":{"done":2,"total":3}}`,
+		0:  `{"id":"1","label":"foo","completed":false,"progress":{"done":2,"total":3},"sub_task_count":1}`,
+		1:  `{"id":"1","label":"foo","completed":false,"sub_tasks":[{"id":"2","label":"bar","completed":true,"progress":{"done":1,"total":2},"sub_task_count":2}],"progress":{"done":2,"total":3}}`,
+		2:  `{"id":"1","label":"foo","completed":false,"sub_tasks":[{"id":"2","label":"bar","completed":true,"sub_tasks":[{"id":"3","label":"baz","completed":true,"sub_task_count":0},{"id":"4","label":"qux","completed":false,"sub_task_count":0}],"progress":{"done":1,"total":2}}],"progress":{"done":2,"total":3}}`,
+	}
+
+	for depth, expected := range tests {
+		t.Log(depth)
+
+		b, err := json.Marshal(task.withDepth(depth))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != expected {
+			t.Fatalf("expected %s got %s", expected, b)
+		}
+	}
+
+	if len(task.Children[TaskID(2)].Children) != 2 {
+		t.Fatal("expected original Task to keep its sub tasks")
+	}
+}
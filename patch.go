@@ -0,0 +1,452 @@
+package tasks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchType is type of the patch document applied on Task.
+type PatchType string
+
+const (
+	// PatchTypeMerge is JSON Merge Patch (RFC 7396).
+	PatchTypeMerge PatchType = "merge"
+	// PatchTypeJSON is JSON Patch (RFC 6902).
+	PatchTypeJSON PatchType = "json"
+)
+
+// jsonPatchOperation is single operation of JSON Patch document.
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// applyPatch applies patch of given type on JSON document and returns patched
+// document.
+func applyPatch(patchType PatchType, doc interface{}, patch []byte) (interface{}, error) {
+	switch patchType {
+	case PatchTypeMerge:
+		return applyMergePatch(doc, patch)
+	case PatchTypeJSON:
+		return applyJSONPatch(doc, patch)
+	default:
+		fmt.Printf("(DEBUG) patch: Unknown patch type %q\n", patchType)
+		return nil, ErrTaskPatchNotValid
+	}
+}
+
+// applyMergePatch applies JSON Merge Patch on JSON document.
+func applyMergePatch(doc interface{}, patch []byte) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal(patch, &value); err != nil {
+		fmt.Printf("(DEBUG) patch: Unmarshaling merge patch failed: %s\n", err)
+		return nil, ErrTaskPatchNotValid
+	}
+
+	return mergePatch(doc, value), nil
+}
+
+// mergePatch merges patch value into target value as described in RFC 7396.
+// Objects are merged recursively, null removes the member and any other
+// value replaces the target.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergePatch(targetObject[name], value)
+	}
+
+	return targetObject
+}
+
+// applyJSONPatch applies JSON Patch operations on JSON document in order.
+// When any operation fails the whole patch fails.
+func applyJSONPatch(doc interface{}, patch []byte) (interface{}, error) {
+	operations := []jsonPatchOperation{}
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		fmt.Printf("(DEBUG) patch: Unmarshaling JSON patch failed: %s\n", err)
+		return nil, ErrTaskPatchNotValid
+	}
+
+	for _, operation := range operations {
+		var err error
+		if doc, err = applyJSONPatchOperation(doc, operation); err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+// applyJSONPatchOperation applies single JSON Patch operation on document.
+func applyJSONPatchOperation(doc interface{}, operation jsonPatchOperation) (interface{}, error) {
+	path, err := parseJSONPointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operation.Op {
+	case "add":
+		value, err := operation.value()
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+
+	case "remove":
+		doc, _, err := removeValue(doc, path)
+		return doc, err
+
+	case "replace":
+		value, err := operation.value()
+		if err != nil {
+			return nil, err
+		}
+		if doc, _, err = removeValue(doc, path); err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+
+	case "move", "copy":
+		from, err := parseJSONPointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+
+		var value interface{}
+		if operation.Op == "move" {
+			if isJSONPointerPrefix(from, path) && len(from) < len(path) {
+				fmt.Println("(DEBUG) patch: JSON patch move into its own child.")
+				return nil, ErrTaskPatchNotValid
+			}
+			if doc, value, err = removeValue(doc, from); err != nil {
+				return nil, err
+			}
+		} else {
+			if value, err = getValue(doc, from); err != nil {
+				return nil, err
+			}
+			value = copyValue(value)
+		}
+		return addValue(doc, path, value)
+
+	case "test":
+		value, err := operation.value()
+		if err != nil {
+			return nil, err
+		}
+		current, err := getValue(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			fmt.Printf("(DEBUG) patch: JSON patch test on path %q failed.\n", operation.Path)
+			return nil, ErrTaskPatchTestFailed
+		}
+		return doc, nil
+
+	default:
+		fmt.Printf("(DEBUG) patch: Unknown JSON patch operation %q\n", operation.Op)
+		return nil, ErrTaskPatchNotValid
+	}
+}
+
+// value returns decoded value of the operation. Value is required for add,
+// replace and test operations.
+func (o jsonPatchOperation) value() (interface{}, error) {
+	if len(o.Value) == 0 {
+		fmt.Printf("(DEBUG) patch: JSON patch operation %q without value.\n", o.Op)
+		return nil, ErrTaskPatchNotValid
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(o.Value, &value); err != nil {
+		return nil, ErrTaskPatchNotValid
+	}
+
+	return value, nil
+}
+
+// parseJSONPointer parses JSON Pointer (RFC 6901) into reference tokens.
+// Empty pointer references the whole document.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		fmt.Printf("(DEBUG) patch: JSON pointer %q is not valid.\n", pointer)
+		return nil, ErrTaskPatchNotValid
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+
+	return tokens, nil
+}
+
+// isJSONPointerPrefix returns true if prefix tokens are prefix of tokens.
+func isJSONPointerPrefix(prefix, tokens []string) bool {
+	if len(prefix) > len(tokens) {
+		return false
+	}
+
+	for i := range prefix {
+		if prefix[i] != tokens[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getValue returns value referenced by tokens in document.
+func getValue(doc interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		var err error
+		if doc, err = childValue(doc, token); err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+// childValue returns member of object or item of array referenced by token.
+func childValue(doc interface{}, token string) (interface{}, error) {
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		value, found := node[token]
+		if !found {
+			fmt.Printf("(DEBUG) patch: JSON pointer member %q not found.\n", token)
+			return nil, ErrTaskPatchNotValid
+		}
+		return value, nil
+
+	case []interface{}:
+		i, err := arrayIndex(token, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		return node[i], nil
+
+	default:
+		fmt.Printf("(DEBUG) patch: JSON pointer token %q references scalar value.\n", token)
+		return nil, ErrTaskPatchNotValid
+	}
+}
+
+// addValue adds value into document at location referenced by tokens and
+// returns the document. Value is inserted into arrays and set in objects.
+func addValue(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	return modifyValue(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+
+		case []interface{}:
+			i := len(node)
+			if token != "-" {
+				var err error
+				if i, err = arrayIndex(token, len(node)); err != nil {
+					return nil, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+
+		default:
+			fmt.Printf("(DEBUG) patch: JSON pointer token %q references scalar value.\n", token)
+			return nil, ErrTaskPatchNotValid
+		}
+	})
+}
+
+// removeValue removes value at location referenced by tokens from document.
+// It returns the document and the removed value.
+func removeValue(doc interface{}, tokens []string) (interface{}, interface{}, error) {
+	if len(tokens) == 0 {
+		fmt.Println("(DEBUG) patch: Removing whole document is not allowed.")
+		return nil, nil, ErrTaskPatchNotValid
+	}
+
+	var removed interface{}
+	doc, err := modifyValue(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		var err error
+		if removed, err = childValue(parent, token); err != nil {
+			return nil, err
+		}
+
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			delete(node, token)
+			return node, nil
+
+		default:
+			array := parent.([]interface{})
+			i, _ := arrayIndex(token, len(array)-1)
+			return append(array[:i:i], array[i+1:]...), nil
+		}
+	})
+
+	return doc, removed, err
+}
+
+// modifyValue walks document to the parent of the location referenced by
+// tokens and calls modify with the parent and the last token. Modified parent
+// is stored back so the arrays can change their length.
+func modifyValue(doc interface{}, tokens []string, modify func(interface{}, string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return modify(doc, tokens[0])
+	}
+
+	child, err := childValue(doc, tokens[0])
+	if err != nil {
+		return nil, err
+	}
+
+	newChild, err := modifyValue(child, tokens[1:], modify)
+	if err != nil {
+		return nil, err
+	}
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		node[tokens[0]] = newChild
+	case []interface{}:
+		i, _ := arrayIndex(tokens[0], len(node)-1)
+		node[i] = newChild
+	}
+
+	return doc, nil
+}
+
+// arrayIndex parses array index from token. Index must be between zero and
+// max (including).
+func arrayIndex(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		fmt.Printf("(DEBUG) patch: JSON pointer array index %q is not valid.\n", token)
+		return 0, ErrTaskPatchNotValid
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max {
+		fmt.Printf("(DEBUG) patch: JSON pointer array index %q is not valid.\n", token)
+		return 0, ErrTaskPatchNotValid
+	}
+
+	return i, nil
+}
+
+// copyValue returns deep copy of JSON value.
+func copyValue(value interface{}) interface{} {
+	switch node := value.(type) {
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(node))
+		for name, member := range node {
+			object[name] = copyValue(member)
+		}
+		return object
+
+	case []interface{}:
+		array := make([]interface{}, len(node))
+		for i, item := range node {
+			array[i] = copyValue(item)
+		}
+		return array
+
+	default:
+		return value
+	}
+}
+
+// taskDocument returns JSON document of the Task which patches are applied
+// on. Sub tasks in the document are ordered by TaskID so JSON Patch array
+// indexes are stable.
+func taskDocument(task *Task) (interface{}, error) {
+	b, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	sortDocumentSubTasks(doc)
+
+	return doc, nil
+}
+
+// sortDocumentSubTasks orders sub tasks in Task document by TaskID.
+func sortDocumentSubTasks(doc interface{}) {
+	object, ok := doc.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	subTasks, ok := object["sub_tasks"].([]interface{})
+	if !ok {
+		return
+	}
+
+	documentID := func(i int) int {
+		subTask, _ := subTasks[i].(map[string]interface{})
+		id, _ := subTask["id"].(string)
+		val, _ := strconv.Atoi(id)
+		return val
+	}
+	sort.Slice(subTasks, func(i, j int) bool { return documentID(i) < documentID(j) })
+
+	for _, subTask := range subTasks {
+		sortDocumentSubTasks(subTask)
+	}
+}
+
+// parseTaskDocument converts patched JSON document into JSONTaskDocument.
+// Unknown fields are not allowed.
+func parseTaskDocument(doc interface{}) (*JSONTaskDocument, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	var taskDocument JSONTaskDocument
+	if err := dec.Decode(&taskDocument); err != nil {
+		fmt.Printf("(DEBUG) patch: Patched document is not valid Task: %s\n", err)
+		return nil, ErrTaskPatchNotValid
+	}
+
+	return &taskDocument, nil
+}
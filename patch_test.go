@@ -0,0 +1,199 @@
+package tasks
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := map[string]struct {
+		doc   string
+		patch string
+		res   string
+		err   error
+	}{
+		"replace member": {
+			doc:   `{"a":"b"}`,
+			patch: `{"a":"c"}`,
+			res:   `{"a":"c"}`,
+		},
+		"add member": {
+			doc:   `{"a":"b"}`,
+			patch: `{"b":"c"}`,
+			res:   `{"a":"b","b":"c"}`,
+		},
+		"remove member": {
+			doc:   `{"a":"b","b":"c"}`,
+			patch: `{"a":null}`,
+			res:   `{"b":"c"}`,
+		},
+		"replace array": {
+			doc:   `{"a":["b"]}`,
+			patch: `{"a":["c","d"]}`,
+			res:   `{"a":["c","d"]}`,
+		},
+		"nested object": {
+			doc:   `{"e":null,"a":{"b":"c","d":"e"}}`,
+			patch: `{"a":{"b":null,"f":{"g":null}}}`,
+			res:   `{"e":null,"a":{"d":"e","f":{}}}`,
+		},
+		"replace document": {
+			doc:   `{"a":"b"}`,
+			patch: `["c"]`,
+			res:   `["c"]`,
+		},
+		"not valid": {
+			doc:   `{"a":"b"}`,
+			patch: `{"a"`,
+			err:   ErrTaskPatchNotValid,
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		testPatch(t, PatchTypeMerge, tc.doc, tc.patch, tc.res, tc.err)
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	tests := map[string]struct {
+		doc   string
+		patch string
+		res   string
+		err   error
+	}{
+		"add member": {
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux"}]`,
+			res:   `{"foo":"bar","baz":"qux"}`,
+		},
+		"add array item": {
+			doc:   `{"foo":["bar","baz"]}`,
+			patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			res:   `{"foo":["bar","qux","baz"]}`,
+		},
+		"add array end": {
+			doc:   `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/-","value":{"a":1}}]`,
+			res:   `{"foo":["bar",{"a":1}]}`,
+		},
+		"add array out of bounds": {
+			doc:   `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/2","value":"qux"}]`,
+			err:   ErrTaskPatchNotValid,
+		},
+		"add without value": {
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz"}]`,
+			err:   ErrTaskPatchNotValid,
+		},
+		"remove member": {
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"remove","path":"/baz"}]`,
+			res:   `{"foo":"bar"}`,
+		},
+		"remove array item": {
+			doc:   `{"foo":["bar","qux","baz"]}`,
+			patch: `[{"op":"remove","path":"/foo/1"}]`,
+			res:   `{"foo":["bar","baz"]}`,
+		},
+		"remove missing": {
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"remove","path":"/baz"}]`,
+			err:   ErrTaskPatchNotValid,
+		},
+		"replace member": {
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			res:   `{"baz":"boo","foo":"bar"}`,
+		},
+		"replace nested array item": {
+			doc:   `{"a":[{"b":["c","d"]}]}`,
+			patch: `[{"op":"replace","path":"/a/0/b/1","value":"e"}]`,
+			res:   `{"a":[{"b":["c","e"]}]}`,
+		},
+		"move member": {
+			doc:   `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			res:   `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		"move array item": {
+			doc:   `{"foo":["all","grass","cows","eat"]}`,
+			patch: `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			res:   `{"foo":["all","cows","eat","grass"]}`,
+		},
+		"move into child": {
+			doc:   `{"foo":{"bar":{}}}`,
+			patch: `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`,
+			err:   ErrTaskPatchNotValid,
+		},
+		"copy member": {
+			doc:   `{"foo":{"bar":"baz"}}`,
+			patch: `[{"op":"copy","from":"/foo","path":"/qux"},{"op":"add","path":"/qux/bar","value":"x"}]`,
+			res:   `{"foo":{"bar":"baz"},"qux":{"bar":"x"}}`,
+		},
+		"test pass": {
+			doc:   `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			res:   `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		"test fail": {
+			doc:   `{"baz":"qux"}`,
+			patch: `[{"op":"test","path":"/baz","value":"bar"}]`,
+			err:   ErrTaskPatchTestFailed,
+		},
+		"escaped pointer": {
+			doc:   `{"a/b":1,"m~n":2}`,
+			patch: `[{"op":"remove","path":"/a~1b"},{"op":"replace","path":"/m~0n","value":3}]`,
+			res:   `{"m~n":3}`,
+		},
+		"leading zero index": {
+			doc:   `{"foo":["a","b"]}`,
+			patch: `[{"op":"remove","path":"/foo/01"}]`,
+			err:   ErrTaskPatchNotValid,
+		},
+		"unknown operation": {
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"kekeke","path":"/foo"}]`,
+			err:   ErrTaskPatchNotValid,
+		},
+		"not valid pointer": {
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"remove","path":"foo"}]`,
+			err:   ErrTaskPatchNotValid,
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		testPatch(t, PatchTypeJSON, tc.doc, tc.patch, tc.res, tc.err)
+	}
+}
+
+func testPatch(t *testing.T, patchType PatchType, doc, patch, res string, expErr error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(doc), &value); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := applyPatch(patchType, value, []byte(patch))
+	if err != expErr {
+		t.Fatalf("expected err %s got %s", expErr, err)
+	}
+
+	if err != nil {
+		return
+	}
+
+	var expValue interface{}
+	if err := json.Unmarshal([]byte(res), &expValue); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(expValue, value) {
+		t.Fatalf("expected document %v got %v", expValue, value)
+	}
+}
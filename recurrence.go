@@ -0,0 +1,293 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrTaskRecurrenceIsNotValid
+	ErrTaskRecurrenceIsNotValid error = errors.New("Task field Recurrence is not valid")
+)
+
+// Recurrence frequencies.
+const (
+	FrequencyDaily   = "DAILY"
+	FrequencyWeekly  = "WEEKLY"
+	FrequencyMonthly = "MONTHLY"
+)
+
+// maxRecurrenceInterval is maximal interval of the recurrence rule.
+const maxRecurrenceInterval = 1000
+
+// recurrenceDays are RRULE names of week days. Weeks start on Monday.
+var recurrenceDays = []struct {
+	name string
+	day  time.Weekday
+}{
+	{"MO", time.Monday},
+	{"TU", time.Tuesday},
+	{"WE", time.Wednesday},
+	{"TH", time.Thursday},
+	{"FR", time.Friday},
+	{"SA", time.Saturday},
+	{"SU", time.Sunday},
+}
+
+// recurrence is parsed recurrence rule. It supports subset of iCalendar
+// RRULE: FREQ (DAILY, WEEKLY or MONTHLY), INTERVAL and BYDAY with week days
+// (only for DAILY and WEEKLY).
+type recurrence struct {
+	freq     string
+	interval int
+	byDay    map[time.Weekday]bool
+}
+
+// parseRecurrence parses recurrence rule (eg. "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH").
+// Rule is case insensitive and may start with "RRULE:".
+func parseRecurrence(value string) (recurrence, error) {
+	value = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(value)), "RRULE:")
+	r := recurrence{interval: 1, byDay: map[time.Weekday]bool{}}
+
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || seen[kv[0]] {
+			fmt.Printf("(DEBUG) recurrence: Parsing recurrence failed. Part %q is not valid.\n", part)
+			return recurrence{}, ErrTaskRecurrenceIsNotValid
+		}
+		seen[kv[0]] = true
+
+		switch kv[0] {
+		case "FREQ":
+			switch kv[1] {
+			case FrequencyDaily, FrequencyWeekly, FrequencyMonthly:
+				r.freq = kv[1]
+			default:
+				fmt.Printf("(DEBUG) recurrence: Parsing recurrence failed. Unknown frequency %q.\n", kv[1])
+				return recurrence{}, ErrTaskRecurrenceIsNotValid
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(kv[1])
+			if err != nil || interval < 1 || interval > maxRecurrenceInterval {
+				fmt.Printf("(DEBUG) recurrence: Parsing recurrence failed. Interval %q is not valid.\n", kv[1])
+				return recurrence{}, ErrTaskRecurrenceIsNotValid
+			}
+			r.interval = interval
+		case "BYDAY":
+			for _, name := range strings.Split(kv[1], ",") {
+				day, found := recurrenceDay(name)
+				if !found {
+					fmt.Printf("(DEBUG) recurrence: Parsing recurrence failed. Unknown day %q.\n", name)
+					return recurrence{}, ErrTaskRecurrenceIsNotValid
+				}
+				r.byDay[day] = true
+			}
+		default:
+			fmt.Printf("(DEBUG) recurrence: Parsing recurrence failed. Unknown part %q.\n", kv[0])
+			return recurrence{}, ErrTaskRecurrenceIsNotValid
+		}
+	}
+
+	if r.freq == "" || (r.freq == FrequencyMonthly && len(r.byDay) > 0) {
+		fmt.Println("(DEBUG) recurrence: Parsing recurrence failed. Frequency is missing or can't have days.")
+		return recurrence{}, ErrTaskRecurrenceIsNotValid
+	}
+
+	return r, nil
+}
+
+// recurrenceDay returns week day with given RRULE name.
+func recurrenceDay(name string) (time.Weekday, bool) {
+	for _, d := range recurrenceDays {
+		if d.name == name {
+			return d.day, true
+		}
+	}
+
+	return time.Sunday, false
+}
+
+// String returns normalized recurrence rule. Interval 1 is omitted and days
+// are ordered from Monday.
+func (r recurrence) String() string {
+	parts := []string{"FREQ=" + r.freq}
+	if r.interval != 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.interval))
+	}
+
+	days := []string{}
+	for _, d := range recurrenceDays {
+		if r.byDay[d.day] {
+			days = append(days, d.name)
+		}
+	}
+	if len(days) > 0 {
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// next returns time of the next occurrence after given time or false if there
+// is no next occurrence (eg. daily rule with interval 7 on other day than
+// days of the rule). Rule has no start date (DTSTART), given time is the
+// anchor of the interval.
+func (r recurrence) next(t time.Time) (time.Time, bool) {
+	switch r.freq {
+	case FrequencyDaily:
+		// Week days repeat after 7 intervals at most.
+		for i := 1; i <= 7; i++ {
+			next := t.AddDate(0, 0, i*r.interval)
+			if len(r.byDay) == 0 || r.byDay[next.Weekday()] {
+				return next, true
+			}
+		}
+	case FrequencyWeekly:
+		if len(r.byDay) == 0 {
+			return t.AddDate(0, 0, 7*r.interval), true
+		}
+
+		// Next day of the rule is in the same week or in the week after
+		// interval. Weeks are counted from the given occurrence, not from
+		// the first one, so if it's moved to other week (eg. its due date
+		// is changed) the following occurrences are counted from the new
+		// week.
+		for i := 1; i <= 7*r.interval; i++ {
+			next := t.AddDate(0, 0, i)
+			if r.byDay[next.Weekday()] && (week(next)-week(t))%r.interval == 0 {
+				return next, true
+			}
+		}
+	case FrequencyMonthly:
+		return addMonths(t, r.interval), true
+	}
+
+	return time.Time{}, false
+}
+
+// week returns number of the week (starting on Monday) of given time since
+// Unix epoch.
+func week(t time.Time) int {
+	y, m, d := t.Date()
+	days := int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+
+	// Unix epoch is on Thursday.
+	return (days + 3) / 7
+}
+
+// addMonths adds months to given time. Day of the month is clamped to the
+// last day of the new month.
+func addMonths(t time.Time, months int) time.Time {
+	y, m, d := t.Date()
+	first := time.Date(y, m+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); d > last {
+		d = last
+	}
+
+	return first.AddDate(0, 0, d-1)
+}
+
+// validRecurrence returns true if given recurrence rule is empty (no
+// recurrence) or valid.
+func validRecurrence(value string) bool {
+	if value == "" {
+		return true
+	}
+
+	_, err := parseRecurrence(value)
+	return err == nil
+}
+
+// normalizeRecurrence returns normalized form of valid recurrence rule or
+// empty string for empty rule.
+func normalizeRecurrence(value string) string {
+	r, err := parseRecurrence(value)
+	if value == "" || err != nil {
+		return ""
+	}
+
+	return r.String()
+}
+
+// shiftDates moves due and start dates of the Task and its descendants by
+// given duration.
+func (t *Task) shiftDates(d time.Duration) {
+	if t.DueAt != nil {
+		dueAt := t.DueAt.Add(d)
+		t.DueAt = &dueAt
+	}
+
+	if t.StartAt != nil {
+		startAt := t.StartAt.Add(d)
+		t.StartAt = &startAt
+	}
+
+	for _, child := range t.Children {
+		child.shiftDates(d)
+	}
+}
+
+// takeRecurrence removes recurrence rule from the new version of the Task if
+// the Task is completed by the change and returns the rule. Completed
+// recurring Task passes its rule to the next occurrence created by recur.
+func takeRecurrence(oldVersionTask, newVersionTask *Task) string {
+	if !newVersionTask.Completed || oldVersionTask.Completed {
+		return ""
+	}
+
+	rule := newVersionTask.Recurrence
+	newVersionTask.Recurrence = ""
+
+	return rule
+}
+
+// recur creates next occurrence of recurring Task at given TaskID path which
+// was completed. Next occurrence is copy of the Task with its sub tasks which
+// are not completed. Its dates are moved to the next occurrence of the rule
+// computed from the due date (or start date or current time if the Task has
+// no dates) and it's placed after its siblings.
+func (s *TaskStorageService) recur(path []TaskID, task *Task, rule string) (*Task, error) {
+	r, err := parseRecurrence(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	base := s.clock.Now().UTC()
+	if task.DueAt != nil {
+		base = *task.DueAt
+	} else if task.StartAt != nil {
+		base = *task.StartAt
+	}
+
+	next, found := r.next(base)
+	if !found {
+		fmt.Printf("(DEBUG) recurrence: Task %d has no next occurrence.\n", task.ID)
+		return nil, nil
+	}
+
+	parent := path[:len(path)-1]
+	position, err := s.nextPosition(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := map[TaskID]TaskID{}
+	newTask := s.cloneTree(task, CloneFields{ResetCompleted: true}, ids)
+	remapBlockers(newTask, ids)
+	newTask.Position = position
+	newTask.Recurrence = rule
+	newTask.shiftDates(next.Sub(base))
+	if newTask.DueAt == nil && newTask.StartAt == nil {
+		newTask.DueAt = &next
+	}
+
+	if err := s.storage.Insert(parent, newTask); err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
@@ -0,0 +1,98 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	tests := map[string]struct {
+		value string
+		exp   string
+		err   error
+	}{
+		"daily":              {value: "FREQ=DAILY", exp: "FREQ=DAILY"},
+		"interval":           {value: "freq=weekly;interval=2", exp: "FREQ=WEEKLY;INTERVAL=2"},
+		"rrule prefix":       {value: "RRULE:FREQ=WEEKLY;BYDAY=FR,MO", exp: "FREQ=WEEKLY;BYDAY=MO,FR"},
+		"interval 1":         {value: "FREQ=MONTHLY;INTERVAL=1", exp: "FREQ=MONTHLY"},
+		"missing freq":       {value: "INTERVAL=2", err: ErrTaskRecurrenceIsNotValid},
+		"unknown freq":       {value: "FREQ=YEARLY", err: ErrTaskRecurrenceIsNotValid},
+		"zero interval":      {value: "FREQ=DAILY;INTERVAL=0", err: ErrTaskRecurrenceIsNotValid},
+		"unknown day":        {value: "FREQ=WEEKLY;BYDAY=XX", err: ErrTaskRecurrenceIsNotValid},
+		"monthly by day":     {value: "FREQ=MONTHLY;BYDAY=MO", err: ErrTaskRecurrenceIsNotValid},
+		"duplicate part":     {value: "FREQ=DAILY;FREQ=WEEKLY", err: ErrTaskRecurrenceIsNotValid},
+		"unknown part":       {value: "FREQ=DAILY;COUNT=3", err: ErrTaskRecurrenceIsNotValid},
+		"part without value": {value: "FREQ", err: ErrTaskRecurrenceIsNotValid},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		r, err := parseRecurrence(tc.value)
+		if err != tc.err {
+			t.Fatalf("expected error %v got %v", tc.err, err)
+		}
+
+		if err == nil && r.String() != tc.exp {
+			t.Fatalf("expected rule %q got %q", tc.exp, r.String())
+		}
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	// 2020-01-08 is Wednesday.
+	wednesday := time.Date(2020, 1, 8, 10, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		rule  string
+		t     time.Time
+		exp   time.Time
+		found bool
+	}{
+		"daily": {
+			rule: "FREQ=DAILY;INTERVAL=3", t: wednesday,
+			exp: time.Date(2020, 1, 11, 10, 0, 0, 0, time.UTC), found: true,
+		},
+		"daily on work days": {
+			rule: "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", t: time.Date(2020, 1, 10, 10, 0, 0, 0, time.UTC),
+			exp: time.Date(2020, 1, 13, 10, 0, 0, 0, time.UTC), found: true,
+		},
+		"daily without next occurrence": {
+			rule: "FREQ=DAILY;INTERVAL=7;BYDAY=MO", t: wednesday,
+		},
+		"weekly": {
+			rule: "FREQ=WEEKLY", t: wednesday,
+			exp: time.Date(2020, 1, 15, 10, 0, 0, 0, time.UTC), found: true,
+		},
+		"weekly by day in the same week": {
+			rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", t: wednesday,
+			exp: time.Date(2020, 1, 10, 10, 0, 0, 0, time.UTC), found: true,
+		},
+		"weekly by day after interval": {
+			rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE", t: wednesday,
+			exp: time.Date(2020, 1, 20, 10, 0, 0, 0, time.UTC), found: true,
+		},
+		"monthly": {
+			rule: "FREQ=MONTHLY;INTERVAL=2", t: wednesday,
+			exp: time.Date(2020, 3, 8, 10, 0, 0, 0, time.UTC), found: true,
+		},
+		"monthly at the end of month": {
+			rule: "FREQ=MONTHLY", t: time.Date(2020, 1, 31, 10, 0, 0, 0, time.UTC),
+			exp: time.Date(2020, 2, 29, 10, 0, 0, 0, time.UTC), found: true,
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		r, err := parseRecurrence(tc.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		next, found := r.next(tc.t)
+		if found != tc.found || !next.Equal(tc.exp) {
+			t.Fatalf("expected next occurrence %s (%t) got %s (%t)", tc.exp, tc.found, next, found)
+		}
+	}
+}
@@ -0,0 +1,528 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SearchQueryError is returned when search query can't be parsed. Pos is
+// position (counted in characters from 1) where the error was found.
+type SearchQueryError struct {
+	Pos     int
+	Message string
+}
+
+// Error implements error interface.
+func (e *SearchQueryError) Error() string {
+	return fmt.Sprintf("Search query is not valid at position %d: %s", e.Pos, e.Message)
+}
+
+// Search query operators.
+const (
+	searchOpEqual        = ":"
+	searchOpContains     = ":~"
+	searchOpLess         = ":<"
+	searchOpLessEqual    = ":<="
+	searchOpGreater      = ":>"
+	searchOpGreaterEqual = ":>="
+)
+
+// searchComparisons are operators of ordered fields (eg. priority, due).
+var searchComparisons = []string{searchOpEqual, searchOpLess, searchOpLessEqual, searchOpGreater, searchOpGreaterEqual}
+
+// searchDateLayout is layout of date only values of time fields.
+const searchDateLayout = "2006-01-02"
+
+// searchTask is Task visited by search with its position in the tree.
+type searchTask struct {
+	task *Task
+	// depth is number of Tasks in TaskID path, top level Tasks have depth 1.
+	depth int
+	// now is current time used by time relative conditions.
+	now time.Time
+}
+
+// searchCondition is single term of the search query.
+type searchCondition struct {
+	negate bool
+	match  func(t *searchTask) bool
+}
+
+// searchField defines field which can be used in search query. Parse returns
+// condition for given operator and value.
+type searchField struct {
+	ops   []string
+	parse func(op, value string) (func(t *searchTask) bool, error)
+}
+
+// searchFields are fields which can be used in search query by their names.
+var searchFields = map[string]searchField{
+	"label": {
+		ops:   []string{searchOpEqual, searchOpContains},
+		parse: parseSearchText(func(t *Task) string { return t.Label }),
+	},
+	"notes": {
+		ops:   []string{searchOpEqual, searchOpContains},
+		parse: parseSearchText(func(t *Task) string { return t.Notes }),
+	},
+	"tag": {
+		ops: []string{searchOpEqual, searchOpContains},
+		parse: func(op, value string) (func(t *searchTask) bool, error) {
+			value = strings.ToLower(value)
+			return func(t *searchTask) bool {
+				for _, tag := range t.task.Tags {
+					if (op == searchOpEqual && tag == value) || (op == searchOpContains && strings.Contains(tag, value)) {
+						return true
+					}
+				}
+				return false
+			}, nil
+		},
+	},
+	"status": {
+		ops: []string{searchOpEqual},
+		parse: func(op, value string) (func(t *searchTask) bool, error) {
+			status := Status(strings.ToLower(value))
+			if !validStatus(status) {
+				return nil, fmt.Errorf("unknown status %q", value)
+			}
+			return func(t *searchTask) bool { return t.task.status() == status }, nil
+		},
+	},
+	"completed": {
+		ops:   []string{searchOpEqual},
+		parse: parseSearchBool(func(t *searchTask) bool { return t.task.Completed }),
+	},
+	"overdue": {
+		ops: []string{searchOpEqual},
+		parse: parseSearchBool(func(t *searchTask) bool {
+			return TaskFilter{Overdue: true}.match(t.task, t.now)
+		}),
+	},
+	"recurring": {
+		ops:   []string{searchOpEqual},
+		parse: parseSearchBool(func(t *searchTask) bool { return t.task.Recurrence != "" }),
+	},
+	"priority": {
+		ops: searchComparisons,
+		parse: func(op, value string) (func(t *searchTask) bool, error) {
+			priority, found := map[string]int{"none": PriorityNone, "low": PriorityLow, "medium": PriorityMedium, "high": PriorityHigh}[strings.ToLower(value)]
+			if !found {
+				var err error
+				if priority, err = strconv.Atoi(value); err != nil || !validPriority(priority) {
+					return nil, fmt.Errorf("priority %q is not valid", value)
+				}
+			}
+			return func(t *searchTask) bool { return compareSearchInt(op, t.task.Priority, priority) }, nil
+		},
+	},
+	"depth": {
+		ops:   searchComparisons,
+		parse: parseSearchInt("depth", func(t *searchTask) int { return t.depth }),
+	},
+	"id": {
+		ops:   searchComparisons,
+		parse: parseSearchInt("id", func(t *searchTask) int { return int(t.task.ID) }),
+	},
+	"due": {
+		ops:   searchComparisons,
+		parse: parseSearchTime(func(t *Task) *time.Time { return t.DueAt }),
+	},
+	"start": {
+		ops:   searchComparisons,
+		parse: parseSearchTime(func(t *Task) *time.Time { return t.StartAt }),
+	},
+	"created_at": {
+		ops:   searchComparisons,
+		parse: parseSearchTime(func(t *Task) *time.Time { return t.CreatedAt }),
+	},
+	"updated_at": {
+		ops:   searchComparisons,
+		parse: parseSearchTime(func(t *Task) *time.Time { return t.UpdatedAt }),
+	},
+	"completed_at": {
+		ops:   searchComparisons,
+		parse: parseSearchTime(func(t *Task) *time.Time { return t.CompletedAt }),
+	},
+}
+
+// SearchQuery is parsed search query. All its conditions must match. Zero
+// value of SearchQuery matches every Task.
+type SearchQuery struct {
+	conditions []searchCondition
+}
+
+// ParseSearchQuery parses search query. Query is list of terms separated by
+// spaces which must all match. Term is field condition (eg. "label:~deploy",
+// "depth:<3", "tag:ops") or text which must be contained in the label. Value
+// with spaces must be quoted ("label:~\"deploy app\"") and term prefixed with
+// "-" is negated.
+func ParseSearchQuery(value string) (SearchQuery, error) {
+	p := &searchParser{input: []rune(value)}
+	query := SearchQuery{}
+
+	for {
+		p.skipSpaces()
+		if p.done() {
+			break
+		}
+
+		condition, err := p.term()
+		if err != nil {
+			fmt.Printf("(DEBUG) search: Parsing query failed: %s\n", err)
+			return SearchQuery{}, err
+		}
+		query.conditions = append(query.conditions, condition)
+	}
+
+	return query, nil
+}
+
+// match returns true if the Task matches all conditions of the query.
+func (q SearchQuery) match(t *searchTask) bool {
+	for _, condition := range q.conditions {
+		if condition.match(t) == condition.negate {
+			return false
+		}
+	}
+
+	return true
+}
+
+// searchParser is state of search query parser.
+type searchParser struct {
+	input []rune
+	pos   int
+}
+
+// done returns true if the whole input was read.
+func (p *searchParser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+// peek returns current character or 0 at the end of input.
+func (p *searchParser) peek() rune {
+	if p.done() {
+		return 0
+	}
+
+	return p.input[p.pos]
+}
+
+// skipSpaces moves after all spaces at current position.
+func (p *searchParser) skipSpaces() {
+	for !p.done() && unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+// fail returns error at given position (counted from 0).
+func (p *searchParser) fail(pos int, format string, args ...interface{}) error {
+	return &SearchQueryError{Pos: pos + 1, Message: fmt.Sprintf(format, args...)}
+}
+
+// term parses single term of the query.
+func (p *searchParser) term() (searchCondition, error) {
+	condition := searchCondition{}
+	if p.peek() == '-' {
+		condition.negate = true
+		p.pos++
+	}
+
+	start := p.pos
+	name := p.name()
+	if name == "" || p.peek() != ':' {
+		// Text term matches the label.
+		p.pos = start
+		value, err := p.value()
+		if err != nil {
+			return searchCondition{}, err
+		}
+		if value == "" {
+			return searchCondition{}, p.fail(start, "expected term")
+		}
+
+		match, _ := parseSearchText(func(t *Task) string { return t.Label })(searchOpContains, value)
+		condition.match = match
+		return condition, nil
+	}
+
+	field, found := searchFields[strings.ToLower(name)]
+	if !found {
+		return searchCondition{}, p.fail(start, "unknown field %q", name)
+	}
+
+	opPos := p.pos
+	op := p.operator()
+	if !containsString(field.ops, op) {
+		return searchCondition{}, p.fail(opPos, "operator %q can't be used with field %q (use %s)", op, name, strings.Join(field.ops, " "))
+	}
+
+	valuePos := p.pos
+	value, err := p.value()
+	if err != nil {
+		return searchCondition{}, err
+	}
+	if value == "" {
+		return searchCondition{}, p.fail(valuePos, "expected value after %q", name+op)
+	}
+
+	match, err := field.parse(op, value)
+	if err != nil {
+		return searchCondition{}, p.fail(valuePos, "%s", err)
+	}
+	condition.match = match
+
+	return condition, nil
+}
+
+// name parses field name (letters and "_" only).
+func (p *searchParser) name() string {
+	start := p.pos
+	for !p.done() && (unicode.IsLetter(p.peek()) || p.peek() == '_') {
+		p.pos++
+	}
+
+	return string(p.input[start:p.pos])
+}
+
+// operator parses operator at current position which starts with ":".
+func (p *searchParser) operator() string {
+	for _, op := range []string{searchOpLessEqual, searchOpGreaterEqual, searchOpContains, searchOpLess, searchOpGreater, searchOpEqual} {
+		if strings.HasPrefix(string(p.input[p.pos:]), op) {
+			p.pos += len([]rune(op))
+			return op
+		}
+	}
+
+	return ""
+}
+
+// value parses quoted string or word which ends with space or end of input.
+// Quoted string may contain escaped quotes and backslashes.
+func (p *searchParser) value() (string, error) {
+	if p.peek() != '"' {
+		start := p.pos
+		for !p.done() && !unicode.IsSpace(p.peek()) {
+			if p.peek() == '"' {
+				return "", p.fail(p.pos, "unexpected quote")
+			}
+			p.pos++
+		}
+
+		return string(p.input[start:p.pos]), nil
+	}
+
+	start := p.pos
+	p.pos++
+	var b strings.Builder
+	for !p.done() {
+		c := p.peek()
+		p.pos++
+
+		switch c {
+		case '"':
+			if !p.done() && !unicode.IsSpace(p.peek()) {
+				return "", p.fail(p.pos, "expected space after quoted value")
+			}
+			return b.String(), nil
+		case '\\':
+			if p.peek() != '"' && p.peek() != '\\' {
+				return "", p.fail(p.pos-1, "unknown escape sequence")
+			}
+			b.WriteRune(p.peek())
+			p.pos++
+		default:
+			b.WriteRune(c)
+		}
+	}
+
+	return "", p.fail(start, "quoted value is not closed")
+}
+
+// parseSearchText returns parser of text field conditions. Text is compared
+// case insensitively.
+func parseSearchText(text func(t *Task) string) func(op, value string) (func(t *searchTask) bool, error) {
+	return func(op, value string) (func(t *searchTask) bool, error) {
+		value = strings.ToLower(value)
+		return func(t *searchTask) bool {
+			if op == searchOpContains {
+				return strings.Contains(strings.ToLower(text(t.task)), value)
+			}
+			return strings.ToLower(text(t.task)) == value
+		}, nil
+	}
+}
+
+// parseSearchBool returns parser of boolean field conditions.
+func parseSearchBool(field func(t *searchTask) bool) func(op, value string) (func(t *searchTask) bool, error) {
+	return func(op, value string) (func(t *searchTask) bool, error) {
+		expected, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected true or false, got %q", value)
+		}
+		return func(t *searchTask) bool { return field(t) == expected }, nil
+	}
+}
+
+// parseSearchInt returns parser of integer field conditions.
+func parseSearchInt(name string, field func(t *searchTask) int) func(op, value string) (func(t *searchTask) bool, error) {
+	return func(op, value string) (func(t *searchTask) bool, error) {
+		expected, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s %q is not a number", name, value)
+		}
+		return func(t *searchTask) bool { return compareSearchInt(op, field(t), expected) }, nil
+	}
+}
+
+// parseSearchTime returns parser of time field conditions. Value is RFC 3339
+// time, date (in UTC) or number of days or hours relative to current time
+// (eg. "-30d", "12h"). Date compared for equality matches the whole day.
+// Tasks without the time don't match.
+func parseSearchTime(field func(t *Task) *time.Time) func(op, value string) (func(t *searchTask) bool, error) {
+	return func(op, value string) (func(t *searchTask) bool, error) {
+		bounds, err := parseSearchTimeValue(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(t *searchTask) bool {
+			value := field(t.task)
+			if value == nil {
+				return false
+			}
+
+			from, to := bounds(t.now)
+			switch op {
+			case searchOpLess:
+				return value.Before(from)
+			case searchOpLessEqual:
+				return !value.After(to)
+			case searchOpGreater:
+				return value.After(to)
+			case searchOpGreaterEqual:
+				return !value.Before(from)
+			}
+			return !value.Before(from) && !value.After(to)
+		}, nil
+	}
+}
+
+// parseSearchTimeValue returns function which returns the first and the last
+// instant of the time value at given current time.
+func parseSearchTimeValue(value string) (func(now time.Time) (time.Time, time.Time), error) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return func(now time.Time) (time.Time, time.Time) { return at, at }, nil
+	}
+
+	if day, err := time.Parse(searchDateLayout, value); err == nil {
+		end := day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		return func(now time.Time) (time.Time, time.Time) { return day, end }, nil
+	}
+
+	if len(value) > 1 {
+		n, err := strconv.Atoi(value[:len(value)-1])
+		if err == nil {
+			switch value[len(value)-1] {
+			case 'd':
+				return func(now time.Time) (time.Time, time.Time) {
+					at := now.AddDate(0, 0, n)
+					return at, at
+				}, nil
+			case 'h':
+				return func(now time.Time) (time.Time, time.Time) {
+					at := now.Add(time.Duration(n) * time.Hour)
+					return at, at
+				}, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("expected date (YYYY-MM-DD), RFC 3339 time or relative time (eg. -30d), got %q", value)
+}
+
+// compareSearchInt compares integer value with expected value by given
+// operator.
+func compareSearchInt(op string, value, expected int) bool {
+	switch op {
+	case searchOpLess:
+		return value < expected
+	case searchOpLessEqual:
+		return value <= expected
+	case searchOpGreater:
+		return value > expected
+	case searchOpGreaterEqual:
+		return value >= expected
+	}
+
+	return value == expected
+}
+
+// containsString returns true if values contain given value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SearchResult is Task matching search query with its TaskID path and labels
+// of its ancestors (from the top level Task).
+type SearchResult struct {
+	Path      TaskIDPath `json:"path"`
+	Ancestors []string   `json:"ancestors"`
+	Task      Task       `json:"task"`
+}
+
+// searchTasks walks Tasks with their children depth first (ordered
+// ByPosition) and returns every Task matching the query. Returned Tasks don't
+// contain children but keep their progress.
+func searchTasks(tasks []Task, query SearchQuery, now time.Time) []SearchResult {
+	result := []SearchResult{}
+
+	var walk func(parent []TaskID, ancestors []string, tasks []Task)
+	walk = func(parent []TaskID, ancestors []string, tasks []Task) {
+		sort.Sort(ByPosition(tasks))
+		for i := range tasks {
+			task := &tasks[i]
+			path := childPath(parent, task.ID)
+			if query.match(&searchTask{task: task, depth: len(path), now: now}) {
+				result = append(result, SearchResult{
+					Path:      path,
+					Ancestors: append([]string{}, ancestors...),
+					Task:      *task.withProgress(),
+				})
+			}
+
+			walk(path, append(ancestors[:len(ancestors):len(ancestors)], task.Label), task.Children.list())
+		}
+	}
+	walk([]TaskID{}, []string{}, tasks)
+
+	return result
+}
+
+// Search walks the whole tree and returns Tasks (without children) matching
+// given SearchQuery with their TaskID paths and labels of their ancestors.
+// Tasks are returned in depth first order with siblings ordered ByPosition.
+// Search implements TaskService interface.
+func (s *TaskStorageService) Search(query SearchQuery) ([]SearchResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tasks, err := s.storage.FindAll()
+	if err != nil {
+		fmt.Println("(DEBUG) service: Searching Tasks failed.")
+		return nil, err
+	}
+
+	return searchTasks(tasks, query, s.clock.Now()), nil
+}
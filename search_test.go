@@ -0,0 +1,177 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSearchTasks(t *testing.T) {
+	now := time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC)
+	yesterday := now.Add(-24 * time.Hour)
+	tomorrow := now.Add(24 * time.Hour)
+
+	tasks := []Task{
+		Task{
+			ID:       TaskID(2),
+			Label:    "Deploy app",
+			DueAt:    &tomorrow,
+			Priority: PriorityHigh,
+			Tags:     []string{"ops", "urgent"},
+		},
+		Task{
+			ID:    TaskID(1),
+			Label: "foo",
+			Notes: "Deploy notes",
+			Children: SubTasks{
+				TaskID(4): &Task{
+					ID:    TaskID(4),
+					Label: "deploy db",
+					DueAt: &yesterday,
+					Tags:  []string{"ops"},
+				},
+				TaskID(3): &Task{
+					ID:          TaskID(3),
+					Label:       "baz qux",
+					Completed:   true,
+					DueAt:       &yesterday,
+					CreatedAt:   &yesterday,
+					CompletedAt: &now,
+				},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		query string
+		res   []TaskIDPath
+	}{
+		"empty": {
+			query: "  ",
+			res: []TaskIDPath{
+				TaskIDPath{TaskID(2)},
+				TaskIDPath{TaskID(1)},
+				TaskIDPath{TaskID(1), TaskID(3)},
+				TaskIDPath{TaskID(1), TaskID(4)},
+			},
+		},
+		"label contains": {
+			query: `label:~"DEPLOY" depth:<2 tag:ops`,
+			res: []TaskIDPath{
+				TaskIDPath{TaskID(2)},
+			},
+		},
+		"text": {
+			query: `deploy -tag:urgent`,
+			res: []TaskIDPath{
+				TaskIDPath{TaskID(1), TaskID(4)},
+			},
+		},
+		"quoted text with escapes": {
+			query: `"baz qux" label:"baz \"qux\""`,
+			res:   []TaskIDPath{},
+		},
+		"notes and completed": {
+			query: `notes:~deploy completed:false`,
+			res: []TaskIDPath{
+				TaskIDPath{TaskID(1)},
+			},
+		},
+		"status and overdue": {
+			query: `status:done overdue:false due:2020-01-01`,
+			res: []TaskIDPath{
+				TaskIDPath{TaskID(1), TaskID(3)},
+			},
+		},
+		"due and priority": {
+			query: `due:>=2020-01-02 priority:>low`,
+			res: []TaskIDPath{
+				TaskIDPath{TaskID(2)},
+			},
+		},
+		"negated due": {
+			query: `-due:<2020-01-02T00:00:00Z id:<3`,
+			res: []TaskIDPath{
+				TaskIDPath{TaskID(2)},
+				TaskIDPath{TaskID(1)},
+			},
+		},
+		"created and completed": {
+			query: `created_at:<-12h completed_at:2020-01-02`,
+			res: []TaskIDPath{
+				TaskIDPath{TaskID(1), TaskID(3)},
+			},
+		},
+		"relative completed": {
+			query: `completed_at:>-1d -created_at:<=2020-01-01`,
+			res:   []TaskIDPath{},
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		query, err := ParseSearchQuery(tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res := []TaskIDPath{}
+		for _, result := range searchTasks(tasks, query, now) {
+			if result.Task.Children != nil {
+				t.Fatalf("expected task %d without children", result.Task.ID)
+			}
+			res = append(res, result.Path)
+		}
+
+		if !reflect.DeepEqual(tc.res, res) {
+			t.Fatalf("expected paths %v got %v", tc.res, res)
+		}
+	}
+
+	query, err := ParseSearchQuery("tag:ops depth:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := searchTasks(tasks, query, now)
+	if len(res) != 1 || !reflect.DeepEqual([]string{"foo"}, res[0].Ancestors) {
+		t.Fatalf("expected one result with ancestors %v got %v", []string{"foo"}, res)
+	}
+}
+
+func TestParseSearchQueryErrors(t *testing.T) {
+	tests := map[string]struct {
+		query string
+		pos   int
+	}{
+		"unknown field":            {query: "label:foo owner:me", pos: 11},
+		"not allowed operator":     {query: "completed:<true", pos: 10},
+		"missing value":            {query: "tag:ops label:~", pos: 16},
+		"not valid bool":           {query: "completed:maybe", pos: 11},
+		"not valid depth":          {query: "depth:<=two", pos: 9},
+		"not valid date":           {query: "due:>tomorrow", pos: 6},
+		"not valid relative time":  {query: "updated_at:<-30m", pos: 13},
+		"unknown status":           {query: "status:open", pos: 8},
+		"not closed quote":         {query: `label:~"deploy`, pos: 8},
+		"unknown escape":           {query: `"foo\n"`, pos: 5},
+		"quote inside word":        {query: `foo"bar"`, pos: 4},
+		"text after quoted value":  {query: `"foo"bar`, pos: 6},
+		"negation without a term":  {query: "foo - bar", pos: 6},
+		"not valid priority value": {query: "priority:urgent", pos: 10},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		_, err := ParseSearchQuery(tc.query)
+		queryErr, ok := err.(*SearchQueryError)
+		if !ok {
+			t.Fatalf("expected SearchQueryError got %v", err)
+		}
+
+		if queryErr.Pos != tc.pos {
+			t.Fatalf("expected error at position %d got %s", tc.pos, queryErr)
+		}
+	}
+}
@@ -1,6 +1,11 @@
 package tasks
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
 
 // TaskService is interface which defines business logic with Task entity.
 // Current TaskService is very simple and offers only CRUD operations. In real
@@ -11,12 +16,59 @@ type TaskService interface {
 	Create([]TaskID, CreateFields) (Task, error)
 	// Find returns Task from given TaskID path.
 	Find([]TaskID) (Task, error)
+	// FindByID returns Task with given TaskID and its TaskID path.
+	FindByID(TaskID) (Task, []TaskID, error)
 	// FindAll returns all root Tasks(with their children).
 	FindAll() ([]Task, error)
+	// FindByFilter returns all Tasks in the tree matching given TaskFilter
+	// with their TaskID paths.
+	FindByFilter(TaskFilter) ([]TaskWithPath, error)
+	// FindByText returns Tasks in the tree matching given full-text query and
+	// TaskFilter ordered by score.
+	FindByText(string, TaskFilter) ([]TextMatch, error)
+	// Search returns all Tasks in the tree matching given SearchQuery with
+	// their TaskID paths and labels of their ancestors.
+	Search(SearchQuery) ([]SearchResult, error)
 	// Update updates Task at given TaskID path.
 	Update([]TaskID, UpdateFields) (Task, error)
-	// Delete removes Tasks at given TaskID path.
-	Delete([]TaskID) (Task, error)
+	// Delete moves Tasks at given TaskID path into trash.
+	Delete([]TaskID, DeleteFields) (Task, error)
+	// Patch applies patch document on Task at given TaskID path.
+	Patch([]TaskID, PatchFields) (Task, error)
+	// Move moves Task at given TaskID path under Task at second TaskID path.
+	Move([]TaskID, []TaskID) (Task, error)
+	// Clone copies Task at given TaskID path with its children under Task at
+	// second TaskID path and returns the copy with mapping of old to new
+	// TaskIDs.
+	Clone([]TaskID, []TaskID, CloneFields) (Task, map[TaskID]TaskID, error)
+	// FindTags returns all tags used in the tree with number of Tasks.
+	FindTags() ([]TagCount, error)
+	// Reorder changes order of sub tasks of Task at given TaskID path.
+	Reorder([]TaskID, []TaskID) (Task, error)
+	// FindDependencies returns dependency graph of Task at given TaskID
+	// path.
+	FindDependencies([]TaskID) (TaskDependencies, error)
+	// FindTrash returns all trashed Tasks.
+	FindTrash() ([]TrashedTask, error)
+	// Restore moves trashed Task with given TaskID back to the tree and
+	// returns it with its TaskID path.
+	Restore(TaskID) (Task, []TaskID, error)
+	// Purge permanently removes trashed Task with given TaskID.
+	Purge(TaskID) (TrashedTask, error)
+	// FindHistory returns all changes of Task at given TaskID path.
+	FindHistory([]TaskID) ([]HistoryEntry, error)
+	// Revert changes fields of Task at given TaskID path to given version.
+	Revert([]TaskID, int) (Task, error)
+	// Undo reverts the latest operation and returns changes made by undo.
+	Undo() ([]HistoryEntry, error)
+	// Batch applies given operations atomically and returns their results.
+	Batch([]BatchOperation) ([]BatchResult, error)
+	// BulkUpdate updates all Tasks matching the query.
+	BulkUpdate(BulkFields, UpdateFields) ([]BulkResult, error)
+	// BulkDelete deletes all Tasks matching the query.
+	BulkDelete(BulkFields) ([]BulkResult, error)
+	// WithActor returns TaskService which records given actor in history.
+	WithActor(string) TaskService
 }
 
 // TaskStorageService is simple implementation of TaskService working with
@@ -24,33 +76,113 @@ type TaskService interface {
 // CRUD operations - in real case it would have more business logic related
 // operations.
 type TaskStorageService struct {
+	// storage is TaskStorage wrapped by history.
 	storage TaskStorage
+	// history records changes of Tasks made by operations into history.
+	history *historyStorage
+	// actor is who makes the changes, it's recorded in history.
+	actor string
+	// clock gives time for Task timestamps.
+	clock Clock
+	// completionPolicy defines how completion is propagated in the tree.
+	completionPolicy CompletionPolicy
+	// workflow defines allowed changes of Task status.
+	workflow Workflow
+
+	// mu serializes operations which modify storage so read-modify-write
+	// operations (eg. Update) don't overwrite each other. Readers hold it
+	// shared so they never see changes of unfinished operation (eg. batch
+	// which is rolled back).
+	mu *sync.RWMutex
 }
 
 // NewTaskStorageService returns new instance of TaskStorageService
-func NewTaskStorageService(storage TaskStorage) *TaskStorageService {
+func NewTaskStorageService(storage TaskStorage, clock Clock) *TaskStorageService {
+	history := &historyStorage{TaskStorage: storage, lastOperation: -1}
+
 	return &TaskStorageService{
-		storage: storage,
+		storage:  history,
+		history:  history,
+		clock:    clock,
+		workflow: DefaultWorkflow(),
+		mu:       &sync.RWMutex{},
 	}
 }
 
+// WithActor returns TaskService which records given actor (eg. user name) in
+// history of changes it makes. It shares storage, history and lock with the
+// TaskStorageService.
+// WithActor implements TaskService interface.
+func (s *TaskStorageService) WithActor(actor string) TaskService {
+	service := *s
+	service.actor = actor
+
+	return &service
+}
+
+// SetCompletionPolicy sets how completion of updated or patched Task is
+// propagated in the tree.
+func (s *TaskStorageService) SetCompletionPolicy(policy CompletionPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.completionPolicy = policy
+}
+
+// SetWorkflow sets allowed changes of Task status.
+func (s *TaskStorageService) SetWorkflow(workflow Workflow) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.workflow = workflow
+}
+
 // CreateFields is struct which contains only allowed fields for Task in Create
 // flow.
 type CreateFields struct {
-	Label string
+	Label    string
+	DueAt    *time.Time
+	StartAt  *time.Time
+	Priority int
+	Tags     []string
+	Notes    string
+	// Status is initial status of the Task, todo if it's empty.
+	Status Status
+	// BlockedBy contains TaskIDs of blockers of the Task.
+	BlockedBy []TaskID
+	// Recurrence is recurrence rule of the Task, empty for no recurrence.
+	Recurrence string
+	// SubTasks are created under the Task in given order.
+	SubTasks []CreateFields
 }
 
 // Create creates and stores new Task in storage under given TaskID path. Task
-// is created from CreateFields provided in parameter. TaskID is received from
-// TaskStorage service which guarantees unique TaskID.
+// is created from CreateFields provided in parameter with all its sub tasks.
+// TaskID is received from TaskStorage service which guarantees unique TaskID.
+// New Task is placed after its siblings.
 // Create implements TaskService interface.
 func (s *TaskStorageService) Create(path []TaskID, fields CreateFields) (Task, error) {
-	// Create a new Task: copy allowed (whitelisted) fields from CreateFields
-	newTask := &Task{
-		ID:        TaskID(s.storage.NextTaskID()),
-		Label:     fields.Label,
-		Completed: false,
-		Children:  SubTasks{},
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.operation(OperationCreate)()
+
+	return s.createTask(path, fields)
+}
+
+// createTask creates new Task with its sub tasks under given TaskID path.
+// Whole subtree is validated before it's inserted. Caller must hold the lock.
+func (s *TaskStorageService) createTask(path []TaskID, fields CreateFields) (Task, error) {
+	position, err := s.nextPosition(path)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Inserting a new Task failed: %s\n", err)
+		return Task{}, err
+	}
+
+	newTask := s.newTaskFromFields(fields, position)
+
+	if err := s.validateTreeBlockers(newTask); err != nil {
+		fmt.Printf("(DEBUG) service: Inserting a new Task failed: %s\n", err)
+		return Task{}, err
 	}
 
 	if err := s.storage.Insert(path, newTask); err != nil {
@@ -61,68 +193,870 @@ func (s *TaskStorageService) Create(path []TaskID, fields CreateFields) (Task, e
 	return *newTask, nil
 }
 
+// newTaskFromFields creates new Task at given position with new TaskIDs
+// (including its sub tasks) from CreateFields. Sub tasks are positioned in
+// given order.
+func (s *TaskStorageService) newTaskFromFields(fields CreateFields, position int) *Task {
+	// Create a new Task: copy allowed (whitelisted) fields from CreateFields
+	newTask := &Task{
+		ID:         TaskID(s.storage.NextTaskID()),
+		Label:      fields.Label,
+		Status:     fields.Status,
+		DueAt:      utcTime(fields.DueAt),
+		StartAt:    utcTime(fields.StartAt),
+		Recurrence: normalizeRecurrence(fields.Recurrence),
+		Priority:   fields.Priority,
+		Tags:       normalizeTags(fields.Tags),
+		Notes:      fields.Notes,
+		BlockedBy:  normalizeTaskIDs(fields.BlockedBy),
+		Position:   position,
+		Revision:   1,
+		Children:   SubTasks{},
+	}
+	if newTask.Status == "" {
+		newTask.Status = StatusTodo
+	}
+	newTask.setStatus(newTask.Status)
+	s.created(newTask)
+
+	for i, childFields := range fields.SubTasks {
+		child := s.newTaskFromFields(childFields, i+1)
+		newTask.Children[child.ID] = child
+	}
+
+	return newTask
+}
+
 // Find returns Task from given TaskID path or error if Task is not found.
 // Find implements TaskService interface.
 func (s *TaskStorageService) Find(path []TaskID) (Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	return s.storage.Find(path)
 }
 
+// FindByID returns Task with given TaskID and its TaskID path or error if
+// Task is not found. Client does not need to know the path of the Task.
+// FindByID implements TaskService interface.
+func (s *TaskStorageService) FindByID(taskID TaskID) (Task, []TaskID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.storage.FindByID(taskID)
+}
+
 // FindAll returns complete Task tree in storage. Every root Task with its
 // all chidren and subchildren. This can be quite verbose and huge.
 // FindAll implements TaskService interface.
 func (s *TaskStorageService) FindAll() ([]Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	return s.storage.FindAll()
 }
 
+// FindByFilter walks the whole tree and returns Tasks (without children)
+// matching given TaskFilter with their TaskID paths. Tasks are returned in
+// depth first order with siblings ordered ByPosition.
+func (s *TaskStorageService) FindByFilter(filter TaskFilter) ([]TaskWithPath, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tasks, err := s.storage.FindAll()
+	if err != nil {
+		fmt.Println("(DEBUG) service: Finding Tasks by filter failed.")
+		return nil, err
+	}
+
+	return filterTasks(tasks, filter, s.clock.Now()), nil
+}
+
+// FindTags walks the whole tree and returns every used tag with number of
+// Tasks which have the tag. Tags are sorted alphabetically.
+// FindTags implements TaskService interface.
+func (s *TaskStorageService) FindTags() ([]TagCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tasks, err := s.storage.FindAll()
+	if err != nil {
+		fmt.Println("(DEBUG) service: Finding tags failed.")
+		return nil, err
+	}
+
+	return countTags(tasks), nil
+}
+
+// FindDependencies returns dependency graph of Task at given TaskID path:
+// Tasks which block it and Tasks blocked by it, directly or transitively.
+// FindDependencies implements TaskService interface.
+func (s *TaskStorageService) FindDependencies(path []TaskID) (TaskDependencies, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	task, err := s.storage.Find(path)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Finding Task dependencies failed: %s\n", err)
+		return TaskDependencies{}, err
+	}
+
+	tasks, err := s.storage.FindAll()
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Finding Task dependencies failed: %s\n", err)
+		return TaskDependencies{}, err
+	}
+
+	return newDependencyGraph(tasks).dependencies(task.ID), nil
+}
+
 // UpdateFields is struct which contains only allowed fields for Task in
 // Update flow. Notice that it contains pointers: if value field is not nil
 // then it will set the value.
 type UpdateFields struct {
-	Label     *string
-	Completed *bool
+	Label      *string
+	Completed  *bool
+	DueAt      *time.Time
+	StartAt    *time.Time
+	Priority   *int
+	Tags       *[]string
+	Notes      *string
+	Status     *Status
+	BlockedBy  *[]TaskID
+	Recurrence *string
+
+	// IfMatch contains entity tags from which one must match current ETag
+	// of the Task, otherwise Task is not updated. Nil means any Task.
+	IfMatch []string
 }
 
 // Update updates Task at given TaskID path with UpdateFields provided in
-// parameter. It updates only "set" fields (fields which are not nil).
+// parameter. It updates only "set" fields (fields which are not nil) and
+// increments Task revision. Completing of recurring Task creates its next
+// occurrence.
 // Update implements TaskService interface.
 func (s *TaskStorageService) Update(path []TaskID, fields UpdateFields) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Update is applied in transaction so Task doesn't stay updated when
+	// creating its next occurrence or propagating completion fails.
+	var task Task
+	_, err := s.transaction(OperationUpdate, 0, func() error {
+		var err error
+		task, err = s.updateTask(path, fields)
+		return err
+	})
+
+	return task, err
+}
+
+// updateTask updates Task at given TaskID path. Caller must hold the lock and
+// apply it in transaction.
+func (s *TaskStorageService) updateTask(path []TaskID, fields UpdateFields) (Task, error) {
 	oldVersionTask, err := s.storage.Find(path)
 	if err != nil {
 		fmt.Printf("(DEBUG) service: Updating existing Task failed: %s\n", err)
 		return Task{}, err
 	}
 
+	if !oldVersionTask.matchETag(fields.IfMatch) {
+		fmt.Println("(DEBUG) service: Updating existing Task failed. ETag does not match.")
+		return oldVersionTask, ErrTaskPreconditionFailed
+	}
+
 	newVersionTask := oldVersionTask
 
 	if fields.Label != nil {
 		newVersionTask.Label = *fields.Label
 	}
 
-	if fields.Completed != nil {
-		newVersionTask.Completed = *fields.Completed
+	// Status must be changed in the workflow.
+	current := oldVersionTask.status()
+	if err := NewTransitionValidator(s.workflow, current).Validate(&JSONTask{Status: fields.Status, Completed: fields.Completed}); err != nil {
+		fmt.Printf("(DEBUG) service: Updating existing Task failed: %s\n", err)
+		return oldVersionTask, err
+	}
+	newVersionTask.setStatus(targetStatus(current, fields.Status, fields.Completed))
+
+	if fields.DueAt != nil {
+		newVersionTask.DueAt = utcTime(fields.DueAt)
+	}
+
+	if fields.StartAt != nil {
+		newVersionTask.StartAt = utcTime(fields.StartAt)
 	}
 
-	if err := s.storage.Update(path, &newVersionTask); err != nil {
+	if fields.Priority != nil {
+		newVersionTask.Priority = *fields.Priority
+	}
+
+	if fields.Tags != nil {
+		newVersionTask.Tags = normalizeTags(*fields.Tags)
+	}
+
+	if fields.Notes != nil {
+		newVersionTask.Notes = *fields.Notes
+	}
+
+	if fields.BlockedBy != nil {
+		newVersionTask.BlockedBy = normalizeTaskIDs(*fields.BlockedBy)
+	}
+
+	if fields.Recurrence != nil {
+		newVersionTask.Recurrence = normalizeRecurrence(*fields.Recurrence)
+	}
+
+	return s.storeUpdate(path, &oldVersionTask, &newVersionTask)
+}
+
+// storeUpdate validates new version of the Task at given TaskID path against
+// the stored one and stores it as new revision. Completing of recurring Task
+// creates its next occurrence and completion is propagated in the tree. Status
+// of the new version must be already checked in the workflow. Changes are
+// stored before next occurrence is created and completion propagated so
+// caller must apply it in transaction. Caller must hold the lock.
+func (s *TaskStorageService) storeUpdate(path []TaskID, oldVersionTask, newVersionTask *Task) (Task, error) {
+	newVersionTask.Revision = oldVersionTask.Revision + 1
+
+	// Only one of the dates may be updated so they must be checked together
+	// with the stored one.
+	if !validStartAtDueAt(newVersionTask.StartAt, newVersionTask.DueAt) {
+		fmt.Println("(DEBUG) service: Updating existing Task failed. StartAt is after DueAt.")
+		return *oldVersionTask, ErrTaskStartAtAfterDueAt
+	}
+
+	if err := s.validateBlockers(newVersionTask, newVersionTask.Completed && !oldVersionTask.Completed); err != nil {
 		fmt.Printf("(DEBUG) service: Updating existing Task failed: %s\n", err)
-		return oldVersionTask, err
+		return *oldVersionTask, err
+	}
+	s.touch(newVersionTask, oldVersionTask.Completed)
+
+	recurrence := takeRecurrence(oldVersionTask, newVersionTask)
+
+	if err := s.storage.Update(path, newVersionTask); err != nil {
+		fmt.Printf("(DEBUG) service: Updating existing Task failed: %s\n", err)
+		return *oldVersionTask, err
+	}
+
+	if recurrence != "" {
+		if _, err := s.recur(path, newVersionTask, recurrence); err != nil {
+			fmt.Printf("(DEBUG) service: Creating next occurrence of updated Task failed: %s\n", err)
+			return Task{}, err
+		}
+	}
+
+	if newVersionTask.Completed == oldVersionTask.Completed {
+		return *newVersionTask, nil
+	}
+
+	if err := s.propagateCompletion(path, newVersionTask.Completed); err != nil {
+		fmt.Printf("(DEBUG) service: Propagating completion of updated Task failed: %s\n", err)
+		return Task{}, err
+	}
+
+	return s.storage.Find(path)
+}
+
+// PatchFields is struct which contains patch document for Patch flow.
+type PatchFields struct {
+	// Type is type of the patch document.
+	Type PatchType
+	// Patch is JSON Merge Patch or JSON Patch document.
+	Patch []byte
+
+	// IfMatch contains entity tags from which one must match current ETag
+	// of the Task, otherwise Task is not patched. Nil means any Task.
+	IfMatch []string
+}
+
+// Patch applies patch document on JSON document of Task at given TaskID path
+// (with its sub tasks). Changed Tasks are updated, sub tasks without ID are
+// created and sub tasks missing in patched document are moved into trash.
+// Whole patched document is validated before any change is stored.
+// Completing of recurring Task creates its next occurrence.
+// Patch implements TaskService interface.
+func (s *TaskStorageService) Patch(path []TaskID, fields PatchFields) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Patch is applied in transaction so it's rolled back when removing
+	// links or propagating completion fails.
+	var task Task
+	_, err := s.transaction(OperationPatch, 0, func() error {
+		var err error
+		task, err = s.patchTask(path, fields)
+		return err
+	})
+
+	return task, err
+}
+
+// patchTask applies patch document on Task at given TaskID path. Caller must
+// hold the lock and apply it in transaction.
+func (s *TaskStorageService) patchTask(path []TaskID, fields PatchFields) (Task, error) {
+	task, err := s.storage.Find(path)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Patching Task failed: %s\n", err)
+		return Task{}, err
+	}
+
+	if !task.matchETag(fields.IfMatch) {
+		fmt.Println("(DEBUG) service: Patching Task failed. ETag does not match.")
+		return task, ErrTaskPreconditionFailed
+	}
+
+	doc, err := taskDocument(&task)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Patching Task failed: %s\n", err)
+		return Task{}, err
+	}
+
+	patchedDoc, err := applyPatch(fields.Type, doc, fields.Patch)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Patching Task failed: %s\n", err)
+		return task, err
+	}
+
+	taskDoc, err := parseTaskDocument(patchedDoc)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Patching Task failed: %s\n", err)
+		return task, err
+	}
+
+	if err := validateTaskDocument(s.workflow, &task, taskDoc); err != nil {
+		fmt.Printf("(DEBUG) service: Patching Task failed: %s\n", err)
+		return task, err
+	}
+
+	if err := s.validateDocumentBlockers(taskDoc); err != nil {
+		fmt.Printf("(DEBUG) service: Patching Task failed: %s\n", err)
+		return task, err
+	}
+
+	recurring := []recurringTask{}
+	if err := s.applyTaskDocument(path, &task, taskDoc, &recurring); err != nil {
+		fmt.Printf("(DEBUG) service: Patching Task failed: %s\n", err)
+		return Task{}, err
+	}
+
+	// Sub tasks missing in the document were deleted so links to them must
+	// be removed.
+	patchedTask, err := s.storage.Find(path)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Patching Task failed: %s\n", err)
+		return Task{}, err
+	}
+
+	deleted, kept := map[TaskID]bool{}, map[TaskID]bool{}
+	task.collectTaskIDs(deleted)
+	patchedTask.collectTaskIDs(kept)
+	for taskID := range kept {
+		delete(deleted, taskID)
+	}
+
+	if err := s.removeBlockers(deleted); err != nil {
+		fmt.Printf("(DEBUG) service: Removing links to deleted sub tasks failed: %s\n", err)
+		return Task{}, err
+	}
+
+	// Next occurrences are created from patched Tasks with their patched
+	// sub tasks.
+	for _, r := range recurring {
+		completedTask, err := s.storage.Find(r.path)
+		if err != nil {
+			fmt.Printf("(DEBUG) service: Patching Task failed: %s\n", err)
+			return Task{}, err
+		}
+
+		if _, err := s.recur(r.path, &completedTask, r.rule); err != nil {
+			fmt.Printf("(DEBUG) service: Creating next occurrence of patched Task failed: %s\n", err)
+			return Task{}, err
+		}
+	}
+
+	// Completion policy is applied only on the patched Task, not on its sub
+	// tasks changed by the patch.
+	completed := targetStatus(task.status(), taskDoc.Status, taskDoc.Completed) == StatusDone
+	if completed != task.Completed {
+		if err := s.propagateCompletion(path, completed); err != nil {
+			fmt.Printf("(DEBUG) service: Propagating completion of patched Task failed: %s\n", err)
+			return Task{}, err
+		}
 	}
 
-	return newVersionTask, nil
+	return s.storage.Find(path)
 }
 
-// Delete removes Tasks at given TaskID path or error if Task is not found.
+// validateTaskDocument validates patched document of given Task (nil for new
+// Task) with all sub tasks. Existing Tasks must keep ID and revision, their
+// status must be changed in the workflow and sub tasks with ID must be
+// children of the Task.
+func validateTaskDocument(workflow Workflow, task *Task, doc *JSONTaskDocument) error {
+	if err := doc.Validate(NewPatchValidator()); err != nil {
+		return err
+	}
+
+	if task != nil {
+		if err := doc.Validate(NewTransitionValidator(workflow, task.status())); err != nil {
+			return err
+		}
+	}
+
+	if task == nil {
+		if doc.ID != nil || doc.Revision != nil || doc.Position != nil ||
+			doc.CreatedAt != nil || doc.UpdatedAt != nil || doc.CompletedAt != nil {
+			return ErrTaskFieldIsReadOnly
+		}
+	} else {
+		if (doc.ID != nil && *doc.ID != task.ID) ||
+			(doc.Revision != nil && *doc.Revision != task.Revision) ||
+			(doc.Position != nil && *doc.Position != task.Position) ||
+			!sameDocumentTime(doc.CreatedAt, task.CreatedAt) ||
+			!sameDocumentTime(doc.UpdatedAt, task.UpdatedAt) ||
+			!sameDocumentTime(doc.CompletedAt, task.CompletedAt) {
+			return ErrTaskFieldIsReadOnly
+		}
+	}
+
+	seen := map[TaskID]bool{}
+	for _, childDoc := range doc.Children {
+		if childDoc == nil {
+			return ErrTaskPatchNotValid
+		}
+
+		var child *Task
+		if task != nil && childDoc.ID != nil {
+			found := false
+			if child, found = task.Children[*childDoc.ID]; !found || seen[*childDoc.ID] {
+				return ErrTaskPatchNotValid
+			}
+			seen[*childDoc.ID] = true
+		}
+
+		if err := validateTaskDocument(workflow, child, childDoc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sameDocumentTime returns true if read-only time in patched document is not
+// set or is equal to the time of the Task.
+func sameDocumentTime(docTime, taskTime *time.Time) bool {
+	return docTime == nil || (taskTime != nil && docTime.Equal(*taskTime))
+}
+
+// recurringTask is recurring Task completed by patch with its recurrence
+// rule. Its next occurrence is created after the whole patch is applied so it
+// doesn't take position of new sub tasks.
+type recurringTask struct {
+	path []TaskID
+	rule string
+}
+
+// applyTaskDocument stores changes from validated patched document of Task
+// at given TaskID path. Recurring Tasks completed by the document are added
+// to recurring.
+func (s *TaskStorageService) applyTaskDocument(path []TaskID, task *Task, doc *JSONTaskDocument, recurring *[]recurringTask) error {
+	updatedTask := task.withChildren(nil)
+	updatedTask.Label = *doc.Label
+	updatedTask.setStatus(targetStatus(task.status(), doc.Status, doc.Completed))
+	updatedTask.DueAt = utcTime(doc.DueAt)
+	updatedTask.StartAt = utcTime(doc.StartAt)
+	updatedTask.Recurrence = ""
+	if doc.Recurrence != nil {
+		updatedTask.Recurrence = normalizeRecurrence(*doc.Recurrence)
+	}
+	updatedTask.Priority = PriorityNone
+	if doc.Priority != nil {
+		updatedTask.Priority = *doc.Priority
+	}
+	updatedTask.Tags = nil
+	if doc.Tags != nil {
+		updatedTask.Tags = normalizeTags(*doc.Tags)
+	}
+	updatedTask.Notes = ""
+	if doc.Notes != nil {
+		updatedTask.Notes = *doc.Notes
+	}
+	updatedTask.BlockedBy = nil
+	if doc.BlockedBy != nil {
+		updatedTask.BlockedBy = normalizeTaskIDs(*doc.BlockedBy)
+	}
+
+	if !updatedTask.sameFields(task) {
+		s.touch(updatedTask, task.Completed)
+		updatedTask.Revision++
+		if rule := takeRecurrence(task, updatedTask); rule != "" {
+			*recurring = append(*recurring, recurringTask{path: path, rule: rule})
+		}
+		if err := s.storage.Update(path, updatedTask); err != nil {
+			return err
+		}
+	}
+
+	// New sub tasks are placed after existing ones in order of the document.
+	position := maxPosition(task.Children.list())
+	kept := map[TaskID]bool{}
+	for _, childDoc := range doc.Children {
+		if childDoc.ID == nil {
+			position++
+			if err := s.storage.Insert(path, s.newTaskFromDocument(childDoc, position)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		kept[*childDoc.ID] = true
+		if err := s.applyTaskDocument(childPath(path, *childDoc.ID), task.Children[*childDoc.ID], childDoc, recurring); err != nil {
+			return err
+		}
+	}
+
+	for taskID, child := range task.Children {
+		if !kept[taskID] {
+			if err := s.trash(childPath(path, taskID), child); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// newTaskFromDocument creates new Task at given position with new TaskIDs
+// (including its sub tasks) from validated patched document. Sub tasks are
+// positioned in order of the document.
+func (s *TaskStorageService) newTaskFromDocument(doc *JSONTaskDocument, position int) *Task {
+	newTask := &Task{
+		ID:       s.storage.NextTaskID(),
+		Label:    *doc.Label,
+		DueAt:    utcTime(doc.DueAt),
+		StartAt:  utcTime(doc.StartAt),
+		Position: position,
+		Revision: 1,
+		Children: SubTasks{},
+	}
+
+	newTask.setStatus(targetStatus(StatusTodo, doc.Status, doc.Completed))
+
+	if doc.Priority != nil {
+		newTask.Priority = *doc.Priority
+	}
+
+	if doc.Tags != nil {
+		newTask.Tags = normalizeTags(*doc.Tags)
+	}
+
+	if doc.Notes != nil {
+		newTask.Notes = *doc.Notes
+	}
+
+	if doc.BlockedBy != nil {
+		newTask.BlockedBy = normalizeTaskIDs(*doc.BlockedBy)
+	}
+
+	if doc.Recurrence != nil {
+		newTask.Recurrence = normalizeRecurrence(*doc.Recurrence)
+	}
+	s.created(newTask)
+
+	for i, childDoc := range doc.Children {
+		child := s.newTaskFromDocument(childDoc, i+1)
+		newTask.Children[child.ID] = child
+	}
+
+	return newTask
+}
+
+// DeleteFields is struct which contains options for Delete flow.
+type DeleteFields struct {
+	// IfMatch contains entity tags from which one must match current ETag
+	// of the Task, otherwise Task is not deleted. Nil means any Task.
+	IfMatch []string
+}
+
+// Delete moves Task at given TaskID path with its children into trash or
+// returns error if Task is not found. Trashed Task can be restored or purged.
+// Links to deleted Tasks are removed from their blocked Tasks.
 // Delete implements TaskService interface.
-func (s *TaskStorageService) Delete(path []TaskID) (Task, error) {
+func (s *TaskStorageService) Delete(path []TaskID, fields DeleteFields) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Delete is applied in transaction so the Task isn't left in trash with
+	// links to it when removing the links fails.
+	var task Task
+	_, err := s.transaction(OperationDelete, 0, func() error {
+		var err error
+		task, err = s.deleteTask(path, fields)
+		return err
+	})
+
+	return task, err
+}
+
+// deleteTask moves Task at given TaskID path into trash. Caller must hold
+// the lock and apply it in transaction.
+func (s *TaskStorageService) deleteTask(path []TaskID, fields DeleteFields) (Task, error) {
 	task, err := s.storage.Find(path)
 	if err != nil {
 		fmt.Printf("(DEBUG) service: Deleting Task failed: %s\n", err)
 		return Task{}, err
 	}
 
-	if err := s.storage.Delete(path); err != nil {
+	if !task.matchETag(fields.IfMatch) {
+		fmt.Println("(DEBUG) service: Deleting Task failed. ETag does not match.")
+		return task, ErrTaskPreconditionFailed
+	}
+
+	if err := s.trash(path, &task); err != nil {
 		fmt.Printf("(DEBUG) service: Deleting Task failed: %s\n", err)
 		return Task{}, err
 	}
 
+	deleted := map[TaskID]bool{}
+	task.collectTaskIDs(deleted)
+	if err := s.removeBlockers(deleted); err != nil {
+		fmt.Printf("(DEBUG) service: Removing links to deleted Task failed: %s\n", err)
+		return Task{}, err
+	}
+
 	return task, nil
 }
+
+// Move moves Task at from TaskID path with all its children under Task at
+// toParent TaskID path (or to top level if toParent is empty). Task keeps its
+// TaskID and is placed after its new siblings. Moving Task under itself or its
+// sub task is not allowed.
+// Move implements TaskService interface.
+func (s *TaskStorageService) Move(from []TaskID, toParent []TaskID) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Move is applied in transaction so the Task isn't left under its new
+	// parent with its old position when storing the position fails.
+	var task Task
+	_, err := s.transaction(OperationMove, 0, func() error {
+		var err error
+		task, err = s.moveTask(from, toParent)
+		return err
+	})
+
+	return task, err
+}
+
+// moveTask moves Task at from TaskID path under Task at toParent TaskID
+// path. Caller must hold the lock and apply it in transaction.
+func (s *TaskStorageService) moveTask(from []TaskID, toParent []TaskID) (Task, error) {
+	position, err := s.nextPosition(toParent)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Moving Task failed: %s\n", err)
+		return Task{}, err
+	}
+
+	if err := s.storage.Move(from, toParent); err != nil {
+		fmt.Printf("(DEBUG) service: Moving Task failed: %s\n", err)
+		return Task{}, err
+	}
+
+	newPath := childPath(toParent, from[len(from)-1])
+	task, err := s.storage.Find(newPath)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Moving Task failed: %s\n", err)
+		return Task{}, err
+	}
+
+	// Parent of the Task changed so it's new revision of the Task.
+	task.Position = position
+	task.Revision++
+	s.touch(&task, task.Completed)
+	if err := s.storage.Update(newPath, &task); err != nil {
+		fmt.Printf("(DEBUG) service: Moving Task failed: %s\n", err)
+		return Task{}, err
+	}
+
+	return task, nil
+}
+
+// CloneFields is struct which contains options for Clone flow.
+type CloneFields struct {
+	// ResetCompleted marks all copied Tasks as not completed.
+	ResetCompleted bool
+}
+
+// Clone copies Task at path with all its children and subchildren under Task
+// at toParent TaskID path (or to top level if toParent is empty). Every copied
+// Task gets new TaskID from TaskStorage. Links between copied Tasks are
+// changed to link the copies. It returns the copy of the Task and mapping of
+// original TaskIDs to the new ones.
+// Clone implements TaskService interface.
+func (s *TaskStorageService) Clone(path []TaskID, toParent []TaskID, fields CloneFields) (Task, map[TaskID]TaskID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.operation(OperationClone)()
+
+	task, err := s.storage.Find(path)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Cloning Task failed: %s\n", err)
+		return Task{}, nil, err
+	}
+
+	position, err := s.nextPosition(toParent)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Cloning Task failed: %s\n", err)
+		return Task{}, nil, err
+	}
+
+	ids := map[TaskID]TaskID{}
+	newTask := s.cloneTree(&task, fields, ids)
+	newTask.Position = position
+	remapBlockers(newTask, ids)
+
+	if err := s.storage.Insert(toParent, newTask); err != nil {
+		fmt.Printf("(DEBUG) service: Cloning Task failed: %s\n", err)
+		return Task{}, nil, err
+	}
+
+	return *newTask, ids, nil
+}
+
+// cloneTree returns copy of given Task and its children with new TaskIDs.
+// Children get their TaskIDs in order of original TaskIDs. Mapping of
+// original TaskIDs to new ones is stored in ids.
+func (s *TaskStorageService) cloneTree(task *Task, fields CloneFields, ids map[TaskID]TaskID) *Task {
+	newTask := task.withChildren(SubTasks{})
+	newTask.ID = s.storage.NextTaskID()
+	newTask.Revision = 1
+	if fields.ResetCompleted && newTask.Completed {
+		newTask.setStatus(StatusTodo)
+	}
+	s.created(newTask)
+	if newTask.Completed {
+		// Copy of completed Task keeps time when the original was completed.
+		newTask.CompletedAt = task.CompletedAt
+	}
+	ids[task.ID] = newTask.ID
+
+	children := task.Children.list()
+	sort.Sort(ByTaskID(children))
+
+	for i := range children {
+		newChild := s.cloneTree(&children[i], fields, ids)
+		newTask.Children[newChild.ID] = newChild
+	}
+
+	return newTask
+}
+
+// Reorder changes positions of sub tasks of Task at given TaskID path to
+// given order. Order must contain TaskID of every sub task exactly once. Only
+// sub tasks with changed position get new revision. It returns the Task with
+// reordered sub tasks.
+// Reorder implements TaskService interface.
+func (s *TaskStorageService) Reorder(path []TaskID, order []TaskID) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.operation(OperationReorder)()
+
+	task, err := s.storage.Find(path)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Reordering Task failed: %s\n", err)
+		return Task{}, err
+	}
+
+	if len(order) != len(task.Children) {
+		fmt.Println("(DEBUG) service: Reordering Task failed. Order does not contain all sub tasks.")
+		return task, ErrTaskReorderNotValid
+	}
+
+	seen := map[TaskID]bool{}
+	for _, taskID := range order {
+		if _, found := task.Children[taskID]; !found || seen[taskID] {
+			fmt.Println("(DEBUG) service: Reordering Task failed. Order contains unknown or duplicate TaskID.")
+			return task, ErrTaskReorderNotValid
+		}
+		seen[taskID] = true
+	}
+
+	for i, taskID := range order {
+		child := task.Children[taskID]
+		if child.Position == i+1 {
+			continue
+		}
+
+		updatedChild := child.withChildren(nil)
+		updatedChild.Position = i + 1
+		updatedChild.Revision++
+		s.touch(updatedChild, updatedChild.Completed)
+		if err := s.storage.Update(childPath(path, taskID), updatedChild); err != nil {
+			fmt.Printf("(DEBUG) service: Reordering Task failed: %s\n", err)
+			return Task{}, err
+		}
+	}
+
+	return s.storage.Find(path)
+}
+
+// nextPosition returns position after the last sub task of Task at given
+// TaskID path (or after the last top level Task if path is empty).
+func (s *TaskStorageService) nextPosition(path []TaskID) (int, error) {
+	if len(path) == 0 {
+		tasks, err := s.storage.FindAll()
+		if err != nil {
+			return 0, err
+		}
+
+		return maxPosition(tasks) + 1, nil
+	}
+
+	task, err := s.storage.Find(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return maxPosition(task.Children.list()) + 1, nil
+}
+
+// created sets timestamps of new Task to current time.
+func (s *TaskStorageService) created(task *Task) {
+	now := s.clock.Now().UTC()
+	task.CreatedAt = &now
+	task.UpdatedAt = &now
+	task.CompletedAt = nil
+	if task.Completed {
+		task.CompletedAt = &now
+	}
+}
+
+// touch sets UpdatedAt of changed Task to current time. CompletedAt is set
+// when Task was completed by the change and removed when Task is not
+// completed.
+func (s *TaskStorageService) touch(task *Task, wasCompleted bool) {
+	now := s.clock.Now().UTC()
+	task.UpdatedAt = &now
+	if !task.Completed {
+		task.CompletedAt = nil
+	} else if !wasCompleted {
+		task.CompletedAt = &now
+	}
+}
+
+// childPath returns new TaskID path of child Task with given TaskID under
+// Task at given TaskID path.
+func childPath(path []TaskID, taskID TaskID) []TaskID {
+	return append(append([]TaskID{}, path...), taskID)
+}
+
+// utcTime returns copy of given time in UTC or nil if time is not set. Times
+// are stored in UTC so they can be compared.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	utc := t.UTC()
+	return &utc
+}
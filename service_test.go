@@ -1,6 +1,15 @@
 package tasks
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
 
 func TestTaskServiceCreate(t *testing.T) {
 	t1 := &Task{
@@ -55,8 +64,9 @@ func TestTaskServiceCreate(t *testing.T) {
 
 		storage := NewTaskMemoryStorage()
 		storage.storage = tc.storage
+		storage.reindex()
 		storage.lastTaskID = tc.lastTaskID
-		service := NewTaskStorageService(storage)
+		service := NewTaskStorageService(storage, &mockClock{})
 
 		res, err := service.Create(tc.path, tc.fields)
 		if err != tc.err {
@@ -81,6 +91,78 @@ func TestTaskServiceCreate(t *testing.T) {
 	}
 }
 
+func TestTaskServiceCreateSubTasks(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := service.Create([]TaskID{foo.ID}, CreateFields{
+		Label: "checklist",
+		SubTasks: []CreateFields{
+			CreateFields{Label: "first", SubTasks: []CreateFields{CreateFields{Label: "nested"}}},
+			CreateFields{Label: "second", Status: StatusDone, BlockedBy: []TaskID{foo.ID}},
+		},
+	})
+	if err != ErrTaskBlockedByOpenTask {
+		t.Fatalf("expected error %v got %v", ErrTaskBlockedByOpenTask, err)
+	}
+
+	res, err = service.Create([]TaskID{foo.ID}, CreateFields{
+		Label: "checklist",
+		SubTasks: []CreateFields{
+			CreateFields{Label: "first", SubTasks: []CreateFields{CreateFields{Label: "nested"}}},
+			CreateFields{Label: "second", BlockedBy: []TaskID{foo.ID}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := service.Find([]TaskID{foo.ID, res.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// labels returns labels of the Tasks in the subtree in depth first order
+	// with their positions.
+	var labels func(tasks []Task) []string
+	labels = func(tasks []Task) []string {
+		sort.Sort(ByPosition(tasks))
+
+		result := []string{}
+		for _, task := range tasks {
+			if task.ID <= foo.ID || task.Revision != 1 || task.CreatedAt == nil {
+				t.Fatalf("expected new Task got %v", task)
+			}
+			result = append(result, fmt.Sprintf("%d:%s", task.Position, task.Label))
+			result = append(result, labels(task.Children.list())...)
+		}
+
+		return result
+	}
+
+	expected := []string{"1:checklist", "1:first", "1:nested", "2:second"}
+	if res := labels([]Task{stored}); !reflect.DeepEqual(expected, res) {
+		t.Fatalf("expected Tasks %v got %v", expected, res)
+	}
+
+	if !reflect.DeepEqual(res.Children, stored.Children) {
+		t.Fatalf("expected created Task %v got %v", stored, res)
+	}
+
+	// Whole subtree is one change so undo removes it.
+	if _, err := service.Undo(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Find([]TaskID{foo.ID, res.ID}); err != ErrTaskNotFound {
+		t.Fatalf("expected error %v got %v", ErrTaskNotFound, err)
+	}
+}
+
 func TestTaskServiceFind(t *testing.T) {
 	t.Skip("No business logic")
 }
@@ -167,7 +249,8 @@ func TestTaskServiceUpdate(t *testing.T) {
 
 		storage := NewTaskMemoryStorage()
 		storage.storage = tc.storage
-		service := NewTaskStorageService(storage)
+		storage.reindex()
+		service := NewTaskStorageService(storage, &mockClock{})
 
 		res, err := service.Update(tc.path, tc.fields)
 		if err != tc.err {
@@ -192,6 +275,1227 @@ func TestTaskServiceUpdate(t *testing.T) {
 	}
 }
 
-func TestTaskServiceDelete(t *testing.T) {
-	t.Skip("No business logic")
+func TestTaskServiceUpdateIfMatch(t *testing.T) {
+	storage := NewTaskMemoryStorage()
+	service := NewTaskStorageService(storage, &mockClock{})
+
+	task, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := []TaskID{task.ID}
+
+	if task.Revision != 1 {
+		t.Fatalf("expected revision %d got %d", 1, task.Revision)
+	}
+
+	bar := "bar"
+	if _, err := service.Update(path, UpdateFields{Label: &bar, IfMatch: []string{`"foo"`}}); err != ErrTaskPreconditionFailed {
+		t.Fatalf("expected err %s got %s", ErrTaskPreconditionFailed, err)
+	}
+
+	res, err := service.Update(path, UpdateFields{Label: &bar, IfMatch: []string{`"foo"`, task.ETag()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Revision != 2 {
+		t.Fatalf("expected revision %d got %d", 2, res.Revision)
+	}
+
+	if _, err := service.Delete(path, DeleteFields{IfMatch: []string{task.ETag()}}); err != ErrTaskPreconditionFailed {
+		t.Fatalf("expected err %s got %s", ErrTaskPreconditionFailed, err)
+	}
+
+	if _, err := service.Delete(path, DeleteFields{IfMatch: []string{res.ETag()}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTaskServiceDueAt(t *testing.T) {
+	now := time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC)
+	service := NewTaskStorageService(NewTaskMemoryStorage(), &mockClock{now: now})
+
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo", DueAt: &past})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bar, err := service.Create([]TaskID{foo.ID}, CreateFields{Label: "bar", DueAt: &past})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Create([]TaskID{foo.ID}, CreateFields{Label: "baz", DueAt: &future}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only StartAt is updated, it must be checked against stored DueAt.
+	if _, err := service.Update([]TaskID{foo.ID, bar.ID}, UpdateFields{StartAt: &future}); err != ErrTaskStartAtAfterDueAt {
+		t.Fatalf("expected err %s got %s", ErrTaskStartAtAfterDueAt, err)
+	}
+
+	completed := true
+	if _, err := service.Update([]TaskID{foo.ID}, UpdateFields{Completed: &completed}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := service.FindByFilter(TaskFilter{Overdue: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []TaskWithPath{
+		TaskWithPath{
+			Path: TaskIDPath{foo.ID, bar.ID},
+			Task: Task{ID: bar.ID, Label: "bar", Status: StatusTodo, DueAt: &past, Position: 1, CreatedAt: &now, UpdatedAt: &now, Revision: 1},
+		},
+	}
+	if !reflect.DeepEqual(expected, res) {
+		t.Fatalf("expected tasks %v got %v", expected, res)
+	}
+}
+
+func TestTaskServiceTimestamps(t *testing.T) {
+	clock := &mockClock{now: time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC)}
+	service := NewTaskStorageService(NewTaskMemoryStorage(), clock)
+
+	created := clock.now
+	task, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := []TaskID{task.ID}
+
+	check := func(task Task, createdAt, updatedAt time.Time, completedAt *time.Time) {
+		if task.CreatedAt == nil || !task.CreatedAt.Equal(createdAt) {
+			t.Fatalf("expected created_at %s got %v", createdAt, task.CreatedAt)
+		}
+
+		if task.UpdatedAt == nil || !task.UpdatedAt.Equal(updatedAt) {
+			t.Fatalf("expected updated_at %s got %v", updatedAt, task.UpdatedAt)
+		}
+
+		if !reflect.DeepEqual(completedAt, task.CompletedAt) {
+			t.Fatalf("expected completed_at %v got %v", completedAt, task.CompletedAt)
+		}
+	}
+	check(task, created, created, nil)
+
+	clock.now = clock.now.Add(time.Hour)
+	completed := true
+	task, err = service.Update(path, UpdateFields{Completed: &completed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	completedAt := clock.now
+	check(task, created, completedAt, &completedAt)
+
+	// Completing completed Task keeps the time when it was completed.
+	clock.now = clock.now.Add(time.Hour)
+	task, err = service.Update(path, UpdateFields{Completed: &completed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(task, created, clock.now, &completedAt)
+
+	clock.now = clock.now.Add(time.Hour)
+	task, err = service.Patch(path, PatchFields{Type: PatchTypeMerge, Patch: []byte(`{"completed":false}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(task, created, clock.now, nil)
+
+	if _, err := service.Patch(path, PatchFields{Type: PatchTypeMerge, Patch: []byte(`{"created_at":"2000-01-01T00:00:00Z"}`)}); err != ErrTaskFieldIsReadOnly {
+		t.Fatalf("expected err %s got %s", ErrTaskFieldIsReadOnly, err)
+	}
+}
+
+func TestTaskServiceTags(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), &mockClock{})
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo", Tags: []string{"urgent", "backend", "urgent"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual([]string{"backend", "urgent"}, foo.Tags) {
+		t.Fatalf("expected tags %v got %v", []string{"backend", "urgent"}, foo.Tags)
+	}
+
+	if _, err := service.Create([]TaskID{foo.ID}, CreateFields{Label: "bar", Tags: []string{"backend"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	noTags := []string{}
+	res, err := service.Update([]TaskID{foo.ID}, UpdateFields{Tags: &noTags})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Tags != nil {
+		t.Fatalf("expected no tags got %v", res.Tags)
+	}
+
+	tags, err := service.FindTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []TagCount{TagCount{Tag: "backend", Count: 1}}
+	if !reflect.DeepEqual(expected, tags) {
+		t.Fatalf("expected tags %v got %v", expected, tags)
+	}
+}
+
+func TestTaskServiceDependencies(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), &mockClock{})
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bar, err := service.Create([]TaskID{foo.ID}, CreateFields{Label: "bar", BlockedBy: []TaskID{foo.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	baz, err := service.Create([]TaskID{}, CreateFields{Label: "baz", BlockedBy: []TaskID{bar.ID, bar.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(TaskIDPath{bar.ID}, baz.BlockedBy) {
+		t.Fatalf("expected blockers %v got %v", TaskIDPath{bar.ID}, baz.BlockedBy)
+	}
+
+	if _, err := service.Create([]TaskID{}, CreateFields{Label: "qux", BlockedBy: []TaskID{TaskID(42)}}); err != ErrTaskBlockerNotFound {
+		t.Fatalf("expected error %v got %v", ErrTaskBlockerNotFound, err)
+	}
+
+	cycle := []TaskID{baz.ID}
+	if _, err := service.Update([]TaskID{foo.ID}, UpdateFields{BlockedBy: &cycle}); err != ErrTaskDependencyCycle {
+		t.Fatalf("expected error %v got %v", ErrTaskDependencyCycle, err)
+	}
+
+	completed := true
+	if _, err := service.Update([]TaskID{baz.ID}, UpdateFields{Completed: &completed}); err != ErrTaskBlockedByOpenTask {
+		t.Fatalf("expected error %v got %v", ErrTaskBlockedByOpenTask, err)
+	}
+
+	// Patch may complete blocker together with blocked Task.
+	patch := []byte(fmt.Sprintf(`{"completed":true,"sub_tasks":[{"id":"%d","label":"bar","completed":true,"blocked_by":["%d"]}]}`, bar.ID, foo.ID))
+	if _, err := service.Patch([]TaskID{foo.ID}, PatchFields{Type: PatchTypeMerge, Patch: patch}); err != nil {
+		t.Fatal(err)
+	}
+
+	deps, err := service.FindDependencies([]TaskID{foo.ID, bar.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deps.Upstream) != 1 || deps.Upstream[0].Task.ID != foo.ID {
+		t.Fatalf("expected upstream Task %d got %v", foo.ID, deps.Upstream)
+	}
+
+	if len(deps.Downstream) != 1 || deps.Downstream[0].Task.ID != baz.ID {
+		t.Fatalf("expected downstream Task %d got %v", baz.ID, deps.Downstream)
+	}
+
+	if _, err := service.Delete([]TaskID{foo.ID}, DeleteFields{}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := service.Find([]TaskID{baz.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.BlockedBy != nil || res.Revision != 2 {
+		t.Fatalf("expected no blockers in revision 2 got %v in revision %d", res.BlockedBy, res.Revision)
+	}
+}
+
+func TestTaskServiceRecurrence(t *testing.T) {
+	now := time.Date(2020, 1, 6, 9, 0, 0, 0, time.UTC)
+	dueAt := time.Date(2020, 1, 7, 17, 0, 0, 0, time.UTC)
+	startAt := dueAt.Add(-2 * time.Hour)
+	service := NewTaskStorageService(NewTaskMemoryStorage(), &mockClock{now: now})
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bar, err := service.Create([]TaskID{foo.ID}, CreateFields{Label: "bar", DueAt: &dueAt, StartAt: &startAt, Recurrence: "rrule:freq=weekly;byday=th,tu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bar.Recurrence != "FREQ=WEEKLY;BYDAY=TU,TH" {
+		t.Fatalf("expected recurrence %q got %q", "FREQ=WEEKLY;BYDAY=TU,TH", bar.Recurrence)
+	}
+
+	if _, err := service.Create([]TaskID{foo.ID, bar.ID}, CreateFields{Label: "baz", Status: StatusDone}); err != nil {
+		t.Fatal(err)
+	}
+
+	completed := true
+	res, err := service.Update([]TaskID{foo.ID, bar.ID}, UpdateFields{Completed: &completed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Completed || res.Recurrence != "" {
+		t.Fatalf("expected completed Task without recurrence got %v", res)
+	}
+
+	parent, err := service.Find([]TaskID{foo.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parent.Children) != 2 {
+		t.Fatalf("expected 2 sub tasks got %d", len(parent.Children))
+	}
+
+	var next *Task
+	for taskID, child := range parent.Children {
+		if taskID != bar.ID {
+			next = child
+		}
+	}
+
+	expDueAt := time.Date(2020, 1, 9, 17, 0, 0, 0, time.UTC)
+	expStartAt := expDueAt.Add(-2 * time.Hour)
+	if next.Label != "bar" || next.Completed || next.Position != 2 || next.Recurrence != bar.Recurrence {
+		t.Fatalf("expected next occurrence of %v got %v", bar, next)
+	}
+
+	if !expDueAt.Equal(*next.DueAt) || !expStartAt.Equal(*next.StartAt) {
+		t.Fatalf("expected dates %s and %s got %s and %s", expStartAt, expDueAt, next.StartAt, next.DueAt)
+	}
+
+	if len(next.Children) != 1 {
+		t.Fatalf("expected 1 sub task got %d", len(next.Children))
+	}
+
+	for _, child := range next.Children {
+		if child.Label != "baz" || child.Completed {
+			t.Fatalf("expected not completed copy of sub task got %v", child)
+		}
+	}
+}
+
+func TestTaskServiceRecurrenceCompletion(t *testing.T) {
+	completed := true
+	tests := map[string]struct {
+		policy   CompletionPolicy
+		complete func(service *TaskStorageService, foo, bar, baz TaskID) error
+	}{
+		"patch": {
+			complete: func(service *TaskStorageService, foo, bar, baz TaskID) error {
+				_, err := service.Patch([]TaskID{foo, bar}, PatchFields{Type: PatchTypeMerge, Patch: []byte(`{"status":"done"}`)})
+				return err
+			},
+		},
+		"cascade down": {
+			policy: CompletionPolicy{CascadeDown: true},
+			complete: func(service *TaskStorageService, foo, bar, baz TaskID) error {
+				_, err := service.Update([]TaskID{foo}, UpdateFields{Completed: &completed})
+				return err
+			},
+		},
+		"complete parent": {
+			policy: CompletionPolicy{CompleteParent: true},
+			complete: func(service *TaskStorageService, foo, bar, baz TaskID) error {
+				_, err := service.Update([]TaskID{foo, bar, baz}, UpdateFields{Completed: &completed})
+				return err
+			},
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		dueAt := time.Date(2020, 1, 7, 17, 0, 0, 0, time.UTC)
+		service := NewTaskStorageService(NewTaskMemoryStorage(), &mockClock{now: time.Date(2020, 1, 6, 9, 0, 0, 0, time.UTC)})
+		service.SetCompletionPolicy(tc.policy)
+
+		foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bar, err := service.Create([]TaskID{foo.ID}, CreateFields{Label: "bar", DueAt: &dueAt, Recurrence: "FREQ=WEEKLY"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		baz, err := service.Create([]TaskID{foo.ID, bar.ID}, CreateFields{Label: "baz"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := tc.complete(service, foo.ID, bar.ID, baz.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		parent, err := service.Find([]TaskID{foo.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(parent.Children) != 2 {
+			t.Fatalf("expected 2 sub tasks got %d", len(parent.Children))
+		}
+
+		if task := parent.Children[bar.ID]; !task.Completed || task.Recurrence != "" {
+			t.Fatalf("expected completed Task without recurrence got %v", task)
+		}
+
+		expDueAt := time.Date(2020, 1, 14, 17, 0, 0, 0, time.UTC)
+		for taskID, next := range parent.Children {
+			if taskID == bar.ID {
+				continue
+			}
+
+			if next.Completed || next.Recurrence != bar.Recurrence || !expDueAt.Equal(*next.DueAt) || len(next.Children) != 1 {
+				t.Fatalf("expected next occurrence of %v got %v", bar, next)
+			}
+		}
+	}
+}
+
+// failingInsertStorage is TaskMemoryStorage which fails Insert when fail is
+// set.
+type failingInsertStorage struct {
+	*TaskMemoryStorage
+
+	fail bool
+}
+
+// Insert fails or stores new Task.
+func (s *failingInsertStorage) Insert(path []TaskID, task *Task) error {
+	if s.fail {
+		return errors.New("insert failed")
+	}
+
+	return s.TaskMemoryStorage.Insert(path, task)
+}
+
+func TestTaskServiceRecurrenceRollback(t *testing.T) {
+	storage := &failingInsertStorage{TaskMemoryStorage: NewTaskMemoryStorage()}
+	service := NewTaskStorageService(storage, &mockClock{now: time.Date(2020, 1, 6, 9, 0, 0, 0, time.UTC)})
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo", Recurrence: "FREQ=DAILY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := storage.FindAllHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Creating next occurrence fails after the Task was completed.
+	storage.fail = true
+	completed := true
+	if _, err := service.Update([]TaskID{foo.ID}, UpdateFields{Completed: &completed}); err == nil {
+		t.Fatal("expected update to fail")
+	}
+
+	res, err := service.Find([]TaskID{foo.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Completed || res.Revision != foo.Revision || res.Recurrence != foo.Recurrence {
+		t.Fatalf("expected not changed Task %v got %v", foo, res)
+	}
+
+	if res, _ := storage.FindAllHistory(); !reflect.DeepEqual(history, res) {
+		t.Fatalf("expected history %v got %v", history, res)
+	}
+}
+
+func TestTaskServiceReorder(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), &mockClock{})
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := []TaskID{foo.ID}
+
+	children := []Task{}
+	for i, label := range []string{"bar", "baz", "qux"} {
+		child, err := service.Create(path, CreateFields{Label: label})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if child.Position != i+1 {
+			t.Fatalf("expected position %d got %d", i+1, child.Position)
+		}
+		children = append(children, child)
+	}
+	bar, baz, qux := children[0], children[1], children[2]
+
+	tests := map[string][]TaskID{
+		"missing":   []TaskID{qux.ID, bar.ID},
+		"duplicate": []TaskID{qux.ID, bar.ID, bar.ID},
+		"unknown":   []TaskID{qux.ID, bar.ID, foo.ID},
+	}
+
+	for desc, order := range tests {
+		t.Log(desc)
+
+		if _, err := service.Reorder(path, order); err != ErrTaskReorderNotValid {
+			t.Fatalf("expected err %s got %s", ErrTaskReorderNotValid, err)
+		}
+	}
+
+	res, err := service.Reorder(path, []TaskID{qux.ID, bar.ID, baz.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[TaskID][2]int{
+		qux.ID: {1, 2},
+		bar.ID: {2, 2},
+		baz.ID: {3, 2},
+	}
+	for taskID, child := range res.Children {
+		if [2]int{child.Position, child.Revision} != expected[taskID] {
+			t.Fatalf("expected position and revision %v got %v", expected[taskID], [2]int{child.Position, child.Revision})
+		}
+	}
+
+	order := []TaskID{}
+	sorted := res.Children.list()
+	sort.Sort(ByPosition(sorted))
+	for _, child := range sorted {
+		order = append(order, child.ID)
+	}
+
+	if !reflect.DeepEqual([]TaskID{qux.ID, bar.ID, baz.ID}, order) {
+		t.Fatalf("expected order %v got %v", []TaskID{qux.ID, bar.ID, baz.ID}, order)
+	}
+
+	moved, err := service.Move(childPath(path, bar.ID), []TaskID{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if moved.Position != 2 {
+		t.Fatalf("expected position %d got %d", 2, moved.Position)
+	}
+}
+
+func TestTaskServiceUpdateConcurrent(t *testing.T) {
+	storage := NewTaskMemoryStorage()
+	service := NewTaskStorageService(storage, &mockClock{})
+
+	root, err := service.Create([]TaskID{}, CreateFields{Label: "root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := []TaskID{root.ID}
+
+	const updates = 200
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < updates; i++ {
+			label := fmt.Sprintf("label-%d", i)
+			if _, err := service.Update(path, UpdateFields{Label: &label}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < updates; i++ {
+			completed := i%2 == 1
+			if _, err := service.Update(path, UpdateFields{Completed: &completed}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < updates; i++ {
+			if _, err := service.Create(path, CreateFields{Label: "child"}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	res, err := service.Find(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expLabel := fmt.Sprintf("label-%d", updates-1); res.Label != expLabel {
+		t.Fatalf("expected label %s got %s", expLabel, res.Label)
+	}
+
+	if !res.Completed {
+		t.Fatalf("expected completed %t got %t", true, res.Completed)
+	}
+
+	if len(res.Children) != updates {
+		t.Fatalf("expected %d children got %d", updates, len(res.Children))
+	}
+}
+
+func TestTaskServiceDelete(t *testing.T) {
+	clock := &mockClock{now: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}
+	service := NewTaskStorageService(NewTaskMemoryStorage(), clock)
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bar, err := service.Create([]TaskID{foo.ID}, CreateFields{Label: "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Create([]TaskID{foo.ID, bar.ID}, CreateFields{Label: "baz"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Delete([]TaskID{foo.ID, bar.ID}, DeleteFields{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Find([]TaskID{foo.ID, bar.ID}); err != ErrTaskNotFound {
+		t.Fatalf("expected error %v got %v", ErrTaskNotFound, err)
+	}
+
+	// Restored Task goes back to its parent with its sub tasks.
+	res, path, err := service.Restore(bar.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual([]TaskID{foo.ID, bar.ID}, path) || len(res.Children) != 1 || res.Revision != 2 {
+		t.Fatalf("expected restored Task with sub task in revision 2 at %v got %v at %v", []TaskID{foo.ID, bar.ID}, res, path)
+	}
+
+	if _, err := service.Delete([]TaskID{foo.ID, bar.ID}, DeleteFields{}); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.now = clock.now.Add(time.Hour)
+	if _, err := service.Delete([]TaskID{foo.ID}, DeleteFields{}); err != nil {
+		t.Fatal(err)
+	}
+
+	trash, err := service.FindTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trash) != 2 || trash[0].Task.ID != foo.ID || trash[1].Task.ID != bar.ID {
+		t.Fatalf("expected trashed Tasks %d and %d got %v", foo.ID, bar.ID, trash)
+	}
+
+	// Parent is in trash so the Task is restored to top level.
+	if _, path, err = service.Restore(bar.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual([]TaskID{bar.ID}, path) {
+		t.Fatalf("expected path %v got %v", []TaskID{bar.ID}, path)
+	}
+
+	clock.now = clock.now.Add(2 * time.Hour)
+	purged, err := service.PurgeExpired(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if purged != 1 {
+		t.Fatalf("expected %d purged Tasks got %d", 1, purged)
+	}
+
+	if _, err := service.Purge(foo.ID); err != ErrTrashedTaskNotFound {
+		t.Fatalf("expected error %v got %v", ErrTrashedTaskNotFound, err)
+	}
+
+	// Purge is recorded in history and undo puts the Task back into trash.
+	history, err := service.storage.FindHistory(foo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if last := history[len(history)-1]; last.Op != OperationPurge || last.Action != HistoryActionUntrash {
+		t.Fatalf("expected purge of Task %d got %v", foo.ID, last)
+	}
+
+	if _, err := service.Undo(); err != nil {
+		t.Fatal(err)
+	}
+
+	if trash, _ := service.FindTrash(); len(trash) != 1 || trash[0].Task.ID != foo.ID {
+		t.Fatalf("expected trashed Task %d got %v", foo.ID, trash)
+	}
+}
+
+// failingUpdateStorage is TaskMemoryStorage which fails Update when fail is
+// set.
+type failingUpdateStorage struct {
+	*TaskMemoryStorage
+
+	fail bool
+}
+
+// Update fails or stores changed Task.
+func (s *failingUpdateStorage) Update(path []TaskID, task *Task) error {
+	if s.fail {
+		return errors.New("update failed")
+	}
+
+	return s.TaskMemoryStorage.Update(path, task)
+}
+
+func TestTaskServiceDeleteRollback(t *testing.T) {
+	storage := &failingUpdateStorage{TaskMemoryStorage: NewTaskMemoryStorage()}
+	service := NewTaskStorageService(storage, NewSystemClock())
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bar, err := service.Create([]TaskID{}, CreateFields{Label: "bar", BlockedBy: []TaskID{foo.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := storage.FindAllHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing link of blocked Task fails after the Task was trashed.
+	storage.fail = true
+	if _, err := service.Delete([]TaskID{foo.ID}, DeleteFields{}); err == nil {
+		t.Fatal("expected delete to fail")
+	}
+
+	if tasks, _ := service.FindAll(); len(tasks) != 2 {
+		t.Fatalf("expected Tasks %d and %d got %v", foo.ID, bar.ID, tasks)
+	}
+	if task, _ := service.Find([]TaskID{bar.ID}); !reflect.DeepEqual(TaskIDPath{foo.ID}, task.BlockedBy) {
+		t.Fatalf("expected Task blocked by %d got %v", foo.ID, task)
+	}
+	if trash, _ := service.FindTrash(); len(trash) != 0 {
+		t.Fatalf("expected empty trash got %v", trash)
+	}
+	if res, _ := storage.FindAllHistory(); !reflect.DeepEqual(history, res) {
+		t.Fatalf("expected history %v got %v", history, res)
+	}
+
+	// Restored Task is blocked by trashed Task so its link is removed.
+	storage.fail = false
+	if _, err := service.Delete([]TaskID{bar.ID}, DeleteFields{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Delete([]TaskID{foo.ID}, DeleteFields{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if history, err = storage.FindAllHistory(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing the link fails after the Task was restored.
+	storage.fail = true
+	if _, _, err := service.Restore(bar.ID); err == nil {
+		t.Fatal("expected restore to fail")
+	}
+
+	if tasks, _ := service.FindAll(); len(tasks) != 0 {
+		t.Fatalf("expected no Tasks got %v", tasks)
+	}
+	if trash, _ := service.FindTrash(); len(trash) != 2 {
+		t.Fatalf("expected 2 trashed Tasks got %v", trash)
+	}
+	if res, _ := storage.FindAllHistory(); !reflect.DeepEqual(history, res) {
+		t.Fatalf("expected history %v got %v", history, res)
+	}
+}
+
+func TestTaskServiceMoveRollback(t *testing.T) {
+	storage := &failingUpdateStorage{TaskMemoryStorage: NewTaskMemoryStorage()}
+	service := NewTaskStorageService(storage, NewSystemClock())
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bar, err := service.Create([]TaskID{}, CreateFields{Label: "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := storage.FindAllHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Storing new position fails after the Task was moved.
+	storage.fail = true
+	if _, err := service.Move([]TaskID{bar.ID}, []TaskID{foo.ID}); err == nil {
+		t.Fatal("expected move to fail")
+	}
+
+	task, err := service.Find([]TaskID{bar.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Position != bar.Position || task.Revision != bar.Revision {
+		t.Fatalf("expected Task %v got %v", bar, task)
+	}
+	if res, _ := storage.FindAllHistory(); !reflect.DeepEqual(history, res) {
+		t.Fatalf("expected history %v got %v", history, res)
+	}
+}
+
+func TestTaskServiceHistory(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bar, err := service.Create([]TaskID{foo.ID}, CreateFields{Label: "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, label := range []string{"baz", "qux"} {
+		label := label
+		if _, err := service.Update([]TaskID{foo.ID, bar.ID}, UpdateFields{Label: &label}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := service.FindHistory([]TaskID{foo.ID, bar.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history) != 3 || history[2].Version != 3 || history[2].Before.Label != "baz" || history[2].After.Label != "qux" {
+		t.Fatalf("expected 3 versions of Task %d got %v", bar.ID, history)
+	}
+
+	reverted, err := service.Revert([]TaskID{foo.ID, bar.ID}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reverted.Label != "baz" || reverted.Revision != 4 {
+		t.Fatalf("expected Task %q in revision 4 got %v", "baz", reverted)
+	}
+
+	if _, err := service.Revert([]TaskID{foo.ID, bar.ID}, 42); err != ErrHistoryVersionNotFound {
+		t.Fatalf("expected error %v got %v", ErrHistoryVersionNotFound, err)
+	}
+
+	// Undo reverts the revert, then the last update.
+	for _, label := range []string{"qux", "baz"} {
+		if _, err := service.Undo(); err != nil {
+			t.Fatal(err)
+		}
+
+		task, err := service.Find([]TaskID{foo.ID, bar.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if task.Label != label {
+			t.Fatalf("expected label %q got %q", label, task.Label)
+		}
+	}
+
+	if _, err := service.Delete([]TaskID{foo.ID}, DeleteFields{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleted Task comes back with its sub tasks and leaves trash.
+	if _, err := service.Undo(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Find([]TaskID{foo.ID, bar.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	if trash, _ := service.FindTrash(); len(trash) != 0 {
+		t.Fatalf("expected empty trash got %v", trash)
+	}
+
+	// Remaining operations are the first update and both creates.
+	for i := 0; i < 3; i++ {
+		if _, err := service.Undo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tasks, err := service.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tasks) != 0 {
+		t.Fatalf("expected no Tasks got %v", tasks)
+	}
+
+	if _, err := service.Undo(); err != ErrHistoryNothingToUndo {
+		t.Fatalf("expected error %v got %v", ErrHistoryNothingToUndo, err)
+	}
+}
+
+func TestTaskServiceRevertUpdate(t *testing.T) {
+	service := NewTaskStorageService(NewTaskMemoryStorage(), NewSystemClock())
+	service.SetCompletionPolicy(CompletionPolicy{CompleteParent: true})
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bar, err := service.Create([]TaskID{foo.ID}, CreateFields{Label: "bar", Status: StatusDone})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := false
+	if _, err := service.Update([]TaskID{foo.ID, bar.ID}, UpdateFields{Completed: &reopened}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Completion of reverted Task is propagated.
+	reverted, err := service.Revert([]TaskID{foo.ID, bar.ID}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reverted.Completed || reverted.CompletedAt == nil {
+		t.Fatalf("expected completed Task got %v", reverted)
+	}
+
+	if parent, _ := service.Find([]TaskID{foo.ID}); !parent.Completed {
+		t.Fatalf("expected completed parent got %v", parent)
+	}
+
+	// Done Task can't be reopened in the workflow.
+	service.SetWorkflow(Workflow{StatusTodo: []Status{StatusDone}})
+	if _, err := service.Revert([]TaskID{foo.ID, bar.ID}, 2); err != ErrTaskStatusTransitionNotValid {
+		t.Fatalf("expected error %v got %v", ErrTaskStatusTransitionNotValid, err)
+	}
+
+	if task, _ := service.Find([]TaskID{foo.ID, bar.ID}); !task.Completed || task.Revision != reverted.Revision {
+		t.Fatalf("expected not changed Task %v got %v", reverted, task)
+	}
+}
+
+func TestTaskServiceUndoRollback(t *testing.T) {
+	storage := &failingInsertStorage{TaskMemoryStorage: NewTaskMemoryStorage()}
+	service := NewTaskStorageService(storage, NewSystemClock())
+
+	foo, err := service.Create([]TaskID{}, CreateFields{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := service.Batch([]BatchOperation{
+		{Op: BatchOpDelete, Path: []BatchTaskID{BatchTaskID(strconv.Itoa(int(foo.ID)))}},
+		{Op: BatchOpCreate, Create: CreateFields{Label: "bar"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := storage.FindAllHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing of created Task is undone first, then insert of deleted
+	// Task fails.
+	storage.fail = true
+	if _, err := service.Undo(); err == nil {
+		t.Fatal("expected undo to fail")
+	}
+
+	tasks, err := service.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != results[1].Task.ID {
+		t.Fatalf("expected only Task %d got %v", results[1].Task.ID, tasks)
+	}
+
+	if trash, _ := service.FindTrash(); len(trash) != 1 {
+		t.Fatalf("expected trashed Task got %v", trash)
+	}
+
+	if res, _ := storage.FindAllHistory(); !reflect.DeepEqual(history, res) {
+		t.Fatalf("expected history %v got %v", history, res)
+	}
+}
+
+func TestTaskServiceClone(t *testing.T) {
+	tests := map[string]struct {
+		path         []TaskID
+		toParent     []TaskID
+		fields       CloneFields
+		err          error
+		expIDs       map[TaskID]TaskID
+		expPath      []TaskID
+		expCompleted bool
+	}{
+		"not found": {
+			path: []TaskID{TaskID(4)},
+			err:  ErrTaskNotFound,
+		},
+		"target not found": {
+			path:     []TaskID{TaskID(1)},
+			toParent: []TaskID{TaskID(4)},
+			err:      ErrTaskNotFound,
+		},
+		"to top level": {
+			path:         []TaskID{TaskID(1)},
+			toParent:     []TaskID{},
+			expIDs:       map[TaskID]TaskID{1: 4, 2: 5, 3: 6},
+			expPath:      []TaskID{TaskID(4)},
+			expCompleted: true,
+		},
+		"under itself reset completed": {
+			path:     []TaskID{TaskID(1), TaskID(2)},
+			toParent: []TaskID{TaskID(1), TaskID(2)},
+			fields: CloneFields{
+				ResetCompleted: true,
+			},
+			expIDs:  map[TaskID]TaskID{2: 4, 3: 5},
+			expPath: []TaskID{TaskID(1), TaskID(2), TaskID(4)},
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		// Tree: 1 -> 2 -> 3
+		storage := NewTaskMemoryStorage()
+		storage.storage = map[TaskID]*Task{
+			TaskID(1): &Task{ID: TaskID(1), Label: "foo", Completed: true, Children: SubTasks{
+				TaskID(2): &Task{ID: TaskID(2), Label: "bar", Completed: true, Children: SubTasks{
+					TaskID(3): &Task{ID: TaskID(3), Label: "baz", Completed: true},
+				}},
+			}},
+		}
+		storage.reindex()
+		storage.lastTaskID = TaskID(3)
+		service := NewTaskStorageService(storage, &mockClock{})
+
+		res, ids, err := service.Clone(tc.path, tc.toParent, tc.fields)
+		if err != tc.err {
+			t.Fatalf("expected err %s got %s", tc.err, err)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if !reflect.DeepEqual(tc.expIDs, ids) {
+			t.Fatalf("expected ids %v got %v", tc.expIDs, ids)
+		}
+
+		if res.ID != tc.expPath[len(tc.expPath)-1] {
+			t.Fatalf("expected id %d got %d", tc.expPath[len(tc.expPath)-1], res.ID)
+		}
+
+		// Every copied Task must be stored with new TaskID.
+		for oldID, newID := range ids {
+			original, _, err := storage.FindByID(oldID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			copied, _, err := storage.FindByID(newID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if original.Label != copied.Label {
+				t.Fatalf("expected label %s got %s", original.Label, copied.Label)
+			}
+
+			if tc.expCompleted != copied.Completed {
+				t.Fatalf("expected completed %t got %t", tc.expCompleted, copied.Completed)
+			}
+		}
+
+		if _, err := storage.Find(tc.expPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTaskServicePatch(t *testing.T) {
+	tests := map[string]struct {
+		fields   PatchFields
+		err      error
+		expLabel string
+		expIDs   []TaskID
+	}{
+		"merge patch label": {
+			fields: PatchFields{
+				Type:  PatchTypeMerge,
+				Patch: []byte(`{"label":"foo_new","completed":true}`),
+			},
+			expLabel: "foo_new",
+			expIDs:   []TaskID{TaskID(2), TaskID(3)},
+		},
+		"merge patch sub tasks": {
+			fields: PatchFields{
+				Type:  PatchTypeMerge,
+				Patch: []byte(`{"sub_tasks":[{"id":"3","label":"baz"},{"label":"qux","sub_tasks":[{"label":"quux"}]}]}`),
+			},
+			expLabel: "foo",
+			expIDs:   []TaskID{TaskID(3), TaskID(4)},
+		},
+		"json patch add sub task": {
+			fields: PatchFields{
+				Type:  PatchTypeJSON,
+				Patch: []byte(`[{"op":"test","path":"/sub_tasks/0/id","value":"2"},{"op":"add","path":"/sub_tasks/-","value":{"label":"qux"}}]`),
+			},
+			expLabel: "foo",
+			expIDs:   []TaskID{TaskID(2), TaskID(3), TaskID(4)},
+		},
+		"json patch remove sub task": {
+			fields: PatchFields{
+				Type:  PatchTypeJSON,
+				Patch: []byte(`[{"op":"remove","path":"/sub_tasks/0"}]`),
+			},
+			expLabel: "foo",
+			expIDs:   []TaskID{TaskID(3)},
+		},
+		"json patch test failed": {
+			fields: PatchFields{
+				Type:  PatchTypeJSON,
+				Patch: []byte(`[{"op":"test","path":"/label","value":"bar"}]`),
+			},
+			err: ErrTaskPatchTestFailed,
+		},
+		"revision is read only": {
+			fields: PatchFields{
+				Type:  PatchTypeMerge,
+				Patch: []byte(`{"revision":5}`),
+			},
+			err: ErrTaskFieldIsReadOnly,
+		},
+		"status not valid": {
+			fields: PatchFields{
+				Type:  PatchTypeMerge,
+				Patch: []byte(`{"status":"kekeke"}`),
+			},
+			err: ErrTaskStatusIsNotValid,
+		},
+		"new sub task with id": {
+			fields: PatchFields{
+				Type:  PatchTypeMerge,
+				Patch: []byte(`{"sub_tasks":[{"id":"1","label":"baz"}]}`),
+			},
+			err: ErrTaskPatchNotValid,
+		},
+		"label removed": {
+			fields: PatchFields{
+				Type:  PatchTypeMerge,
+				Patch: []byte(`{"label":null}`),
+			},
+			err: ErrTaskLabelIsRequired,
+		},
+		"sub task label not valid": {
+			fields: PatchFields{
+				Type:  PatchTypeJSON,
+				Patch: []byte(`[{"op":"replace","path":"/sub_tasks/1/label","value":""}]`),
+			},
+			err: ErrTaskLabelIsNotValid,
+		},
+		"unknown field": {
+			fields: PatchFields{
+				Type:  PatchTypeMerge,
+				Patch: []byte(`{"lable":"foo"}`),
+			},
+			err: ErrTaskPatchNotValid,
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		// Tree: 1 -> 2, 3
+		storage := NewTaskMemoryStorage()
+		storage.storage = map[TaskID]*Task{
+			TaskID(1): &Task{ID: TaskID(1), Label: "foo", Revision: 1, Children: SubTasks{
+				TaskID(2): &Task{ID: TaskID(2), Label: "bar", Revision: 1},
+				TaskID(3): &Task{ID: TaskID(3), Label: "baz", Revision: 1},
+			}},
+		}
+		storage.reindex()
+		storage.lastTaskID = TaskID(3)
+		service := NewTaskStorageService(storage, &mockClock{})
+
+		res, err := service.Patch([]TaskID{TaskID(1)}, tc.fields)
+		if err != tc.err {
+			t.Fatalf("expected err %s got %s", tc.err, err)
+		}
+
+		if err != nil {
+			stored, _ := storage.Find([]TaskID{TaskID(1)})
+			if stored.Revision != 1 || len(stored.Children) != 2 {
+				t.Fatalf("expected unchanged task got %v", stored)
+			}
+			continue
+		}
+
+		if tc.expLabel != res.Label {
+			t.Fatalf("expected label %s got %s", tc.expLabel, res.Label)
+		}
+
+		ids := []TaskID{}
+		for id := range res.Children {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		if !reflect.DeepEqual(tc.expIDs, ids) {
+			t.Fatalf("expected sub tasks %v got %v", tc.expIDs, ids)
+		}
+	}
+}
+
+// mockClock is Clock which always returns the same time.
+type mockClock struct {
+	now time.Time
+}
+
+func (c *mockClock) Now() time.Time {
+	return c.now
 }
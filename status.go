@@ -0,0 +1,142 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrTaskStatusIsNotValid
+	ErrTaskStatusIsNotValid error = errors.New("Task field Status is not valid")
+	// ErrTaskStatusTransitionNotValid
+	ErrTaskStatusTransitionNotValid error = errors.New("Task status can't be changed to given status")
+)
+
+// Status is workflow state of the Task.
+type Status string
+
+// Statuses of the Task. Only done Task is completed.
+const (
+	StatusTodo       Status = "todo"
+	StatusInProgress Status = "in_progress"
+	StatusBlocked    Status = "blocked"
+	StatusDone       Status = "done"
+	StatusCancelled  Status = "cancelled"
+)
+
+// validStatus returns true if given status is one of the statuses.
+func validStatus(status Status) bool {
+	switch status {
+	case StatusTodo, StatusInProgress, StatusBlocked, StatusDone, StatusCancelled:
+		return true
+	}
+
+	return false
+}
+
+// Workflow is state machine of Task statuses. It maps status to statuses the
+// Task can be changed to. Keeping the same status is always allowed.
+type Workflow map[Status][]Status
+
+// DefaultWorkflow returns workflow where open Task can be changed to any
+// status, blocked Task must be unblocked before it's done and done or
+// cancelled Task can be reopened.
+func DefaultWorkflow() Workflow {
+	return Workflow{
+		StatusTodo:       []Status{StatusInProgress, StatusBlocked, StatusDone, StatusCancelled},
+		StatusInProgress: []Status{StatusTodo, StatusBlocked, StatusDone, StatusCancelled},
+		StatusBlocked:    []Status{StatusTodo, StatusInProgress, StatusCancelled},
+		StatusDone:       []Status{StatusTodo, StatusInProgress},
+		StatusCancelled:  []Status{StatusTodo},
+	}
+}
+
+// allowed returns true if Task can be changed from status to another status.
+func (w Workflow) allowed(from, to Status) bool {
+	if from == to {
+		return true
+	}
+
+	for _, status := range w[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// status returns status of the Task. Tasks stored before statuses were
+// introduced have status given by Completed.
+func (t *Task) status() Status {
+	if t.Status != "" {
+		return t.Status
+	}
+
+	if t.Completed {
+		return StatusDone
+	}
+
+	return StatusTodo
+}
+
+// setStatus sets status of the Task and Completed derived from the status.
+func (t *Task) setStatus(status Status) {
+	t.Status = status
+	t.Completed = status == StatusDone
+}
+
+// targetStatus returns status of the Task with given current status after the
+// change of status or completed. Changed status takes precedence over changed
+// completed. Completing the Task changes its status to done and reopening of
+// done Task changes its status to todo.
+func targetStatus(current Status, status *Status, completed *bool) Status {
+	if status != nil && *status != current {
+		return *status
+	}
+
+	if completed != nil && *completed != (current == StatusDone) {
+		if *completed {
+			return StatusDone
+		}
+
+		return StatusTodo
+	}
+
+	return current
+}
+
+// TransitionValidator implements TaskActionValidator for change of the Task
+// status. It checks the change in the workflow from current status of the
+// Task.
+type TransitionValidator struct {
+	workflow Workflow
+	current  Status
+}
+
+// NewTransitionValidator returns new instance of TransitionValidator for Task
+// with given current status.
+func NewTransitionValidator(workflow Workflow, current Status) *TransitionValidator {
+	return &TransitionValidator{
+		workflow: workflow,
+		current:  current,
+	}
+}
+
+// Validate returns error if status or completed of given Task can't be
+// changed from current status.
+// Validate implements TaskActionValidator.
+func (v *TransitionValidator) Validate(t *JSONTask) error {
+	if t.Status != nil && !validStatus(*t.Status) {
+		fmt.Println("(DEBUG) status: Transition validation failed. Field Status is not valid.")
+		return ErrTaskStatusIsNotValid
+	}
+
+	target := targetStatus(v.current, t.Status, t.Completed)
+	if !v.workflow.allowed(v.current, target) {
+		fmt.Printf("(DEBUG) status: Transition validation failed. Status %s can't be changed to %s.\n", v.current, target)
+		return ErrTaskStatusTransitionNotValid
+	}
+
+	return nil
+}
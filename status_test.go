@@ -0,0 +1,111 @@
+package tasks
+
+import "testing"
+
+func TestTransitionValidator(t *testing.T) {
+	completed := true
+	reopened := false
+	inProgress := StatusInProgress
+	done := StatusDone
+	unknown := Status("kekeke")
+
+	tests := map[string]struct {
+		current  Status
+		jsonTask *JSONTask
+		target   Status
+		err      error
+	}{
+		"nothing changed": {
+			current:  StatusBlocked,
+			jsonTask: &JSONTask{},
+			target:   StatusBlocked,
+		},
+		"start": {
+			current:  StatusTodo,
+			jsonTask: &JSONTask{Status: &inProgress},
+			target:   StatusInProgress,
+		},
+		"complete": {
+			current:  StatusInProgress,
+			jsonTask: &JSONTask{Completed: &completed},
+			target:   StatusDone,
+		},
+		"complete done": {
+			current:  StatusDone,
+			jsonTask: &JSONTask{Completed: &completed},
+			target:   StatusDone,
+		},
+		"reopen": {
+			current:  StatusDone,
+			jsonTask: &JSONTask{Completed: &reopened},
+			target:   StatusTodo,
+		},
+		"status takes precedence": {
+			current:  StatusTodo,
+			jsonTask: &JSONTask{Status: &inProgress, Completed: &completed},
+			target:   StatusInProgress,
+		},
+		"complete blocked": {
+			current:  StatusBlocked,
+			jsonTask: &JSONTask{Status: &done},
+			target:   StatusDone,
+			err:      ErrTaskStatusTransitionNotValid,
+		},
+		"start cancelled": {
+			current:  StatusCancelled,
+			jsonTask: &JSONTask{Status: &inProgress},
+			target:   StatusInProgress,
+			err:      ErrTaskStatusTransitionNotValid,
+		},
+		"unknown status": {
+			current:  StatusTodo,
+			jsonTask: &JSONTask{Status: &unknown},
+			err:      ErrTaskStatusIsNotValid,
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		err := NewTransitionValidator(DefaultWorkflow(), tc.current).Validate(tc.jsonTask)
+		if err != tc.err {
+			t.Fatalf("expected err %s got %s", tc.err, err)
+		}
+
+		if err == ErrTaskStatusIsNotValid {
+			continue
+		}
+
+		if target := targetStatus(tc.current, tc.jsonTask.Status, tc.jsonTask.Completed); target != tc.target {
+			t.Fatalf("expected status %s got %s", tc.target, target)
+		}
+	}
+}
+
+func TestTaskStatus(t *testing.T) {
+	tests := map[string]struct {
+		task   Task
+		status Status
+	}{
+		"status": {
+			task:   Task{Status: StatusBlocked},
+			status: StatusBlocked,
+		},
+		"legacy completed": {
+			task:   Task{Completed: true},
+			status: StatusDone,
+		},
+		"legacy not completed": {
+			task:   Task{},
+			status: StatusTodo,
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		if status := tc.task.status(); status != tc.status {
+			t.Fatalf("expected status %s got %s", tc.status, status)
+		}
+	}
+}
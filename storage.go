@@ -1,24 +1,71 @@
 package tasks
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 )
 
+var (
+	// ErrStorageTransactionInProgress
+	ErrStorageTransactionInProgress error = errors.New("Storage transaction is already in progress")
+	// ErrStorageTransactionNotStarted
+	ErrStorageTransactionNotStarted error = errors.New("Storage transaction is not started")
+)
+
 // TaskStorage is interface which defines task storage operations.
 type TaskStorage interface {
 	// Insert stores new Task in storage under given TaskID path.
 	Insert([]TaskID, *Task) error
 	// Find returns Task from given TaskID path.
 	Find([]TaskID) (Task, error)
+	// FindByID returns Task with given TaskID and its TaskID path.
+	FindByID(TaskID) (Task, []TaskID, error)
 	// FindAll returns all root Tasks (with their children).
 	FindAll() ([]Task, error)
 	// Update updates Task at given TaskID path.
 	Update([]TaskID, *Task) error
 	// Delete removes Task at given TaskID path.
 	Delete([]TaskID) error
+	// Move moves Task at given TaskID path (with its children) under Task at
+	// second TaskID path.
+	Move([]TaskID, []TaskID) error
 	// NextTaskID returns next available TaskID.
 	NextTaskID() TaskID
+	// InsertTrash stores deleted Task (with its children) in trash.
+	InsertTrash(*TrashedTask) error
+	// FindTrash returns trashed Task with given TaskID.
+	FindTrash(TaskID) (TrashedTask, error)
+	// FindAllTrash returns all trashed Tasks.
+	FindAllTrash() ([]TrashedTask, error)
+	// DeleteTrash removes trashed Task with given TaskID from trash.
+	DeleteTrash(TaskID) error
+	// InsertHistory appends change of the Task to history.
+	InsertHistory(*HistoryEntry) error
+	// FindHistory returns all changes of the Task with given TaskID.
+	FindHistory(TaskID) ([]HistoryEntry, error)
+	// FindAllHistory returns all changes in order they were inserted.
+	FindAllHistory() ([]HistoryEntry, error)
+	// FindByText returns Tasks matching full-text query ordered by score.
+	FindByText(string) ([]TextMatch, error)
+	// Begin starts transaction. Changes made until Commit are stored
+	// together, Rollback discards them.
+	Begin() error
+	// Commit stores changes made since Begin.
+	Commit() error
+	// Rollback discards changes made since Begin.
+	Rollback() error
+}
+
+// noParentTaskID is parent TaskID of root Tasks in the index. TaskIDs given
+// by NextTaskID start from 1 so it never collides with real Task.
+const noParentTaskID TaskID = 0
+
+// taskIndexEntry is entry of the flat Task index. It points to the Task node
+// in the tree and to its parent.
+type taskIndexEntry struct {
+	task   *Task
+	parent TaskID
 }
 
 // TaskMemoryStorage is simple implementation of TaskStorage as hashmap tree.
@@ -27,6 +74,26 @@ type TaskStorage interface {
 type TaskMemoryStorage struct {
 	// storage is top level tree hashmap.
 	storage map[TaskID]*Task
+	// index is flat index of every Task in the tree by TaskID so any Task
+	// can be found without walking the tree.
+	index map[TaskID]*taskIndexEntry
+	// text is full-text index of labels and notes of every Task in the
+	// tree. It's kept in sync with index.
+	text *textIndex
+	// trash holds deleted Tasks by their TaskID.
+	trash map[TaskID]*TrashedTask
+	// history holds all changes of Tasks in order they were inserted.
+	history []*HistoryEntry
+	// historyIndex holds changes of every Task by its TaskID.
+	historyIndex map[TaskID][]*HistoryEntry
+	// journal holds functions which undo changes made in the transaction
+	// in order the changes were made. It's nil outside of transaction.
+	journal []func()
+	// mu guards storage, index, trash, history, journal and every Task in
+	// the tree. Tasks are never shared with callers: Insert and Update store
+	// copies, Find and FindAll return copies, so the tree can be touched
+	// only under the lock.
+	mu *sync.RWMutex
 
 	// LastTaskID is the value of next inserted TaskID.
 	lastTaskID TaskID
@@ -39,16 +106,25 @@ type TaskMemoryStorage struct {
 func NewTaskMemoryStorage() *TaskMemoryStorage {
 	return &TaskMemoryStorage{
 		storage:      map[TaskID]*Task{},
+		index:        map[TaskID]*taskIndexEntry{},
+		text:         newTextIndex(),
+		trash:        map[TaskID]*TrashedTask{},
+		historyIndex: map[TaskID][]*HistoryEntry{},
+		mu:           &sync.RWMutex{},
 		lastTaskIDmu: &sync.Mutex{},
 	}
 }
 
-// Insert stores new Task in storage. Path is the key where new task
-// will be stored WITHOUT TaskID of the new Task.
+// Insert stores copy of new Task (with its children) in storage. Path is the
+// key where new task will be stored WITHOUT TaskID of the new Task.
 // Insert implements TaskStorage interface.
 func (s *TaskMemoryStorage) Insert(path []TaskID, task *Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if len(path) == 0 {
-		s.storage[task.ID] = task
+		s.record(s.undoPut(noParentTaskID, task.ID))
+		s.put(s.storage, noParentTaskID, task.ID, task.clone())
 		return nil
 	}
 
@@ -62,14 +138,18 @@ func (s *TaskMemoryStorage) Insert(path []TaskID, task *Task) error {
 	if lastPathTask.Children == nil {
 		lastPathTask.Children = map[TaskID]*Task{}
 	}
-	lastPathTask.Children[task.ID] = task
+	s.record(s.undoPut(path[len(path)-1], task.ID))
+	s.put(lastPathTask.Children, path[len(path)-1], task.ID, task.clone())
 
 	return nil
 }
 
-// Find returns Task under given taskID path.
+// Find returns copy of Task under given taskID path.
 // Find implements TaskStorage interface.
 func (s *TaskMemoryStorage) Find(path []TaskID) (Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	if len(path) == 0 {
 		fmt.Println("(DEBUG) storage: Find Task by TaskID path failed. TaskID path is empty.")
 		return Task{}, ErrTaskPathNotValid
@@ -81,52 +161,65 @@ func (s *TaskMemoryStorage) Find(path []TaskID) (Task, error) {
 		return Task{}, err
 	}
 
-	return *lastPathTask, nil
+	return *lastPathTask.clone(), nil
 }
 
-// FindAll returns all root (top level) Tasks with their children.
+// FindByID returns copy of Task with given TaskID and TaskID path of the Task
+// (including its TaskID) using the index.
+// FindByID implements TaskStorage interface.
+func (s *TaskMemoryStorage) FindByID(taskID TaskID) (Task, []TaskID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, found := s.index[taskID]
+	if !found {
+		fmt.Println("(DEBUG) storage: Find Task by TaskID failed. Task not found.")
+		return Task{}, nil, ErrTaskNotFound
+	}
+
+	return *entry.task.clone(), s.path(taskID), nil
+}
+
+// FindAll returns copy of all root (top level) Tasks with their children.
 // FindAll implements TaskStorage interface.
 func (s *TaskMemoryStorage) FindAll() ([]Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	tasks := []Task{}
 	for _, task := range s.storage {
-		tasks = append(tasks, *task)
+		tasks = append(tasks, *task.clone())
 	}
 
 	return tasks, nil
 }
 
-// Update updates Task under given TaskID path.
+// Update updates Task under given TaskID path. Only Task fields are updated,
+// children of the stored Task are kept - they are changed only by Insert and
+// Delete.
 // Update implements TaskStorage interface.
 func (s *TaskMemoryStorage) Update(path []TaskID, task *Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if len(path) == 0 {
 		fmt.Println("(DEBUG) storage: Update Task by TaskID path failed. TaskID path is empty.")
 		return ErrTaskPathNotValid
 	}
 
-	// Look in top level tasks
-	if len(path) == 1 {
-		if _, found := s.storage[task.ID]; !found {
-			fmt.Println("(DEBUG) storage: Update Task by TaskID path failed. Root Task not found.")
-			return ErrTaskNotFound
-		}
-
-		s.storage[task.ID] = task
-
-		return nil
-	}
-
-	// Find the Task before last in the path. The last one is the Task we want
-	// to update.
-	lastPathTask, err := s.search(path[:len(path)-1])
+	entry, err := s.lookup(path)
 	if err != nil {
-		fmt.Println("(DEBUG) storage: Update Task by TaskID path failed. Child Task not found.")
+		fmt.Println("(DEBUG) storage: Update Task by TaskID path failed. Task not found.")
 		return err
 	}
 
-	if lastPathTask.Children == nil {
-		lastPathTask.Children = map[TaskID]*Task{}
-	}
-	lastPathTask.Children[task.ID] = task
+	// Replace the node in its parent and in the index. Children stay in
+	// place so their index entries are still valid.
+	taskID := path[len(path)-1]
+	s.record(s.undoUpdate(taskID, entry.task))
+	entry.task = task.withChildren(entry.task.Children)
+	s.siblings(entry.parent)[taskID] = entry.task
+	s.text.add(entry.task)
 
 	return nil
 }
@@ -134,33 +227,72 @@ func (s *TaskMemoryStorage) Update(path []TaskID, task *Task) error {
 // Delete removes Task at given TaskID path.
 // Delete implements TaskStorage interface.
 func (s *TaskMemoryStorage) Delete(path []TaskID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if len(path) == 0 {
 		fmt.Println("(DEBUG) storage: Search Task by TaskID path failed. TaskID path is empty.")
 		return ErrTaskPathNotValid
 	}
 
-	// Look in top level tasks
-	if len(path) == 1 {
-		if _, found := s.storage[path[0]]; !found {
-			fmt.Println("(DEBUG) storage: Delete Task by TaskID path failed. Root Task not found.")
-			return ErrTaskNotFound
-		}
-
-		delete(s.storage, path[0])
-
-		return nil
+	entry, err := s.lookup(path)
+	if err != nil {
+		fmt.Println("(DEBUG) storage: Delete Task by TaskID path failed. Task not found.")
+		return err
 	}
 
-	// Find the Task before last in the path. The last one is the Task we want
-	// to delete.
 	taskID := path[len(path)-1]
-	lastPathTask, err := s.search(path[:len(path)-1])
+	s.record(s.undoDelete(entry.parent, taskID, entry.task))
+	delete(s.siblings(entry.parent), taskID)
+	s.unindex(taskID, entry.task)
+
+	return nil
+}
+
+// Move moves Task at from TaskID path with its whole subtree under Task at
+// toParent TaskID path. Empty toParent path moves the Task to top level. Task
+// can't be moved under itself or under any of its descendants.
+// Move implements TaskStorage interface.
+func (s *TaskMemoryStorage) Move(from []TaskID, toParent []TaskID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, err := s.lookup(from)
 	if err != nil {
-		fmt.Println("(DEBUG) storage: Delete Task by TaskID path failed. Child Task not found.")
+		fmt.Println("(DEBUG) storage: Move Task by TaskID path failed. Task not found.")
 		return err
 	}
+	taskID := from[len(from)-1]
+
+	parent := noParentTaskID
+	if len(toParent) > 0 {
+		parentEntry, err := s.lookup(toParent)
+		if err != nil {
+			fmt.Println("(DEBUG) storage: Move Task by TaskID path failed. Target Task not found.")
+			return err
+		}
+		parent = toParent[len(toParent)-1]
+
+		// Target path contains the Task itself if target is the Task or
+		// any of its descendants.
+		for _, id := range toParent {
+			if id == taskID {
+				fmt.Println("(DEBUG) storage: Move Task by TaskID path failed. Target is in moved subtree.")
+				return ErrTaskMoveNotValid
+			}
+		}
 
-	delete(lastPathTask.Children, taskID)
+		if parentEntry.task.Children == nil {
+			parentEntry.task.Children = map[TaskID]*Task{}
+		}
+	}
+
+	// Index entries of the subtree stay valid, only the moved Task gets new
+	// parent.
+	s.record(s.undoMove(taskID, entry.parent))
+	delete(s.siblings(entry.parent), taskID)
+	entry.parent = parent
+	s.siblings(parent)[taskID] = entry.task
 
 	return nil
 }
@@ -176,36 +308,365 @@ func (s *TaskMemoryStorage) NextTaskID() TaskID {
 	return s.lastTaskID
 }
 
-// search returns Task at given TaskID path.
+// InsertTrash stores copy of deleted Task in trash. Trashed Task stored
+// under the same TaskID before is replaced.
+// InsertTrash implements TaskStorage interface.
+func (s *TaskMemoryStorage) InsertTrash(trashed *TrashedTask) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.record(s.undoTrash(trashed.Task.ID))
+	s.trash[trashed.Task.ID] = trashed.clone()
+
+	return nil
+}
+
+// FindTrash returns copy of trashed Task with given TaskID.
+// FindTrash implements TaskStorage interface.
+func (s *TaskMemoryStorage) FindTrash(taskID TaskID) (TrashedTask, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trashed, found := s.trash[taskID]
+	if !found {
+		fmt.Println("(DEBUG) storage: Find trashed Task by TaskID failed. Task not found.")
+		return TrashedTask{}, ErrTrashedTaskNotFound
+	}
+
+	return *trashed.clone(), nil
+}
+
+// FindAllTrash returns copy of all trashed Tasks.
+// FindAllTrash implements TaskStorage interface.
+func (s *TaskMemoryStorage) FindAllTrash() ([]TrashedTask, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trash := []TrashedTask{}
+	for _, trashed := range s.trash {
+		trash = append(trash, *trashed.clone())
+	}
+
+	return trash, nil
+}
+
+// DeleteTrash removes trashed Task with given TaskID from trash.
+// DeleteTrash implements TaskStorage interface.
+func (s *TaskMemoryStorage) DeleteTrash(taskID TaskID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.trash[taskID]; !found {
+		fmt.Println("(DEBUG) storage: Delete trashed Task by TaskID failed. Task not found.")
+		return ErrTrashedTaskNotFound
+	}
+	s.record(s.undoTrash(taskID))
+	delete(s.trash, taskID)
+
+	return nil
+}
+
+// InsertHistory appends copy of the change of the Task to history.
+// InsertHistory implements TaskStorage interface.
+func (s *TaskMemoryStorage) InsertHistory(entry *HistoryEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry = entry.clone()
+	s.record(s.undoHistory(entry.TaskID))
+	s.history = append(s.history, entry)
+	s.historyIndex[entry.TaskID] = append(s.historyIndex[entry.TaskID], entry)
+
+	return nil
+}
+
+// FindHistory returns copy of all changes of the Task with given TaskID in
+// order they were inserted.
+// FindHistory implements TaskStorage interface.
+func (s *TaskMemoryStorage) FindHistory(taskID TaskID) ([]HistoryEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := []HistoryEntry{}
+	for _, entry := range s.historyIndex[taskID] {
+		history = append(history, *entry.clone())
+	}
+
+	return history, nil
+}
+
+// FindAllHistory returns copy of all changes in order they were inserted.
+// FindAllHistory implements TaskStorage interface.
+func (s *TaskMemoryStorage) FindAllHistory() ([]HistoryEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := make([]HistoryEntry, len(s.history))
+	for i, entry := range s.history {
+		history[i] = *entry.clone()
+	}
+
+	return history, nil
+}
+
+// FindByText returns copy of Tasks (without children, with progress) which
+// contain all words of given full-text query in their labels or notes ordered
+// by score. Words of the query match prefixes of the words in Tasks.
+// FindByText implements TaskStorage interface.
+func (s *TaskMemoryStorage) FindByText(query string) ([]TextMatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	scores := s.text.search(query)
+	matches := make([]TextMatch, len(scores))
+	for i, score := range scores {
+		matches[i] = TextMatch{
+			Path:  s.path(score.taskID),
+			Task:  *s.index[score.taskID].task.withProgress(),
+			Score: score.score,
+		}
+	}
+
+	return matches, nil
+}
+
+// Begin starts transaction. Every change made until Commit is journaled so
+// Rollback can undo it. Changes are visible to readers of the storage before
+// Commit so only the owner of the transaction may read the storage until it
+// ends (TaskStorageService holds its readers back by its lock).
+// Begin implements TaskStorage interface.
+func (s *TaskMemoryStorage) Begin() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.journal != nil {
+		fmt.Println("(DEBUG) storage: Begin transaction failed. Transaction in progress.")
+		return ErrStorageTransactionInProgress
+	}
+	s.journal = []func(){}
+
+	return nil
+}
+
+// Commit ends transaction and keeps its changes.
+// Commit implements TaskStorage interface.
+func (s *TaskMemoryStorage) Commit() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.journal == nil {
+		fmt.Println("(DEBUG) storage: Commit transaction failed. Transaction not started.")
+		return ErrStorageTransactionNotStarted
+	}
+	s.journal = nil
+
+	return nil
+}
+
+// Rollback ends transaction and undoes its changes in reverse order.
+// TaskIDs given by NextTaskID in the transaction are not reused.
+// Rollback implements TaskStorage interface.
+func (s *TaskMemoryStorage) Rollback() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.journal == nil {
+		fmt.Println("(DEBUG) storage: Rollback transaction failed. Transaction not started.")
+		return ErrStorageTransactionNotStarted
+	}
+
+	for i := len(s.journal) - 1; i >= 0; i-- {
+		s.journal[i]()
+	}
+	s.journal = nil
+
+	return nil
+}
+
+// record appends undo function of the change into journal if transaction is
+// in progress. Caller must hold the lock.
+func (s *TaskMemoryStorage) record(undo func()) {
+	if s.journal != nil {
+		s.journal = append(s.journal, undo)
+	}
+}
+
+// undoPut returns function which restores Task stored under taskID in
+// children of parent before put. Caller must hold the lock.
+func (s *TaskMemoryStorage) undoPut(parent, taskID TaskID) func() {
+	old := s.siblings(parent)[taskID]
+
+	return func() {
+		children := s.siblings(parent)
+		if old != nil {
+			s.put(children, parent, taskID, old)
+			return
+		}
+
+		s.unindex(taskID, children[taskID])
+		delete(children, taskID)
+	}
+}
+
+// undoUpdate returns function which restores fields of updated Task.
+// Children of the Task are kept. Caller must hold the lock.
+func (s *TaskMemoryStorage) undoUpdate(taskID TaskID, old *Task) func() {
+	return func() {
+		entry := s.index[taskID]
+		entry.task = old.withChildren(entry.task.Children)
+		s.siblings(entry.parent)[taskID] = entry.task
+		s.text.add(entry.task)
+	}
+}
+
+// undoDelete returns function which puts deleted Task back under its parent.
+// Caller must hold the lock.
+func (s *TaskMemoryStorage) undoDelete(parent, taskID TaskID, task *Task) func() {
+	return func() {
+		s.siblings(parent)[taskID] = task
+		s.reindexTree(parent, taskID, task)
+	}
+}
+
+// undoMove returns function which moves Task back under its former parent.
+// Caller must hold the lock.
+func (s *TaskMemoryStorage) undoMove(taskID, parent TaskID) func() {
+	return func() {
+		entry := s.index[taskID]
+		delete(s.siblings(entry.parent), taskID)
+		entry.parent = parent
+		s.siblings(parent)[taskID] = entry.task
+	}
+}
+
+// undoTrash returns function which restores trashed Task stored under taskID
+// (or removes it if there was none). Caller must hold the lock.
+func (s *TaskMemoryStorage) undoTrash(taskID TaskID) func() {
+	old := s.trash[taskID]
+
+	return func() {
+		if old != nil {
+			s.trash[taskID] = old
+			return
+		}
+
+		delete(s.trash, taskID)
+	}
+}
+
+// undoHistory returns function which removes the last change from history.
+// Caller must hold the lock.
+func (s *TaskMemoryStorage) undoHistory(taskID TaskID) func() {
+	return func() {
+		s.history = s.history[:len(s.history)-1]
+
+		entries := s.historyIndex[taskID][:len(s.historyIndex[taskID])-1]
+		if len(entries) == 0 {
+			delete(s.historyIndex, taskID)
+			return
+		}
+		s.historyIndex[taskID] = entries
+	}
+}
+
+// search returns Task at given TaskID path. Caller must hold the lock.
 func (s *TaskMemoryStorage) search(path []TaskID) (*Task, error) {
+	entry, err := s.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.task, nil
+}
+
+// lookup returns index entry of the Task at given TaskID path. Task is found
+// in the index and the path is verified by parents of the entries so only
+// valid full paths are accepted. Caller must hold the lock.
+func (s *TaskMemoryStorage) lookup(path []TaskID) (*taskIndexEntry, error) {
 	if len(path) == 0 {
 		fmt.Println("(DEBUG) storage: Search Task by TaskID path failed. TaskID path is empty.")
 		return nil, ErrTaskPathNotValid
 	}
 
-	// Look in top level Tasks.
-	task, found := s.storage[path[0]]
-	if !found {
-		fmt.Println("(DEBUG) storage: Search Task by TaskID path failed. Root Task not found.")
-		return nil, ErrTaskNotFound
-	}
-
-	// Loop in Task children to find the match.
-	for i := 1; i < len(path); i++ {
-		found = false
-		for _, childTask := range task.Children {
-			if childTask.ID == path[i] {
-				found = true
-				task = childTask
-				continue
+	parent := noParentTaskID
+	for i, taskID := range path {
+		entry, found := s.index[taskID]
+		if !found || entry.parent != parent {
+			if i == 0 {
+				fmt.Println("(DEBUG) storage: Search Task by TaskID path failed. Root Task not found.")
+			} else {
+				fmt.Println("(DEBUG) storage: Search Task by TaskID path failed. Child Task not found.")
 			}
-		}
-
-		if !found {
-			fmt.Println("(DEBUG) storage: Search Task by TaskID path failed. Child Task not found.")
 			return nil, ErrTaskNotFound
 		}
+		parent = taskID
+	}
+
+	return s.index[path[len(path)-1]], nil
+}
+
+// path returns TaskID path of indexed Task with given TaskID. Caller must
+// hold the lock.
+func (s *TaskMemoryStorage) path(taskID TaskID) []TaskID {
+	path := []TaskID{}
+	for id := taskID; id != noParentTaskID; id = s.index[id].parent {
+		path = append([]TaskID{id}, path...)
 	}
 
-	return task, nil
+	return path
+}
+
+// siblings returns children hashmap of Task with given TaskID or top level
+// hashmap for noParentTaskID. Caller must hold the lock.
+func (s *TaskMemoryStorage) siblings(parent TaskID) map[TaskID]*Task {
+	if parent == noParentTaskID {
+		return s.storage
+	}
+
+	return s.index[parent].task.Children
+}
+
+// put stores Task under taskID in given children hashmap of parent and
+// indexes the Task with its subtree. Task which was stored under the same
+// TaskID before is replaced. Caller must hold the lock.
+func (s *TaskMemoryStorage) put(children map[TaskID]*Task, parent, taskID TaskID, task *Task) {
+	if oldTask, found := children[taskID]; found {
+		s.unindex(taskID, oldTask)
+	}
+
+	children[taskID] = task
+	s.reindexTree(parent, taskID, task)
+}
+
+// reindex rebuilds the whole index from the tree. Caller must hold the lock
+// (or be the only user of the storage).
+func (s *TaskMemoryStorage) reindex() {
+	s.index = map[TaskID]*taskIndexEntry{}
+	s.text = newTextIndex()
+	for taskID, task := range s.storage {
+		s.reindexTree(noParentTaskID, taskID, task)
+	}
+}
+
+// reindexTree adds Task stored under taskID and all its children into index.
+func (s *TaskMemoryStorage) reindexTree(parent, taskID TaskID, task *Task) {
+	s.index[taskID] = &taskIndexEntry{
+		task:   task,
+		parent: parent,
+	}
+	s.text.add(task)
+
+	for childID, child := range task.Children {
+		s.reindexTree(taskID, childID, child)
+	}
+}
+
+// unindex removes Task stored under taskID and all its children from index.
+func (s *TaskMemoryStorage) unindex(taskID TaskID, task *Task) {
+	delete(s.index, taskID)
+	s.text.remove(taskID)
+
+	for childID, child := range task.Children {
+		s.unindex(childID, child)
+	}
 }
@@ -0,0 +1,697 @@
+package tasks
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// walFileName is name of the write-ahead log file in data directory.
+	walFileName = "tasks.wal"
+	// snapshotFileName is name of the snapshot file in data directory.
+	snapshotFileName = "tasks.snapshot"
+	// defaultCompactThreshold is number of log records after which the log
+	// is compacted into a snapshot.
+	defaultCompactThreshold = 1000
+)
+
+// Operations which are recorded in write-ahead log.
+const (
+	walOpInsert  = "insert"
+	walOpUpdate  = "update"
+	walOpDelete  = "delete"
+	walOpMove    = "move"
+	walOpTrash   = "trash"
+	walOpPurge   = "purge"
+	walOpHistory = "history"
+	walOpBatch   = "batch"
+)
+
+var (
+	// ErrStorageClosed is returned when TaskFileStorage is used after Close.
+	ErrStorageClosed error = errors.New("Storage is closed")
+)
+
+// walRecord is single entry in write-ahead log. Every mutation of the storage
+// is written as one JSON line before it is applied in memory.
+type walRecord struct {
+	// Seq is monotonic sequence number of the record. Records with Seq lower
+	// or equal to the snapshot Seq are already part of the snapshot.
+	Seq uint64 `json:"seq"`
+	// Op is name of the operation.
+	Op string `json:"op"`
+	// Path is TaskID path the operation was called with.
+	Path []TaskID `json:"path"`
+	// Task is Task the operation was called with (Insert and Update only).
+	Task *Task `json:"task,omitempty"`
+	// Target is target parent TaskID path (Move only).
+	Target []TaskID `json:"target,omitempty"`
+	// Trashed is Task stored in trash (InsertTrash only).
+	Trashed *TrashedTask `json:"trashed,omitempty"`
+	// History is change of the Task appended to history (InsertHistory
+	// only).
+	History *HistoryEntry `json:"history,omitempty"`
+	// Records are records of committed transaction (batch only). They are
+	// written as one record so they are replayed all or none.
+	Records []walRecord `json:"records,omitempty"`
+}
+
+// snapshot is compacted state of the storage written to disk.
+type snapshot struct {
+	// Seq is sequence number of the last record included in the snapshot.
+	Seq uint64 `json:"seq"`
+	// LastTaskID is the last TaskID given by NextTaskID.
+	LastTaskID TaskID `json:"last_task_id"`
+	// Tasks are all root Tasks with their children.
+	Tasks []Task `json:"tasks"`
+	// Trash contains all trashed Tasks.
+	Trash []TrashedTask `json:"trash,omitempty"`
+	// History contains all changes of Tasks in order they were inserted.
+	History []HistoryEntry `json:"history,omitempty"`
+}
+
+// TaskFileStorage is durable implementation of TaskStorage. It keeps the Task
+// tree in TaskMemoryStorage and appends every change of the tree to the
+// write-ahead log in data directory before the change is applied. The log is
+// replayed on start and periodically compacted into a snapshot so the tasks
+// survive restarts and crashes of the program.
+type TaskFileStorage struct {
+	// memory holds current state of the Task tree.
+	memory *TaskMemoryStorage
+
+	// dir is data directory with log and snapshot files.
+	dir string
+	// wal is opened write-ahead log file.
+	wal *os.File
+	// seq is sequence number of the last written record.
+	seq uint64
+	// records is number of records in the log since last compaction.
+	records int
+	// compactThreshold is number of records which triggers compaction.
+	compactThreshold int
+	// transaction holds records of the transaction in progress until they
+	// are written by Commit. It's nil outside of transaction.
+	transaction []walRecord
+
+	// mu guarantees that log records are written in the same order as they
+	// are applied in memory.
+	mu *sync.Mutex
+}
+
+// NewTaskFileStorage returns a new instance of TaskFileStorage persisted in
+// given data directory. Directory is created if it does not exist. Existing
+// snapshot and write-ahead log are loaded and replayed.
+func NewTaskFileStorage(dir string) (*TaskFileStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Printf("(WARN) storage: Creating data directory %q failed: %s\n", dir, err)
+		return nil, err
+	}
+
+	s := &TaskFileStorage{
+		memory:           NewTaskMemoryStorage(),
+		dir:              dir,
+		compactThreshold: defaultCompactThreshold,
+		mu:               &sync.Mutex{},
+	}
+
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Insert stores new Task in storage under given TaskID path.
+// Insert implements TaskStorage interface.
+func (s *TaskFileStorage) Insert(path []TaskID, task *Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(walRecord{Op: walOpInsert, Path: path, Task: task}); err != nil {
+		return err
+	}
+
+	return s.memory.Insert(path, task)
+}
+
+// Find returns Task under given TaskID path.
+// Find implements TaskStorage interface.
+func (s *TaskFileStorage) Find(path []TaskID) (Task, error) {
+	return s.memory.Find(path)
+}
+
+// FindByID returns Task with given TaskID and its TaskID path.
+// FindByID implements TaskStorage interface.
+func (s *TaskFileStorage) FindByID(taskID TaskID) (Task, []TaskID, error) {
+	return s.memory.FindByID(taskID)
+}
+
+// FindAll returns all root (top level) Tasks with their children.
+// FindAll implements TaskStorage interface.
+func (s *TaskFileStorage) FindAll() ([]Task, error) {
+	return s.memory.FindAll()
+}
+
+// Update updates Task under given TaskID path.
+// Update implements TaskStorage interface.
+func (s *TaskFileStorage) Update(path []TaskID, task *Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Update does not change children so there is no need to log them.
+	if err := s.append(walRecord{Op: walOpUpdate, Path: path, Task: task.withChildren(nil)}); err != nil {
+		return err
+	}
+
+	return s.memory.Update(path, task)
+}
+
+// Delete removes Task at given TaskID path.
+// Delete implements TaskStorage interface.
+func (s *TaskFileStorage) Delete(path []TaskID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(walRecord{Op: walOpDelete, Path: path}); err != nil {
+		return err
+	}
+
+	return s.memory.Delete(path)
+}
+
+// Move moves Task at from TaskID path under Task at toParent TaskID path.
+// Move implements TaskStorage interface.
+func (s *TaskFileStorage) Move(from []TaskID, toParent []TaskID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(walRecord{Op: walOpMove, Path: from, Target: toParent}); err != nil {
+		return err
+	}
+
+	return s.memory.Move(from, toParent)
+}
+
+// NextTaskID returns next available TaskID value. Given TaskIDs are persisted
+// by snapshot so TaskID is not used twice after restart.
+// NextTaskID implements TaskStorage interface.
+func (s *TaskFileStorage) NextTaskID() TaskID {
+	return s.memory.NextTaskID()
+}
+
+// InsertTrash stores deleted Task in trash.
+// InsertTrash implements TaskStorage interface.
+func (s *TaskFileStorage) InsertTrash(trashed *TrashedTask) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(walRecord{Op: walOpTrash, Trashed: trashed}); err != nil {
+		return err
+	}
+
+	return s.memory.InsertTrash(trashed)
+}
+
+// FindTrash returns trashed Task with given TaskID.
+// FindTrash implements TaskStorage interface.
+func (s *TaskFileStorage) FindTrash(taskID TaskID) (TrashedTask, error) {
+	return s.memory.FindTrash(taskID)
+}
+
+// FindAllTrash returns all trashed Tasks.
+// FindAllTrash implements TaskStorage interface.
+func (s *TaskFileStorage) FindAllTrash() ([]TrashedTask, error) {
+	return s.memory.FindAllTrash()
+}
+
+// DeleteTrash removes trashed Task with given TaskID from trash.
+// DeleteTrash implements TaskStorage interface.
+func (s *TaskFileStorage) DeleteTrash(taskID TaskID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(walRecord{Op: walOpPurge, Path: []TaskID{taskID}}); err != nil {
+		return err
+	}
+
+	return s.memory.DeleteTrash(taskID)
+}
+
+// InsertHistory appends change of the Task to history.
+// InsertHistory implements TaskStorage interface.
+func (s *TaskFileStorage) InsertHistory(entry *HistoryEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(walRecord{Op: walOpHistory, History: entry}); err != nil {
+		return err
+	}
+
+	return s.memory.InsertHistory(entry)
+}
+
+// FindHistory returns all changes of the Task with given TaskID.
+// FindHistory implements TaskStorage interface.
+func (s *TaskFileStorage) FindHistory(taskID TaskID) ([]HistoryEntry, error) {
+	return s.memory.FindHistory(taskID)
+}
+
+// FindAllHistory returns all changes in order they were inserted.
+// FindAllHistory implements TaskStorage interface.
+func (s *TaskFileStorage) FindAllHistory() ([]HistoryEntry, error) {
+	return s.memory.FindAllHistory()
+}
+
+// FindByText returns Tasks matching full-text query ordered by score.
+// FindByText implements TaskStorage interface.
+func (s *TaskFileStorage) FindByText(query string) ([]TextMatch, error) {
+	return s.memory.FindByText(query)
+}
+
+// Begin starts transaction. Changes are applied in memory immediately but
+// their log records are held back until Commit.
+// Begin implements TaskStorage interface.
+func (s *TaskFileStorage) Begin() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal == nil {
+		return ErrStorageClosed
+	}
+
+	if s.transaction != nil {
+		fmt.Println("(DEBUG) storage: Begin transaction failed. Transaction in progress.")
+		return ErrStorageTransactionInProgress
+	}
+
+	if err := s.memory.Begin(); err != nil {
+		return err
+	}
+	s.transaction = []walRecord{}
+
+	return nil
+}
+
+// Commit writes all records of the transaction into write-ahead log as one
+// record. Changes are rolled back if the record can't be written.
+// Commit implements TaskStorage interface.
+func (s *TaskFileStorage) Commit() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.transaction == nil {
+		fmt.Println("(DEBUG) storage: Commit transaction failed. Transaction not started.")
+		return ErrStorageTransactionNotStarted
+	}
+
+	records := s.transaction
+	s.transaction = nil
+
+	if len(records) > 0 {
+		if err := s.write(walRecord{Op: walOpBatch, Records: records}); err != nil {
+			s.memory.Rollback()
+			return err
+		}
+	}
+
+	return s.memory.Commit()
+}
+
+// Rollback discards records of the transaction and undoes its changes in
+// memory.
+// Rollback implements TaskStorage interface.
+func (s *TaskFileStorage) Rollback() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.transaction == nil {
+		fmt.Println("(DEBUG) storage: Rollback transaction failed. Transaction not started.")
+		return ErrStorageTransactionNotStarted
+	}
+	s.transaction = nil
+
+	return s.memory.Rollback()
+}
+
+// Compact writes current state of the storage into snapshot and truncates the
+// write-ahead log.
+func (s *TaskFileStorage) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.compact()
+}
+
+// Close compacts the storage and closes the write-ahead log. Storage must not
+// be used after Close.
+func (s *TaskFileStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal == nil {
+		return ErrStorageClosed
+	}
+
+	if err := s.compact(); err != nil {
+		return err
+	}
+
+	err := s.wal.Close()
+	s.wal = nil
+
+	return err
+}
+
+// append writes given record with next Seq into write-ahead log and syncs the
+// log on disk. Log is compacted when it reaches compactThreshold records.
+// Records of transaction in progress are held back until Commit.
+func (s *TaskFileStorage) append(record walRecord) error {
+	if s.wal == nil {
+		return ErrStorageClosed
+	}
+
+	if s.transaction != nil {
+		s.transaction = append(s.transaction, record)
+		return nil
+	}
+
+	if s.records >= s.compactThreshold {
+		if err := s.compact(); err != nil {
+			return err
+		}
+	}
+
+	return s.write(record)
+}
+
+// write writes given record with next Seq into write-ahead log and syncs the
+// log on disk.
+func (s *TaskFileStorage) write(record walRecord) error {
+	if s.wal == nil {
+		return ErrStorageClosed
+	}
+
+	record.Seq = s.seq + 1
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		fmt.Printf("(WARN) storage: Marshaling log record failed: %s\n", err)
+		return err
+	}
+
+	if _, err := s.wal.Write(append(b, '\n')); err != nil {
+		fmt.Printf("(WARN) storage: Writing log record failed: %s\n", err)
+		return err
+	}
+
+	if err := s.wal.Sync(); err != nil {
+		fmt.Printf("(WARN) storage: Syncing log failed: %s\n", err)
+		return err
+	}
+
+	s.seq = record.Seq
+	s.records++
+
+	return nil
+}
+
+// compact writes snapshot into temporary file and atomically renames it. Log
+// is truncated only after the snapshot is safely on disk. If the program
+// crashes in between, records already in the snapshot are skipped by Seq.
+// Compaction is postponed while transaction is in progress because memory
+// contains changes which are not committed yet.
+func (s *TaskFileStorage) compact() error {
+	if s.transaction != nil {
+		fmt.Println("(DEBUG) storage: Compaction postponed. Transaction in progress.")
+		return nil
+	}
+
+	tasks, err := s.memory.FindAll()
+	if err != nil {
+		return err
+	}
+
+	trash, err := s.memory.FindAllTrash()
+	if err != nil {
+		return err
+	}
+
+	history, err := s.memory.FindAllHistory()
+	if err != nil {
+		return err
+	}
+
+	s.memory.lastTaskIDmu.Lock()
+	lastTaskID := s.memory.lastTaskID
+	s.memory.lastTaskIDmu.Unlock()
+
+	snap := snapshot{
+		Seq:        s.seq,
+		LastTaskID: lastTaskID,
+		Tasks:      tasks,
+		Trash:      trash,
+		History:    history,
+	}
+
+	b, err := json.Marshal(snap)
+	if err != nil {
+		fmt.Printf("(WARN) storage: Marshaling snapshot failed: %s\n", err)
+		return err
+	}
+
+	tmpPath := filepath.Join(s.dir, snapshotFileName+".tmp")
+	if err := writeFileSync(tmpPath, b); err != nil {
+		fmt.Printf("(WARN) storage: Writing snapshot failed: %s\n", err)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(s.dir, snapshotFileName)); err != nil {
+		fmt.Printf("(WARN) storage: Renaming snapshot failed: %s\n", err)
+		return err
+	}
+
+	if err := syncDir(s.dir); err != nil {
+		fmt.Printf("(WARN) storage: Syncing data directory failed: %s\n", err)
+		return err
+	}
+
+	if err := s.wal.Truncate(0); err != nil {
+		fmt.Printf("(WARN) storage: Truncating log failed: %s\n", err)
+		return err
+	}
+
+	if _, err := s.wal.Seek(0, io.SeekStart); err != nil {
+		fmt.Printf("(WARN) storage: Truncating log failed: %s\n", err)
+		return err
+	}
+
+	s.records = 0
+
+	return nil
+}
+
+// loadSnapshot loads snapshot from data directory into memory. Missing
+// snapshot means empty storage.
+func (s *TaskFileStorage) loadSnapshot() error {
+	b, err := os.ReadFile(filepath.Join(s.dir, snapshotFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		fmt.Printf("(WARN) storage: Reading snapshot failed: %s\n", err)
+		return err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		fmt.Printf("(WARN) storage: Unmarshaling snapshot failed: %s\n", err)
+		return err
+	}
+
+	for i := range snap.Tasks {
+		task := snap.Tasks[i]
+		s.memory.storage[task.ID] = &task
+	}
+	s.memory.reindex()
+	for i := range snap.Trash {
+		trashed := snap.Trash[i]
+		s.memory.trash[trashed.Task.ID] = &trashed
+	}
+	for i := range snap.History {
+		s.memory.InsertHistory(&snap.History[i])
+	}
+	s.memory.lastTaskID = snap.LastTaskID
+	s.seq = snap.Seq
+
+	return nil
+}
+
+// replay opens write-ahead log and applies records which are not part of the
+// snapshot. Incomplete record at the end of the log (caused by crash during
+// write) is cut off.
+func (s *TaskFileStorage) replay() error {
+	wal, err := os.OpenFile(filepath.Join(s.dir, walFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		fmt.Printf("(WARN) storage: Opening log failed: %s\n", err)
+		return err
+	}
+
+	var offset int64
+	reader := bufio.NewReader(wal)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				fmt.Println("(INFO) storage: Replaying log found incomplete record. Record skipped.")
+			}
+			break
+		}
+		if err != nil {
+			fmt.Printf("(WARN) storage: Reading log failed: %s\n", err)
+			wal.Close()
+			return err
+		}
+
+		var record walRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			fmt.Printf("(INFO) storage: Replaying log found corrupted record: %s. Rest of the log skipped.\n", err)
+			break
+		}
+		offset += int64(len(line))
+
+		if record.Seq <= s.seq {
+			continue
+		}
+
+		s.apply(record)
+		s.seq = record.Seq
+		s.records++
+	}
+
+	// Cut off everything after the last valid record so new records are
+	// appended right after it.
+	if err := wal.Truncate(offset); err != nil {
+		fmt.Printf("(WARN) storage: Truncating log failed: %s\n", err)
+		wal.Close()
+		return err
+	}
+
+	if _, err := wal.Seek(offset, io.SeekStart); err != nil {
+		fmt.Printf("(WARN) storage: Seeking log failed: %s\n", err)
+		wal.Close()
+		return err
+	}
+
+	s.wal = wal
+
+	return nil
+}
+
+// apply applies log record on memory storage. Operations which failed
+// originally (eg. Task was not found) fail the same way during replay so
+// errors are ignored.
+func (s *TaskFileStorage) apply(record walRecord) {
+	var err error
+	switch record.Op {
+	case walOpInsert:
+		err = s.memory.Insert(record.Path, record.Task)
+		// Inserted subtree (eg. created with sub tasks or cloned) has new
+		// TaskIDs also in its children.
+		if id := maxTaskID(record.Task); id > s.memory.lastTaskID {
+			s.memory.lastTaskID = id
+		}
+	case walOpUpdate:
+		err = s.memory.Update(record.Path, record.Task)
+	case walOpDelete:
+		err = s.memory.Delete(record.Path)
+	case walOpMove:
+		err = s.memory.Move(record.Path, record.Target)
+	case walOpTrash:
+		if record.Trashed == nil {
+			err = errors.New("missing trashed Task")
+			break
+		}
+		err = s.memory.InsertTrash(record.Trashed)
+	case walOpPurge:
+		if len(record.Path) != 1 {
+			err = ErrTaskPathNotValid
+			break
+		}
+		err = s.memory.DeleteTrash(record.Path[0])
+	case walOpHistory:
+		if record.History == nil {
+			err = errors.New("missing history entry")
+			break
+		}
+		err = s.memory.InsertHistory(record.History)
+	case walOpBatch:
+		for _, r := range record.Records {
+			r.Seq = record.Seq
+			s.apply(r)
+		}
+	default:
+		err = fmt.Errorf("unknown operation %q", record.Op)
+	}
+
+	if err != nil {
+		fmt.Printf("(DEBUG) storage: Replaying log record %d failed: %s\n", record.Seq, err)
+	}
+}
+
+// maxTaskID returns the highest TaskID of the Task and all its descendants
+// or 0 for nil Task.
+func maxTaskID(task *Task) TaskID {
+	if task == nil {
+		return 0
+	}
+
+	max := task.ID
+	for _, child := range task.Children {
+		if id := maxTaskID(child); id > max {
+			max = id
+		}
+	}
+
+	return max
+}
+
+// writeFileSync writes data into file at given path and syncs it on disk.
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// syncDir syncs directory entries on disk so renamed file survives crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,383 @@
+package tasks
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTaskFileStorageReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	storage, err := NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t1 := &Task{ID: storage.NextTaskID(), Label: "foo", Children: SubTasks{}}
+	t2 := &Task{ID: storage.NextTaskID(), Label: "bar", Children: SubTasks{}}
+	t3 := &Task{ID: storage.NextTaskID(), Label: "baz", Children: SubTasks{}}
+
+	if err := storage.Insert([]TaskID{}, t1); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Insert([]TaskID{t1.ID}, t2); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Insert([]TaskID{}, t3); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Update([]TaskID{t1.ID, t2.ID}, &Task{ID: t2.ID, Label: "bar_new", Completed: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Delete([]TaskID{t3.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Do not close the storage - simulate crash of the program.
+	reopened, err := NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := reopened.Find([]TaskID{t1.ID, t2.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Label != "bar_new" || !res.Completed {
+		t.Fatalf("expected updated task got %v", res)
+	}
+
+	if _, err := reopened.Find([]TaskID{t3.ID}); err != ErrTaskNotFound {
+		t.Fatalf("expected err %s got %s", ErrTaskNotFound, err)
+	}
+
+	if id := reopened.NextTaskID(); id != TaskID(4) {
+		t.Fatalf("expected next id %d got %d", TaskID(4), id)
+	}
+}
+
+func TestTaskFileStorageReopenSubTasks(t *testing.T) {
+	dir := t.TempDir()
+
+	storage, err := NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := NewTaskStorageService(storage, NewSystemClock())
+
+	created, err := service.Create([]TaskID{}, CreateFields{
+		Label: "foo",
+		SubTasks: []CreateFields{
+			CreateFields{Label: "bar"},
+			CreateFields{Label: "baz", SubTasks: []CreateFields{CreateFields{Label: "qux"}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Batch records are replayed the same way.
+	if _, err := service.Batch([]BatchOperation{
+		{Op: BatchOpCreate, Create: CreateFields{Label: "quux", SubTasks: []CreateFields{CreateFields{Label: "corge"}}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Do not close the storage - simulate crash of the program.
+	reopened, err := NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service = NewTaskStorageService(reopened, NewSystemClock())
+
+	task, err := service.Create([]TaskID{}, CreateFields{Label: "grault"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if task.ID != TaskID(7) {
+		t.Fatalf("expected new Task %d got %d", TaskID(7), task.ID)
+	}
+
+	if res, err := service.Find([]TaskID{created.ID}); err != nil || len(res.Children) != 2 {
+		t.Fatalf("expected Task %d with 2 sub tasks got %v (%v)", created.ID, res, err)
+	}
+}
+
+func TestTaskFileStorageTrash(t *testing.T) {
+	dir := t.TempDir()
+
+	storage, err := NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		trashed := &TrashedTask{
+			Task: Task{ID: storage.NextTaskID(), Label: "foo", Children: SubTasks{}},
+			Path: TaskIDPath{TaskID(i + 1)},
+		}
+		if err := storage.InsertTrash(trashed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := storage.DeleteTrash(TaskID(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Trash is replayed from the log.
+	reopened, err := NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := reopened.FindTrash(TaskID(1)); err != ErrTrashedTaskNotFound {
+		t.Fatalf("expected err %s got %s", ErrTrashedTaskNotFound, err)
+	}
+
+	if err := reopened.DeleteTrash(TaskID(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reopened.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Trash is loaded from the snapshot.
+	reopened, err = NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trash, err := reopened.FindAllTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trash) != 1 || trash[0].Task.ID != TaskID(3) {
+		t.Fatalf("expected trashed task %d got %v", TaskID(3), trash)
+	}
+}
+
+func TestTaskFileStorageHistory(t *testing.T) {
+	dir := t.TempDir()
+
+	storage, err := NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		entry := &HistoryEntry{
+			Operation: i + 1,
+			Op:        OperationUpdate,
+			Action:    HistoryActionUpdate,
+			TaskID:    TaskID(i%2 + 1),
+			Version:   i/2 + 1,
+			After:     &Task{ID: TaskID(i%2 + 1), Label: "foo"},
+		}
+		if err := storage.InsertHistory(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// History is replayed from the log.
+	reopened, err := NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := reopened.FindHistory(TaskID(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history) != 2 || history[1].Operation != 3 {
+		t.Fatalf("expected 2 changes of task %d got %v", TaskID(1), history)
+	}
+
+	if err := reopened.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// History is loaded from the snapshot.
+	reopened, err = NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err = reopened.FindAllHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history) != 3 || history[1].TaskID != TaskID(2) {
+		t.Fatalf("expected 3 changes got %v", history)
+	}
+}
+
+func TestTaskFileStorageCompact(t *testing.T) {
+	dir := t.TempDir()
+
+	storage, err := NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.compactThreshold = 2
+
+	for i := 0; i < 5; i++ {
+		task := &Task{ID: storage.NextTaskID(), Label: "foo", Children: SubTasks{}}
+		if err := storage.Insert([]TaskID{}, task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, snapshotFileName)); err != nil {
+		t.Fatalf("expected snapshot got %s", err)
+	}
+
+	if err := storage.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, walFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 {
+		t.Fatalf("expected empty log got %d bytes", info.Size())
+	}
+
+	reopened, err := NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := reopened.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 5 {
+		t.Fatalf("expected %d tasks got %d", 5, len(tasks))
+	}
+
+	if id := reopened.NextTaskID(); id != TaskID(6) {
+		t.Fatalf("expected next id %d got %d", TaskID(6), id)
+	}
+}
+
+func TestTaskFileStorageIncompleteRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	storage, err := NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task := &Task{ID: storage.NextTaskID(), Label: "foo", Children: SubTasks{}}
+	if err := storage.Insert([]TaskID{}, task); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate crash in the middle of writing the record.
+	if _, err := storage.wal.WriteString(`{"seq":2,"op":"insert","path":[],"ta`); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := reopened.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("expected %d tasks got %d", 1, len(tasks))
+	}
+
+	// New records must be appended after the last valid record.
+	t2 := &Task{ID: reopened.NextTaskID(), Label: "bar", Children: SubTasks{}}
+	if err := reopened.Insert([]TaskID{task.ID}, t2); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := again.Find([]TaskID{task.ID, t2.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Label != "bar" {
+		t.Fatalf("expected label %s got %s", "bar", res.Label)
+	}
+}
+
+func TestTaskFileStorageTransaction(t *testing.T) {
+	dir := t.TempDir()
+
+	storage, err := NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.compactThreshold = 2
+
+	foo := &Task{ID: storage.NextTaskID(), Label: "foo", Children: SubTasks{}}
+	if err := storage.Insert([]TaskID{}, foo); err != nil {
+		t.Fatal(err)
+	}
+
+	// insert inserts new Tasks under foo in transaction.
+	insert := func(labels ...string) {
+		if err := storage.Begin(); err != nil {
+			t.Fatal(err)
+		}
+		for _, label := range labels {
+			task := &Task{ID: storage.NextTaskID(), Label: label, Children: SubTasks{}}
+			if err := storage.Insert([]TaskID{foo.ID}, task); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	insert("bar", "baz", "qux")
+	if err := storage.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	insert("rolled back")
+	if err := storage.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Transaction in progress is not compacted and it's lost by the crash.
+	insert("not committed")
+	if err := storage.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewTaskFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := reopened.Find([]TaskID{foo.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := map[string]bool{}
+	for _, child := range res.Children {
+		labels[child.Label] = true
+	}
+	if len(labels) != 3 || !labels["bar"] || !labels["baz"] || !labels["qux"] {
+		t.Fatalf("expected committed tasks got %v", labels)
+	}
+}
@@ -2,6 +2,8 @@ package tasks
 
 import (
 	"reflect"
+	"sort"
+	"sync"
 	"testing"
 )
 
@@ -63,6 +65,7 @@ func TestTaskMemoryStorageInsert(t *testing.T) {
 
 		storage := NewTaskMemoryStorage()
 		storage.storage = tc.storage
+		storage.reindex()
 
 		err := storage.Insert(tc.path, tc.task)
 		if err != tc.err {
@@ -154,6 +157,7 @@ func TestTaskMemoryStorageFind(t *testing.T) {
 
 		storage := NewTaskMemoryStorage()
 		storage.storage = tc.storage
+		storage.reindex()
 
 		res, err := storage.Find(tc.path)
 		if err != tc.err {
@@ -265,6 +269,7 @@ func TestTaskMemoryStorageUpdate(t *testing.T) {
 
 		storage := NewTaskMemoryStorage()
 		storage.storage = tc.storage
+		storage.reindex()
 
 		err := storage.Update(tc.path, tc.task)
 		if err != tc.err {
@@ -355,6 +360,7 @@ func TestTaskMemoryStorageDelete(t *testing.T) {
 
 		storage := NewTaskMemoryStorage()
 		storage.storage = tc.storage
+		storage.reindex()
 
 		err := storage.Delete(tc.path)
 		if err != tc.err {
@@ -446,6 +452,7 @@ func TestTaskMemoryStorageSearch(t *testing.T) {
 
 		storage := NewTaskMemoryStorage()
 		storage.storage = tc.storage
+		storage.reindex()
 
 		res, err := storage.search(tc.path)
 		if err != tc.err {
@@ -473,3 +480,355 @@ func TestTaskMemoryStorageSearch(t *testing.T) {
 		}
 	}
 }
+
+func TestTaskMemoryStorageConcurrent(t *testing.T) {
+	storage := NewTaskMemoryStorage()
+	root := &Task{ID: storage.NextTaskID(), Label: "root", Children: SubTasks{}}
+	if err := storage.Insert([]TaskID{}, root); err != nil {
+		t.Fatal(err)
+	}
+
+	const workers = 16
+	const tasks = 50
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < tasks; j++ {
+				task := &Task{ID: storage.NextTaskID(), Label: "foo", Children: SubTasks{}}
+				path := []TaskID{root.ID, task.ID}
+
+				if err := storage.Insert([]TaskID{root.ID}, task); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := storage.Update(path, &Task{ID: task.ID, Label: "bar"}); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := storage.Find(path); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := storage.FindAll(); err != nil {
+					t.Error(err)
+					return
+				}
+				if j%2 == 0 {
+					if err := storage.Delete(path); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	res, err := storage.Find([]TaskID{root.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Children) != workers*tasks/2 {
+		t.Fatalf("expected %d children got %d", workers*tasks/2, len(res.Children))
+	}
+}
+
+func TestTaskMemoryStorageFindByID(t *testing.T) {
+	storage := NewTaskMemoryStorage()
+
+	t1 := &Task{ID: TaskID(1), Label: "foo"}
+	t2 := &Task{ID: TaskID(2), Label: "bar"}
+	t3 := &Task{ID: TaskID(3), Label: "baz"}
+
+	for _, insert := range []struct {
+		path []TaskID
+		task *Task
+	}{
+		{[]TaskID{}, t1},
+		{[]TaskID{t1.ID}, t2},
+		{[]TaskID{t1.ID, t2.ID}, t3},
+	} {
+		if err := storage.Insert(insert.path, insert.task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Update must keep children of updated Task in the index.
+	if err := storage.Update([]TaskID{t1.ID, t2.ID}, &Task{ID: t2.ID, Label: "bar_new"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		taskID TaskID
+		res    Task
+		path   []TaskID
+		err    error
+	}{
+		"root": {
+			taskID: t1.ID,
+			res:    *t1,
+			path:   []TaskID{t1.ID},
+		},
+		"updated": {
+			taskID: t2.ID,
+			res:    Task{ID: t2.ID, Label: "bar_new"},
+			path:   []TaskID{t1.ID, t2.ID},
+		},
+		"deep": {
+			taskID: t3.ID,
+			res:    *t3,
+			path:   []TaskID{t1.ID, t2.ID, t3.ID},
+		},
+		"not found": {
+			taskID: TaskID(4),
+			err:    ErrTaskNotFound,
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		res, path, err := storage.FindByID(tc.taskID)
+		if err != tc.err {
+			t.Fatalf("expected err %s got %s", tc.err, err)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if tc.res.ID != res.ID {
+			t.Fatalf("expected id %d got %d", tc.res.ID, res.ID)
+		}
+
+		if tc.res.Label != res.Label {
+			t.Fatalf("expected label %s got %s", tc.res.Label, res.Label)
+		}
+
+		if !reflect.DeepEqual(tc.path, path) {
+			t.Fatalf("expected path %v got %v", tc.path, path)
+		}
+	}
+
+	// Delete must remove whole subtree from the index.
+	if err := storage.Delete([]TaskID{t1.ID, t2.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, taskID := range []TaskID{t2.ID, t3.ID} {
+		if _, _, err := storage.FindByID(taskID); err != ErrTaskNotFound {
+			t.Fatalf("expected err %s got %s", ErrTaskNotFound, err)
+		}
+	}
+
+	if _, err := storage.Find([]TaskID{t2.ID}); err != ErrTaskNotFound {
+		t.Fatalf("expected err %s got %s", ErrTaskNotFound, err)
+	}
+}
+
+func TestTaskMemoryStorageMove(t *testing.T) {
+	tests := map[string]struct {
+		from     []TaskID
+		toParent []TaskID
+		err      error
+		expPath  []TaskID
+	}{
+		"empty path": {
+			from: []TaskID{},
+			err:  ErrTaskPathNotValid,
+		},
+		"not found": {
+			from: []TaskID{TaskID(1), TaskID(4)},
+			err:  ErrTaskNotFound,
+		},
+		"target not found": {
+			from:     []TaskID{TaskID(1), TaskID(2)},
+			toParent: []TaskID{TaskID(2)},
+			err:      ErrTaskNotFound,
+		},
+		"under itself": {
+			from:     []TaskID{TaskID(1), TaskID(2)},
+			toParent: []TaskID{TaskID(1), TaskID(2)},
+			err:      ErrTaskMoveNotValid,
+		},
+		"under descendant": {
+			from:     []TaskID{TaskID(1)},
+			toParent: []TaskID{TaskID(1), TaskID(2), TaskID(3)},
+			err:      ErrTaskMoveNotValid,
+		},
+		"to other subtree": {
+			from:     []TaskID{TaskID(1), TaskID(2)},
+			toParent: []TaskID{TaskID(5)},
+			expPath:  []TaskID{TaskID(5), TaskID(2)},
+		},
+		"to top level": {
+			from:     []TaskID{TaskID(1), TaskID(2)},
+			toParent: []TaskID{},
+			expPath:  []TaskID{TaskID(2)},
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		// Tree: 1 -> 2 -> 3, 5
+		storage := NewTaskMemoryStorage()
+		storage.storage = map[TaskID]*Task{
+			TaskID(1): &Task{ID: TaskID(1), Label: "foo", Children: SubTasks{
+				TaskID(2): &Task{ID: TaskID(2), Label: "bar", Children: SubTasks{
+					TaskID(3): &Task{ID: TaskID(3), Label: "baz"},
+				}},
+			}},
+			TaskID(5): &Task{ID: TaskID(5), Label: "qux"},
+		}
+		storage.reindex()
+
+		err := storage.Move(tc.from, tc.toParent)
+		if err != tc.err {
+			t.Fatalf("expected err %s got %s", tc.err, err)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if _, err := storage.Find(tc.from); err != ErrTaskNotFound {
+			t.Fatalf("expected err %s got %s", ErrTaskNotFound, err)
+		}
+
+		// Moved Task must keep its children.
+		res, err := storage.Find(append(tc.expPath, TaskID(3)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Label != "baz" {
+			t.Fatalf("expected label %s got %s", "baz", res.Label)
+		}
+
+		_, path, err := storage.FindByID(TaskID(3))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expPath := append(tc.expPath, TaskID(3)); !reflect.DeepEqual(expPath, path) {
+			t.Fatalf("expected path %v got %v", expPath, path)
+		}
+	}
+}
+
+func TestTaskMemoryStorageTransaction(t *testing.T) {
+	storage := NewTaskMemoryStorage()
+
+	foo := &Task{ID: TaskID(1), Label: "foo deploy", Children: SubTasks{}}
+	bar := &Task{ID: TaskID(2), Label: "bar", Children: SubTasks{}}
+	baz := &Task{ID: TaskID(3), Label: "baz", Children: SubTasks{}}
+	if err := storage.Insert([]TaskID{}, foo); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Insert([]TaskID{foo.ID}, bar); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Insert([]TaskID{}, baz); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.InsertTrash(&TrashedTask{Task: Task{ID: TaskID(4), Label: "qux"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// state returns everything stored so it can be compared after rollback.
+	state := func() []interface{} {
+		tasks, err := storage.FindAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Sort(ByTaskID(tasks))
+
+		trash, err := storage.FindAllTrash()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		history, err := storage.FindAllHistory()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		matches, err := storage.FindByText("deploy")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Task bar is not in the tree after its parent is replaced.
+		_, path, _ := storage.FindByID(bar.ID)
+
+		return []interface{}{tasks, trash, history, matches, path}
+	}
+	before := state()
+
+	if err := storage.Commit(); err != ErrStorageTransactionNotStarted {
+		t.Fatalf("expected error %v got %v", ErrStorageTransactionNotStarted, err)
+	}
+
+	if err := storage.Begin(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storage.Begin(); err != ErrStorageTransactionInProgress {
+		t.Fatalf("expected error %v got %v", ErrStorageTransactionInProgress, err)
+	}
+
+	changes := []func() error{
+		func() error {
+			return storage.Insert([]TaskID{foo.ID, bar.ID}, &Task{ID: TaskID(5), Label: "deploy child"})
+		},
+		func() error { return storage.Update([]TaskID{foo.ID}, &Task{ID: foo.ID, Label: "foo"}) },
+		func() error { return storage.Move([]TaskID{foo.ID, bar.ID}, []TaskID{baz.ID}) },
+		func() error { return storage.Update([]TaskID{baz.ID, bar.ID}, &Task{ID: bar.ID, Label: "bar deploy"}) },
+		func() error { return storage.Insert([]TaskID{}, &Task{ID: baz.ID, Label: "baz replaced"}) },
+		func() error { return storage.Delete([]TaskID{foo.ID}) },
+		func() error { return storage.InsertTrash(&TrashedTask{Task: *foo}) },
+		func() error { return storage.DeleteTrash(TaskID(4)) },
+		func() error { return storage.InsertHistory(&HistoryEntry{TaskID: foo.ID, Action: HistoryActionDelete}) },
+	}
+	for i, change := range changes {
+		if err := change(); err != nil {
+			t.Fatalf("change %d failed: %s", i, err)
+		}
+	}
+
+	if reflect.DeepEqual(before, state()) {
+		t.Fatal("expected changed state in transaction")
+	}
+
+	if err := storage.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if after := state(); !reflect.DeepEqual(before, after) {
+		t.Fatalf("expected state \n%v\n after rollback got \n%v\n", before, after)
+	}
+
+	// Committed changes are kept and can't be rolled back.
+	if err := storage.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Delete([]TaskID{baz.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storage.Rollback(); err != ErrStorageTransactionNotStarted {
+		t.Fatalf("expected error %v got %v", ErrStorageTransactionNotStarted, err)
+	}
+
+	if _, err := storage.Find([]TaskID{baz.ID}); err != ErrTaskNotFound {
+		t.Fatalf("expected error %v got %v", ErrTaskNotFound, err)
+	}
+}
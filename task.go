@@ -5,6 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var (
@@ -17,7 +24,66 @@ var (
 	// ErrTaskLabelIsNotValid
 	ErrTaskLabelIsNotValid error = errors.New("Task field Label is not valid")
 	// ErrTaskLabelOrCompletedRequired
-	ErrTaskLabelOrCompletedRequired error = errors.New("Task field Label or Completed is required")
+	ErrTaskLabelOrCompletedRequired error = errors.New("At least one Task field is required")
+	// ErrTaskStartAtAfterDueAt
+	ErrTaskStartAtAfterDueAt error = errors.New("Task field StartAt must be before DueAt")
+	// ErrTaskMoveNotValid
+	ErrTaskMoveNotValid error = errors.New("Task can't be moved under itself or its sub task")
+	// ErrTaskMoveTargetRequired
+	ErrTaskMoveTargetRequired error = errors.New("Task move field Target is required")
+	// ErrTaskPreconditionFailed
+	ErrTaskPreconditionFailed error = errors.New("Task was modified")
+	// ErrTaskPatchNotValid
+	ErrTaskPatchNotValid error = errors.New("Task patch is not valid")
+	// ErrTaskPatchTestFailed
+	ErrTaskPatchTestFailed error = errors.New("Task patch test operation failed")
+	// ErrTaskFieldIsReadOnly
+	ErrTaskFieldIsReadOnly error = errors.New("Task fields id, revision, position and timestamps can't be changed")
+	// ErrTaskCloneTargetRequired
+	ErrTaskCloneTargetRequired error = errors.New("Task clone field Target is required")
+	// ErrTaskPriorityIsNotValid
+	ErrTaskPriorityIsNotValid error = errors.New("Task field Priority is not valid")
+	// ErrTaskTagsAreNotValid
+	ErrTaskTagsAreNotValid error = errors.New("Task field Tags is not valid")
+	// ErrTaskNotesAreNotValid
+	ErrTaskNotesAreNotValid error = errors.New("Task field Notes is too long")
+	// ErrTaskReorderIDsRequired
+	ErrTaskReorderIDsRequired error = errors.New("Task reorder field IDs is required")
+	// ErrTaskReorderNotValid
+	ErrTaskReorderNotValid error = errors.New("Task reorder IDs must contain every sub task exactly once")
+	// ErrTaskFieldsNotValid
+	ErrTaskFieldsNotValid error = errors.New("Task fields selection is not valid")
+	// ErrTaskSubTasksTooDeep
+	ErrTaskSubTasksTooDeep error = errors.New("Task field SubTasks is nested too deep")
+	// ErrTaskSubTasksTooMany
+	ErrTaskSubTasksTooMany error = errors.New("Task field SubTasks has too many tasks")
+)
+
+// Priority levels of the Task. Tasks with higher priority are ordered before
+// siblings with the same position.
+const (
+	PriorityNone   int = 0
+	PriorityLow    int = 1
+	PriorityMedium int = 2
+	PriorityHigh   int = 3
+)
+
+// maxNotesLength is maximal length of Task notes in bytes.
+const maxNotesLength = 10000
+
+// Limits of subtree created by one request. Depth is number of levels of sub
+// tasks below the created Task, count includes the created Task.
+const (
+	maxCreateDepth = 10
+	maxCreateTasks = 1000
+)
+
+// Tags limits. Tag must start with lower case letter or digit and may
+// contain lower case letters, digits, dashes and underscores.
+const (
+	maxTagLength  = 32
+	maxTaskTags   = 20
+	tagCharacters = "abcdefghijklmnopqrstuvwxyz0123456789-_"
 )
 
 // TaskID is alias for int type.
@@ -29,10 +95,230 @@ type Task struct {
 	ID TaskID `json:"id,string"`
 	// Label is name of the Task.
 	Label string `json:"label"`
-	// Completed identifies if given Task is completed.
+	// Completed identifies if given Task is completed. It's derived from
+	// Status, only done Task is completed.
 	Completed bool `json:"completed"`
+	// Status is workflow state of the Task.
+	Status Status `json:"status,omitempty"`
+	// DueAt is optional deadline of the Task.
+	DueAt *time.Time `json:"due_at,omitempty"`
+	// StartAt is optional time when work on the Task should start.
+	StartAt *time.Time `json:"start_at,omitempty"`
+	// Recurrence is optional recurrence rule (subset of iCalendar RRULE).
+	// Completed recurring Task passes the rule to its next occurrence.
+	Recurrence string `json:"recurrence,omitempty"`
+	// Priority is one of the priority levels (PriorityNone by default).
+	Priority int `json:"priority,omitempty"`
+	// Notes is long-form description of the Task in Markdown.
+	Notes string `json:"notes,omitempty"`
+	// Tags is sorted set of free-form tags of the Task.
+	Tags []string `json:"tags,omitempty"`
+	// BlockedBy contains TaskIDs of Tasks which must be finished before the
+	// Task can be completed. Blockers can be anywhere in the tree.
+	BlockedBy TaskIDPath `json:"blocked_by,omitempty"`
+	// Position is order of the Task among its siblings. It's given by
+	// TaskService and changed by reorder.
+	Position int `json:"position,omitempty"`
+	// CreatedAt is time when the Task was created.
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	// UpdatedAt is time of the last change of the Task.
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	// CompletedAt is time when the Task was completed (nil if it's not).
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	// Revision is incremented with every change of the Task.
+	Revision int `json:"revision,omitempty"`
 	// Children contains tasks which have given Task as parent.
 	Children SubTasks `json:"sub_tasks,omitempty"`
+
+	// computedProgress is progress of the Task which was computed before its
+	// children were removed from the copy of the Task.
+	computedProgress *TaskProgress
+	// subTaskCount is number of direct sub tasks of the Task which were
+	// removed from the copy of the Task (see withDepth).
+	subTaskCount *int
+	// fields are JSON fields the Task is marshaled with (all fields if nil).
+	fields TaskFields
+}
+
+// MarshalJSON marshals Task with progress computed from its children and
+// number of sub tasks removed by depth limit. Task with selected fields is
+// marshaled only with them.
+// MarshalJSON implements json.Marshaler interface.
+func (t Task) MarshalJSON() ([]byte, error) {
+	// plainTask has the same fields as Task but not its methods so it's
+	// marshaled by default marshaler.
+	type plainTask Task
+
+	task := plainTask(t)
+	if t.fields != nil {
+		// Sub tasks are marshaled only when they are selected and then
+		// with their own selection.
+		if sub, found := t.fields["sub_tasks"]; found && t.Children != nil {
+			if sub == nil {
+				sub = t.fields
+			}
+			task.Children = t.Children.withFields(sub)
+		} else {
+			task.Children = nil
+		}
+	}
+
+	data, err := json.Marshal(struct {
+		plainTask
+		Progress     *TaskProgress `json:"progress,omitempty"`
+		SubTaskCount *int          `json:"sub_task_count,omitempty"`
+	}{task, t.progress(), t.subTaskCount})
+	if err != nil || t.fields == nil {
+		return data, err
+	}
+
+	return t.fields.project(data)
+}
+
+// withFields returns copy of the Task which is marshaled only with given
+// fields (all fields if nil).
+func (t *Task) withFields(fields TaskFields) *Task {
+	task := *t
+	task.fields = fields
+
+	return &task
+}
+
+// tasksWithFields returns copies of given Tasks which are marshaled only with
+// given fields.
+func tasksWithFields(tasks []Task, fields TaskFields) []Task {
+	if fields == nil {
+		return tasks
+	}
+
+	result := make([]Task, len(tasks))
+	for i := range tasks {
+		result[i] = *tasks[i].withFields(fields)
+	}
+
+	return result
+}
+
+// clone returns deep copy of the Task with all its children.
+func (t *Task) clone() *Task {
+	var children SubTasks
+	if t.Children != nil {
+		children = make(SubTasks, len(t.Children))
+		for id, child := range t.Children {
+			children[id] = child.clone()
+		}
+	}
+
+	return t.withChildren(children)
+}
+
+// withChildren returns copy of the Task fields with given children.
+func (t *Task) withChildren(children SubTasks) *Task {
+	task := *t
+	task.Children = children
+
+	return &task
+}
+
+// sameFields returns true if given Task has the same fields as the Task.
+// Revisions and children are not compared.
+func (t *Task) sameFields(task *Task) bool {
+	a := t.withChildren(nil)
+	b := task.withChildren(nil)
+	a.Revision, b.Revision = 0, 0
+
+	return reflect.DeepEqual(a, b)
+}
+
+// ETag returns entity tag of the Task. It is computed from TaskIDs and
+// revisions of the Task and all its sub tasks so it changes with every change
+// in the subtree.
+func (t *Task) ETag() string {
+	h := fnv.New64a()
+	t.hashRevisions(h)
+
+	return fmt.Sprintf(`"%d-%x"`, t.Revision, h.Sum64())
+}
+
+// hashRevisions writes TaskIDs and revisions of the subtree into hash.
+// Children are written in order of TaskIDs so the hash is stable.
+func (t *Task) hashRevisions(h hash.Hash64) {
+	fmt.Fprintf(h, "%d:%d;", t.ID, t.Revision)
+
+	children := t.Children.list()
+	sort.Sort(ByTaskID(children))
+
+	for i := range children {
+		children[i].hashRevisions(h)
+	}
+}
+
+// matchETag returns true if ETag of the Task is one of given entity tags or
+// if no entity tags are given.
+func (t *Task) matchETag(etags []string) bool {
+	if etags == nil {
+		return true
+	}
+
+	etag := t.ETag()
+	for _, value := range etags {
+		if value == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TaskIDPath is path of TaskIDs from root Task to given Task or other list of
+// TaskIDs. It is serialized as array of strings same as Task ID.
+type TaskIDPath []TaskID
+
+// MarshalJSON marshals TaskIDPath as array of strings.
+// MarshalJSON implements json.Marshaler interface.
+func (p TaskIDPath) MarshalJSON() ([]byte, error) {
+	values := make([]string, len(p))
+	for i, taskID := range p {
+		values[i] = strconv.Itoa(int(taskID))
+	}
+
+	return json.Marshal(values)
+}
+
+// UnmarshalJSON unmarshals TaskIDPath from array of strings or numbers.
+// UnmarshalJSON implements json.Unmarshaler interface.
+func (p *TaskIDPath) UnmarshalJSON(data []byte) error {
+	values := []json.Number{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		fmt.Printf("(DEBUG) task: Unmarshaling TaskID path failed: %s\n", err)
+		return ErrTaskPathNotValid
+	}
+
+	path := make(TaskIDPath, len(values))
+	for i, value := range values {
+		val, err := strconv.Atoi(value.String())
+		if err != nil {
+			fmt.Printf("(DEBUG) task: Unmarshaling TaskID path on value %q failed: %s\n", value, err)
+			return ErrTaskPathNotValid
+		}
+		path[i] = TaskID(val)
+	}
+	*p = path
+
+	return nil
+}
+
+// TaskWithPath is Task together with its TaskID path. It is used in responses
+// where Tasks are found by other means than by their path.
+type TaskWithPath struct {
+	Path TaskIDPath `json:"path"`
+	Task Task       `json:"task"`
+}
+
+// TagCount is tag with number of Tasks which have the tag.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
 }
 
 // ByTaskID is alias type for slice of Task. Used for sorting only.
@@ -47,20 +333,59 @@ func (a ByTaskID) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
 // Less implements sort.Sort interface.
 func (a ByTaskID) Less(i, j int) bool { return a[i].ID < a[j].ID }
 
+// ByPosition is alias type for slice of Task. Used for sorting only. Tasks
+// are ordered by position, then by priority (higher first) and then by
+// TaskID.
+type ByPosition []Task
+
+// Len implements sort.Sort interface.
+func (a ByPosition) Len() int { return len(a) }
+
+// Swap implements sort.Sort interface.
+func (a ByPosition) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+
+// Less implements sort.Sort interface.
+func (a ByPosition) Less(i, j int) bool {
+	if a[i].Position != a[j].Position {
+		return a[i].Position < a[j].Position
+	}
+	if a[i].Priority != a[j].Priority {
+		return a[i].Priority > a[j].Priority
+	}
+
+	return a[i].ID < a[j].ID
+}
+
+// maxPosition returns the highest position of given Tasks or 0 if there are
+// no Tasks.
+func maxPosition(tasks []Task) int {
+	position := 0
+	for _, task := range tasks {
+		if task.Position > position {
+			position = task.Position
+		}
+	}
+
+	return position
+}
+
 // SubTasks is alias type for map of Tasks.
 type SubTasks map[TaskID]*Task
 
 // MarshalJSON marshals Task's subtask. We must use our own marshaler because
 // default Go marshaler don't know how to properly serialize map[TaskID]*Task.
-// We also don't want to serialize as map but as array. This function causes
-// recursive json marshaling for tasks in the tree.
+// We also don't want to serialize as map but as array ordered ByPosition. This
+// function causes recursive json marshaling for tasks in the tree.
 // MarshalJSON implements json.Marshaler interface.
 func (sb SubTasks) MarshalJSON() ([]byte, error) {
 	buffer := bytes.NewBufferString("[")
 
-	l := len(sb)
-	for _, task := range sb {
-		jsonValue, err := json.Marshal(task)
+	tasks := sb.list()
+	sort.Sort(ByPosition(tasks))
+
+	l := len(tasks)
+	for i := range tasks {
+		jsonValue, err := json.Marshal(&tasks[i])
 		if err != nil {
 			fmt.Printf("(WARN) task: Marshaling task struct failed: %s\n", err)
 			return nil, err
@@ -78,14 +403,178 @@ func (sb SubTasks) MarshalJSON() ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
+// list returns Tasks from SubTasks as slice (in random order).
+func (sb SubTasks) list() []Task {
+	tasks := make([]Task, 0, len(sb))
+	for _, task := range sb {
+		tasks = append(tasks, *task)
+	}
+
+	return tasks
+}
+
+// UnmarshalJSON unmarshals Task's subtasks from JSON array produced by
+// MarshalJSON back into the map indexed by TaskID.
+// UnmarshalJSON implements json.Unmarshaler interface.
+func (sb *SubTasks) UnmarshalJSON(data []byte) error {
+	tasks := []*Task{}
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		fmt.Printf("(WARN) task: Unmarshaling task struct failed: %s\n", err)
+		return err
+	}
+
+	children := SubTasks{}
+	for _, task := range tasks {
+		children[task.ID] = task
+	}
+	*sb = children
+
+	return nil
+}
+
+// withFields returns copy of SubTasks whose Tasks are marshaled only with
+// given fields. Sub tasks of the Tasks are shared with the original.
+func (sb SubTasks) withFields(fields TaskFields) SubTasks {
+	children := make(SubTasks, len(sb))
+	for id, child := range sb {
+		children[id] = child.withFields(fields)
+	}
+
+	return children
+}
+
+// taskFieldNames are names of Task JSON fields in the order they are
+// marshaled.
+var taskFieldNames = []string{
+	"id", "label", "completed", "status", "due_at", "start_at", "recurrence",
+	"priority", "notes", "tags", "blocked_by", "position", "created_at",
+	"updated_at", "completed_at", "revision", "sub_tasks", "progress",
+	"sub_task_count",
+}
+
+// TaskFields is selection of Task JSON fields. Only "sub_tasks" can have
+// nested selection for the sub tasks, sub tasks without it are marshaled
+// with the same fields as their parent.
+type TaskFields map[string]TaskFields
+
+// ParseTaskFields parses comma separated list of Task JSON field names with
+// optional nested selection in parentheses after "sub_tasks" (eg.
+// "id,label,sub_tasks(id,label)").
+func ParseTaskFields(value string) (TaskFields, error) {
+	fields, rest, err := parseTaskFieldList(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if rest != "" {
+		fmt.Printf("(DEBUG) task: Parsing fields failed at %q.\n", rest)
+		return nil, ErrTaskFieldsNotValid
+	}
+
+	return fields, nil
+}
+
+// parseTaskFieldList parses comma separated field names up to closing
+// parenthesis or end of the value and returns the rest of the value.
+func parseTaskFieldList(value string) (TaskFields, string, error) {
+	fields := TaskFields{}
+	for {
+		i := strings.IndexAny(value, ",()")
+		if i < 0 {
+			i = len(value)
+		}
+
+		name := strings.TrimSpace(value[:i])
+		if !validTaskField(name) {
+			fmt.Printf("(DEBUG) task: Parsing field %q failed.\n", name)
+			return nil, "", ErrTaskFieldsNotValid
+		}
+		value = value[i:]
+
+		var sub TaskFields
+		if strings.HasPrefix(value, "(") {
+			if name != "sub_tasks" {
+				fmt.Printf("(DEBUG) task: Field %q can't have nested fields.\n", name)
+				return nil, "", ErrTaskFieldsNotValid
+			}
+
+			var err error
+			sub, value, err = parseTaskFieldList(value[1:])
+			if err != nil {
+				return nil, "", err
+			}
+
+			if !strings.HasPrefix(value, ")") {
+				fmt.Printf("(DEBUG) task: Nested fields of %q are not closed.\n", name)
+				return nil, "", ErrTaskFieldsNotValid
+			}
+			value = strings.TrimSpace(value[1:])
+		}
+		fields[name] = sub
+
+		if !strings.HasPrefix(value, ",") {
+			return fields, value, nil
+		}
+		value = value[1:]
+	}
+}
+
+// validTaskField returns true if name is name of Task JSON field.
+func validTaskField(name string) bool {
+	for _, field := range taskFieldNames {
+		if field == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// project returns JSON object with only selected fields of given marshaled
+// Task.
+func (f TaskFields) project(data []byte) ([]byte, error) {
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		fmt.Printf("(WARN) task: Projecting task fields failed: %s\n", err)
+		return nil, err
+	}
+
+	buffer := bytes.NewBufferString("{")
+	for _, name := range taskFieldNames {
+		value, found := values[name]
+		if _, selected := f[name]; !found || !selected {
+			continue
+		}
+
+		if buffer.Len() > 1 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString(strconv.Quote(name))
+		buffer.WriteString(":")
+		buffer.Write(value)
+	}
+	buffer.WriteString("}")
+
+	return buffer.Bytes(), nil
+}
+
 // JSONTask represents Task is JSON request and response. This struct uses
 // pointers because Go uses default values for structs so we can't distiguish
 // if the value was set or not. With pointers we know that value was set (has
 // value) or was not set (is nil). JSONTask also support only fields which are
-// used in create and update flow.
+// used in create and update flow. SubTasks are used only in create flow.
 type JSONTask struct {
-	Label     *string `json:"label"`
-	Completed *bool   `json:"completed"`
+	Label      *string     `json:"label"`
+	Completed  *bool       `json:"completed"`
+	DueAt      *time.Time  `json:"due_at"`
+	StartAt    *time.Time  `json:"start_at"`
+	Priority   *int        `json:"priority"`
+	Tags       *[]string   `json:"tags"`
+	Notes      *string     `json:"notes"`
+	Status     *Status     `json:"status"`
+	BlockedBy  *TaskIDPath `json:"blocked_by"`
+	Recurrence *string     `json:"recurrence"`
+	SubTasks   []JSONTask  `json:"sub_tasks"`
 }
 
 // Valid returns if current Task is valid for given action.
@@ -93,6 +582,132 @@ func (t *JSONTask) Validate(validator TaskActionValidator) error {
 	return validator.Validate(t)
 }
 
+// createFields returns CreateFields with fields of validated JSONTask.
+func (t *JSONTask) createFields() CreateFields {
+	fields := CreateFields{
+		Label:   *t.Label,
+		DueAt:   t.DueAt,
+		StartAt: t.StartAt,
+	}
+	if t.Priority != nil {
+		fields.Priority = *t.Priority
+	}
+	if t.Tags != nil {
+		fields.Tags = *t.Tags
+	}
+	if t.Notes != nil {
+		fields.Notes = *t.Notes
+	}
+	if t.Status != nil {
+		fields.Status = *t.Status
+	}
+	if t.BlockedBy != nil {
+		fields.BlockedBy = *t.BlockedBy
+	}
+	if t.Recurrence != nil {
+		fields.Recurrence = *t.Recurrence
+	}
+	for i := range t.SubTasks {
+		fields.SubTasks = append(fields.SubTasks, t.SubTasks[i].createFields())
+	}
+
+	return fields
+}
+
+// updateFields returns UpdateFields with fields of validated JSONTask.
+func (t *JSONTask) updateFields() UpdateFields {
+	fields := UpdateFields{
+		Label:      t.Label,
+		Completed:  t.Completed,
+		DueAt:      t.DueAt,
+		StartAt:    t.StartAt,
+		Priority:   t.Priority,
+		Tags:       t.Tags,
+		Notes:      t.Notes,
+		Status:     t.Status,
+		Recurrence: t.Recurrence,
+	}
+	if t.BlockedBy != nil {
+		blockedBy := []TaskID(*t.BlockedBy)
+		fields.BlockedBy = &blockedBy
+	}
+
+	return fields
+}
+
+// JSONTaskPatch represents Task patch request. Patch is JSON Merge Patch or
+// JSON Patch document (given by Type) which is applied on the Task JSON.
+type JSONTaskPatch struct {
+	Type  PatchType
+	Patch []byte
+}
+
+// JSONTaskDocument represents patched Task JSON with its sub tasks. Sub tasks
+// without ID are new Tasks. ID, revision, position and timestamps are
+// read-only, they are given by TaskService. Progress is computed so it's
+// ignored.
+type JSONTaskDocument struct {
+	JSONTask
+	ID          *TaskID             `json:"id,string"`
+	Revision    *int                `json:"revision"`
+	Position    *int                `json:"position"`
+	CreatedAt   *time.Time          `json:"created_at"`
+	UpdatedAt   *time.Time          `json:"updated_at"`
+	CompletedAt *time.Time          `json:"completed_at"`
+	Progress    *TaskProgress       `json:"progress"`
+	Children    []*JSONTaskDocument `json:"sub_tasks"`
+}
+
+// JSONMove represents request for moving Task under another parent Task.
+// Target is TaskID path of the new parent, empty path means top level.
+type JSONMove struct {
+	Target *TaskIDPath `json:"target"`
+}
+
+// Validate returns error if move request is not valid.
+func (m *JSONMove) Validate() error {
+	if m.Target == nil {
+		fmt.Println("(DEBUG) task: Move task validation failed. Missing field Target.")
+		return ErrTaskMoveTargetRequired
+	}
+
+	return nil
+}
+
+// JSONClone represents request for cloning Task under another parent Task.
+// Target is TaskID path of the parent of the copy, empty path means top
+// level.
+type JSONClone struct {
+	Target         *TaskIDPath `json:"target"`
+	ResetCompleted bool        `json:"reset_completed"`
+}
+
+// Validate returns error if clone request is not valid.
+func (c *JSONClone) Validate() error {
+	if c.Target == nil {
+		fmt.Println("(DEBUG) task: Clone task validation failed. Missing field Target.")
+		return ErrTaskCloneTargetRequired
+	}
+
+	return nil
+}
+
+// JSONReorder represents request for reordering sub tasks of the Task. IDs
+// contain TaskIDs of all sub tasks in the new order.
+type JSONReorder struct {
+	IDs *TaskIDPath `json:"ids"`
+}
+
+// Validate returns error if reorder request is not valid.
+func (r *JSONReorder) Validate() error {
+	if r.IDs == nil {
+		fmt.Println("(DEBUG) task: Reorder task validation failed. Missing field IDs.")
+		return ErrTaskReorderIDsRequired
+	}
+
+	return nil
+}
+
 // TaskActionValidator is interface with method which validates if given task
 // is valid for given operation.
 type TaskActionValidator interface {
@@ -107,10 +722,44 @@ func NewCreateValidator() *CreateValidator {
 	return &CreateValidator{}
 }
 
-// Validate returns error if given task is not valid and should not be stored
-// in storage.
+// Validate returns error if given task or any of its sub tasks is not valid
+// and should not be stored in storage. Sub tasks may be nested at most
+// maxCreateDepth levels deep and there may be at most maxCreateTasks Tasks.
 // Validate implements TaskActionValidator.
 func (v *CreateValidator) Validate(t *JSONTask) error {
+	count := 0
+	return v.validateTree(t, 0, &count)
+}
+
+// validateTree validates the Task at given depth below the created Task and
+// all its sub tasks. Count is number of Tasks validated so far.
+func (v *CreateValidator) validateTree(t *JSONTask, depth int, count *int) error {
+	if depth > maxCreateDepth {
+		fmt.Println("(DEBUG) task: Create task validation failed. Field SubTasks is nested too deep.")
+		return ErrTaskSubTasksTooDeep
+	}
+
+	*count++
+	if *count > maxCreateTasks {
+		fmt.Println("(DEBUG) task: Create task validation failed. Field SubTasks has too many tasks.")
+		return ErrTaskSubTasksTooMany
+	}
+
+	if err := v.validateFields(t); err != nil {
+		return err
+	}
+
+	for i := range t.SubTasks {
+		if err := v.validateTree(&t.SubTasks[i], depth+1, count); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateFields validates fields of single new Task.
+func (v *CreateValidator) validateFields(t *JSONTask) error {
 	if t.Label == nil {
 		fmt.Println("(DEBUG) task: Create task validation failed. Missing field Label.")
 		return ErrTaskLabelIsRequired
@@ -121,6 +770,92 @@ func (v *CreateValidator) Validate(t *JSONTask) error {
 		return ErrTaskLabelIsNotValid
 	}
 
+	if !validStartAtDueAt(t.StartAt, t.DueAt) {
+		fmt.Println("(DEBUG) task: Create task validation failed. Field StartAt is after DueAt.")
+		return ErrTaskStartAtAfterDueAt
+	}
+
+	if t.Priority != nil && !validPriority(*t.Priority) {
+		fmt.Println("(DEBUG) task: Create task validation failed. Field Priority is not valid.")
+		return ErrTaskPriorityIsNotValid
+	}
+
+	if t.Tags != nil && !validTags(*t.Tags) {
+		fmt.Println("(DEBUG) task: Create task validation failed. Field Tags is not valid.")
+		return ErrTaskTagsAreNotValid
+	}
+
+	if t.Notes != nil && len(*t.Notes) > maxNotesLength {
+		fmt.Println("(DEBUG) task: Create task validation failed. Field Notes is too long.")
+		return ErrTaskNotesAreNotValid
+	}
+
+	if t.Status != nil && !validStatus(*t.Status) {
+		fmt.Println("(DEBUG) task: Create task validation failed. Field Status is not valid.")
+		return ErrTaskStatusIsNotValid
+	}
+
+	if t.Recurrence != nil && !validRecurrence(*t.Recurrence) {
+		fmt.Println("(DEBUG) task: Create task validation failed. Field Recurrence is not valid.")
+		return ErrTaskRecurrenceIsNotValid
+	}
+
+	return nil
+}
+
+// PatchValidator implements TaskActionValidator for Tasks in patched Task
+// document.
+type PatchValidator struct{}
+
+// NewPatchValidator returns new instance of PatchValidator.
+func NewPatchValidator() *PatchValidator {
+	return &PatchValidator{}
+}
+
+// Validate returns error if Task in patched document is not valid. Patched
+// document is complete Task so Label can't be removed.
+// Validate implements TaskActionValidator.
+func (v *PatchValidator) Validate(t *JSONTask) error {
+	if t.Label == nil {
+		fmt.Println("(DEBUG) task: Patch task validation failed. Missing field Label.")
+		return ErrTaskLabelIsRequired
+	}
+
+	if len(*t.Label) < 1 || len(*t.Label) > 100 {
+		fmt.Println("(DEBUG) task: Patch task validation failed. Field Label is not valid.")
+		return ErrTaskLabelIsNotValid
+	}
+
+	if !validStartAtDueAt(t.StartAt, t.DueAt) {
+		fmt.Println("(DEBUG) task: Patch task validation failed. Field StartAt is after DueAt.")
+		return ErrTaskStartAtAfterDueAt
+	}
+
+	if t.Priority != nil && !validPriority(*t.Priority) {
+		fmt.Println("(DEBUG) task: Patch task validation failed. Field Priority is not valid.")
+		return ErrTaskPriorityIsNotValid
+	}
+
+	if t.Tags != nil && !validTags(*t.Tags) {
+		fmt.Println("(DEBUG) task: Patch task validation failed. Field Tags is not valid.")
+		return ErrTaskTagsAreNotValid
+	}
+
+	if t.Notes != nil && len(*t.Notes) > maxNotesLength {
+		fmt.Println("(DEBUG) task: Patch task validation failed. Field Notes is too long.")
+		return ErrTaskNotesAreNotValid
+	}
+
+	if t.Status != nil && !validStatus(*t.Status) {
+		fmt.Println("(DEBUG) task: Patch task validation failed. Field Status is not valid.")
+		return ErrTaskStatusIsNotValid
+	}
+
+	if t.Recurrence != nil && !validRecurrence(*t.Recurrence) {
+		fmt.Println("(DEBUG) task: Patch task validation failed. Field Recurrence is not valid.")
+		return ErrTaskRecurrenceIsNotValid
+	}
+
 	return nil
 }
 
@@ -137,8 +872,8 @@ func NewUpdateValidator() *UpdateValidator {
 // Validate implements TaskActionValidator.
 func (v *UpdateValidator) Validate(t *JSONTask) error {
 	// At least one of the value should be set.
-	if t.Label == nil && t.Completed == nil {
-		fmt.Println("(DEBUG) task: Update task validation failed. Neither Label or Completed fields are set.")
+	if t.Label == nil && t.Completed == nil && t.DueAt == nil && t.StartAt == nil && t.Priority == nil && t.Tags == nil && t.Notes == nil && t.Status == nil && t.BlockedBy == nil && t.Recurrence == nil {
+		fmt.Println("(DEBUG) task: Update task validation failed. No field is set.")
 		return ErrTaskLabelOrCompletedRequired
 	}
 
@@ -149,5 +884,109 @@ func (v *UpdateValidator) Validate(t *JSONTask) error {
 		}
 	}
 
+	if !validStartAtDueAt(t.StartAt, t.DueAt) {
+		fmt.Println("(DEBUG) task: Update task validation failed. Field StartAt is after DueAt.")
+		return ErrTaskStartAtAfterDueAt
+	}
+
+	if t.Priority != nil && !validPriority(*t.Priority) {
+		fmt.Println("(DEBUG) task: Update task validation failed. Field Priority is not valid.")
+		return ErrTaskPriorityIsNotValid
+	}
+
+	if t.Tags != nil && !validTags(*t.Tags) {
+		fmt.Println("(DEBUG) task: Update task validation failed. Field Tags is not valid.")
+		return ErrTaskTagsAreNotValid
+	}
+
+	if t.Notes != nil && len(*t.Notes) > maxNotesLength {
+		fmt.Println("(DEBUG) task: Update task validation failed. Field Notes is too long.")
+		return ErrTaskNotesAreNotValid
+	}
+
+	if t.Status != nil && !validStatus(*t.Status) {
+		fmt.Println("(DEBUG) task: Update task validation failed. Field Status is not valid.")
+		return ErrTaskStatusIsNotValid
+	}
+
+	if t.Recurrence != nil && !validRecurrence(*t.Recurrence) {
+		fmt.Println("(DEBUG) task: Update task validation failed. Field Recurrence is not valid.")
+		return ErrTaskRecurrenceIsNotValid
+	}
+
 	return nil
 }
+
+// validStartAtDueAt returns false if both StartAt and DueAt are set and
+// StartAt is after DueAt.
+func validStartAtDueAt(startAt, dueAt *time.Time) bool {
+	return startAt == nil || dueAt == nil || !startAt.After(*dueAt)
+}
+
+// validPriority returns true if given priority is one of the priority levels.
+func validPriority(priority int) bool {
+	return priority >= PriorityNone && priority <= PriorityHigh
+}
+
+// validTag returns true if given tag has valid syntax.
+func validTag(tag string) bool {
+	if len(tag) < 1 || len(tag) > maxTagLength || strings.IndexByte("-_", tag[0]) >= 0 {
+		return false
+	}
+
+	for _, c := range tag {
+		if !strings.ContainsRune(tagCharacters, c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validTags returns true if every tag has valid syntax and there are not too
+// many of them. Duplicate tags are allowed, they are removed by normalizeTags.
+func validTags(tags []string) bool {
+	if len(tags) > maxTaskTags {
+		return false
+	}
+
+	for _, tag := range tags {
+		if !validTag(tag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// normalizeTags returns sorted copy of given tags without duplicates or nil
+// if there are no tags.
+func normalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	set := map[string]bool{}
+	normalized := []string{}
+	for _, tag := range tags {
+		if !set[tag] {
+			set[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	sort.Strings(normalized)
+
+	return normalized
+}
+
+// hasTags returns true if Task has all given tags.
+func (t *Task) hasTags(tags []string) bool {
+	for _, tag := range tags {
+		i := sort.SearchStrings(t.Tags, tag)
+		if i == len(t.Tags) || t.Tags[i] != tag {
+			return false
+		}
+	}
+
+	return true
+}
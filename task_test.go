@@ -1,12 +1,25 @@
 package tasks
 
-import "testing"
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestTaskValidator(t *testing.T) {
 	empty := ""
 	tooLong := "1234567890123456789012345678901234567890123456789012345678901234567890123456789012345678901234567890123"
 	label := "foobar"
 	completed := true
+	priority := PriorityHigh
+	badPriority := PriorityHigh + 1
+	tags := []string{"backend", "urgent", "backend", "v2_api"}
+	badTags := []string{"backend", "-urgent"}
+	noTags := []string{}
+	tooLongNotes := strings.Repeat("a", maxNotesLength+1)
+	early := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
 
 	tests := map[string]struct {
 		validator TaskActionValidator
@@ -38,11 +51,148 @@ func TestTaskValidator(t *testing.T) {
 				Label: &label,
 			},
 		},
+		"create sub task not valid": {
+			validator: NewCreateValidator(),
+			jsonTask: &JSONTask{
+				Label: &label,
+				SubTasks: []JSONTask{
+					JSONTask{Label: &label},
+					JSONTask{Label: &label, SubTasks: []JSONTask{JSONTask{Label: &empty}}},
+				},
+			},
+			err: ErrTaskLabelIsNotValid,
+		},
+		"create sub tasks too deep": {
+			validator: NewCreateValidator(),
+			jsonTask:  nestedJSONTask(label, maxCreateDepth+1),
+			err:       ErrTaskSubTasksTooDeep,
+		},
+		"create sub tasks deepest": {
+			validator: NewCreateValidator(),
+			jsonTask:  nestedJSONTask(label, maxCreateDepth),
+		},
+		"create too many sub tasks": {
+			validator: NewCreateValidator(),
+			jsonTask: &JSONTask{
+				Label:    &label,
+				SubTasks: manyJSONTasks(label, maxCreateTasks),
+			},
+			err: ErrTaskSubTasksTooMany,
+		},
+		"create start after due": {
+			validator: NewCreateValidator(),
+			jsonTask: &JSONTask{
+				Label:   &label,
+				DueAt:   &early,
+				StartAt: &late,
+			},
+			err: ErrTaskStartAtAfterDueAt,
+		},
+		"create start equals due": {
+			validator: NewCreateValidator(),
+			jsonTask: &JSONTask{
+				Label:   &label,
+				DueAt:   &early,
+				StartAt: &early,
+			},
+		},
+		"create priority": {
+			validator: NewCreateValidator(),
+			jsonTask: &JSONTask{
+				Label:    &label,
+				Priority: &priority,
+			},
+		},
+		"create priority not valid": {
+			validator: NewCreateValidator(),
+			jsonTask: &JSONTask{
+				Label:    &label,
+				Priority: &badPriority,
+			},
+			err: ErrTaskPriorityIsNotValid,
+		},
+		"create tags": {
+			validator: NewCreateValidator(),
+			jsonTask: &JSONTask{
+				Label: &label,
+				Tags:  &tags,
+			},
+		},
+		"create tags not valid": {
+			validator: NewCreateValidator(),
+			jsonTask: &JSONTask{
+				Label: &label,
+				Tags:  &badTags,
+			},
+			err: ErrTaskTagsAreNotValid,
+		},
+		"patch label nil": {
+			validator: NewPatchValidator(),
+			jsonTask: &JSONTask{
+				Completed: &completed,
+			},
+			err: ErrTaskLabelIsRequired,
+		},
+		"patch label too long": {
+			validator: NewPatchValidator(),
+			jsonTask: &JSONTask{
+				Label: &tooLong,
+			},
+			err: ErrTaskLabelIsNotValid,
+		},
+		"patch pass": {
+			validator: NewPatchValidator(),
+			jsonTask: &JSONTask{
+				Label:     &label,
+				Completed: &completed,
+			},
+		},
 		"update both nil": {
 			validator: NewUpdateValidator(),
 			jsonTask:  &JSONTask{},
 			err:       ErrTaskLabelOrCompletedRequired,
 		},
+		"update due only": {
+			validator: NewUpdateValidator(),
+			jsonTask: &JSONTask{
+				DueAt: &late,
+			},
+		},
+		"update start after due": {
+			validator: NewUpdateValidator(),
+			jsonTask: &JSONTask{
+				DueAt:   &early,
+				StartAt: &late,
+			},
+			err: ErrTaskStartAtAfterDueAt,
+		},
+		"update priority not valid": {
+			validator: NewUpdateValidator(),
+			jsonTask: &JSONTask{
+				Priority: &badPriority,
+			},
+			err: ErrTaskPriorityIsNotValid,
+		},
+		"update tags empty": {
+			validator: NewUpdateValidator(),
+			jsonTask: &JSONTask{
+				Tags: &noTags,
+			},
+		},
+		"update tags not valid": {
+			validator: NewUpdateValidator(),
+			jsonTask: &JSONTask{
+				Tags: &badTags,
+			},
+			err: ErrTaskTagsAreNotValid,
+		},
+		"update notes too long": {
+			validator: NewUpdateValidator(),
+			jsonTask: &JSONTask{
+				Notes: &tooLongNotes,
+			},
+			err: ErrTaskNotesAreNotValid,
+		},
 		"update label nil": {
 			validator: NewUpdateValidator(),
 			jsonTask: &JSONTask{
@@ -84,3 +234,121 @@ func TestTaskValidator(t *testing.T) {
 		}
 	}
 }
+
+// nestedJSONTask returns JSONTask with chain of sub tasks of given depth.
+func nestedJSONTask(label string, depth int) *JSONTask {
+	task := &JSONTask{Label: &label}
+	if depth > 0 {
+		task.SubTasks = []JSONTask{*nestedJSONTask(label, depth-1)}
+	}
+
+	return task
+}
+
+// manyJSONTasks returns n JSONTasks with given label.
+func manyJSONTasks(label string, n int) []JSONTask {
+	tasks := make([]JSONTask, n)
+	for i := range tasks {
+		tasks[i].Label = &label
+	}
+
+	return tasks
+}
+
+func TestValidTag(t *testing.T) {
+	tests := map[string]bool{
+		"backend":                           true,
+		"v2":                                true,
+		"team-a_b":                          true,
+		"":                                  false,
+		"Backend":                           false,
+		"-backend":                          false,
+		"back end":                          false,
+		"héllo":                             false,
+		"123456789012345678901234567890123": false,
+	}
+
+	for tag, valid := range tests {
+		t.Log(tag)
+
+		if validTag(tag) != valid {
+			t.Fatalf("expected valid %t got %t", valid, !valid)
+		}
+	}
+}
+
+func TestSubTasksMarshalJSON(t *testing.T) {
+	children := SubTasks{
+		TaskID(1): &Task{ID: TaskID(1), Label: "foo", Position: 2},
+		TaskID(2): &Task{ID: TaskID(2), Label: "bar", Position: 1},
+		TaskID(3): &Task{ID: TaskID(3), Label: "baz", Position: 2, Priority: PriorityHigh},
+		TaskID(4): &Task{ID: TaskID(4), Label: "qux", Position: 2},
+	}
+
+	res, err := json.Marshal(children)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[{"id":"2","label":"bar","completed":false,"position":1},` +
+		`{"id":"3","label":"baz","completed":false,"priority":3,"position":2},` +
+		`{"id":"1","label":"foo","completed":false,"position":2},` +
+		`{"id":"4","label":"qux","completed":false,"position":2}]`
+	if expected != string(res) {
+		t.Fatalf("expected \n%s\n got \n%s\n", expected, res)
+	}
+}
+
+func TestTaskMarshalJSONWithFields(t *testing.T) {
+	task := &Task{
+		ID:       TaskID(1),
+		Label:    "foo",
+		Priority: PriorityHigh,
+		Children: SubTasks{
+			TaskID(2): &Task{
+				ID:        TaskID(2),
+				Label:     "bar",
+				Completed: true,
+				Position:  1,
+				Children: SubTasks{
+					TaskID(3): &Task{ID: TaskID(3), Label: "baz"},
+				},
+			},
+		},
+	}
+
+	tests := map[string]string{
+		"label,id":                             `{"id":"1","label":"foo"}`,
+		"id, priority ,notes":                  `{"id":"1","priority":3}`,
+		"id,sub_tasks":                         `{"id":"1","sub_tasks":[{"id":"2","sub_tasks":[{"id":"3"}]}]}`,
+		"label,sub_tasks(id,completed)":        `{"label":"foo","sub_tasks":[{"id":"2","completed":true}]}`,
+		"sub_tasks(id,sub_tasks(label)),label": `{"label":"foo","sub_tasks":[{"id":"2","sub_tasks":[{"label":"baz"}]}]}`,
+		"progress,sub_tasks(progress)":         `{"sub_tasks":[{"progress":{"done":0,"total":1}}],"progress":{"done":1,"total":2}}`,
+	}
+
+	for value, expected := range tests {
+		t.Log(value)
+
+		fields, err := ParseTaskFields(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := json.Marshal(task.withFields(fields))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expected != string(res) {
+			t.Fatalf("expected \n%s\n got \n%s\n", expected, res)
+		}
+	}
+
+	for _, value := range []string{"", "id,", "owner", "label(id)", "sub_tasks(id", "sub_tasks()", "sub_tasks(id))", "sub_tasks(id)label"} {
+		t.Log(value)
+
+		if _, err := ParseTaskFields(value); err != ErrTaskFieldsNotValid {
+			t.Fatalf("expected error %v got %v", ErrTaskFieldsNotValid, err)
+		}
+	}
+}
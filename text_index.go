@@ -0,0 +1,305 @@
+package tasks
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// labelWeight is weight of term found in the label relative to term found in
+// the notes.
+const labelWeight = 3
+
+// prefixWeight is weight of term which only starts with query token relative
+// to term equal to query token.
+const prefixWeight = 0.5
+
+// TextMatch is Task matching full-text query with its TaskID path and score.
+// Tasks with higher score match the query better.
+type TextMatch struct {
+	Path  TaskIDPath `json:"path"`
+	Task  Task       `json:"task"`
+	Score float64    `json:"score"`
+}
+
+// textPosting is number of occurrences of the term in label and notes of the
+// Task.
+type textPosting struct {
+	label int
+	notes int
+}
+
+// textScore is score of the Task for full-text query.
+type textScore struct {
+	taskID TaskID
+	score  float64
+}
+
+// maxPendingTerms is number of new terms kept aside before they are merged
+// into sorted terms of textIndex.
+const maxPendingTerms = 1024
+
+// textIndex is inverted index of terms in labels and notes of Tasks. It's not
+// safe for concurrent use, TaskMemoryStorage guards it by its lock. Search
+// doesn't change the index so it can run concurrently with other searches.
+type textIndex struct {
+	// postings maps term to Tasks which contain it.
+	postings map[string]map[TaskID]textPosting
+	// terms are indexed terms in sorted order for prefix matching. Removed
+	// terms (without postings) are kept until the terms are compacted so
+	// sorted terms are not copied on every change.
+	terms []string
+	// removed is number of removed terms kept in terms.
+	removed int
+	// pending are new terms which are not merged into terms yet.
+	pending []string
+	// docs maps TaskID to terms of the Task so the Task can be removed.
+	docs map[TaskID][]string
+}
+
+// newTextIndex returns empty textIndex.
+func newTextIndex() *textIndex {
+	return &textIndex{
+		postings: map[string]map[TaskID]textPosting{},
+		terms:    []string{},
+		docs:     map[TaskID][]string{},
+	}
+}
+
+// tokenize splits text into lower case terms. Terms are sequences of letters
+// and digits.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// add indexes label and notes of the Task (without its children). Task which
+// was indexed before is replaced.
+func (x *textIndex) add(task *Task) {
+	x.remove(task.ID)
+
+	postings := map[string]textPosting{}
+	for _, term := range tokenize(task.Label) {
+		p := postings[term]
+		p.label++
+		postings[term] = p
+	}
+	for _, term := range tokenize(task.Notes) {
+		p := postings[term]
+		p.notes++
+		postings[term] = p
+	}
+	if len(postings) == 0 {
+		return
+	}
+
+	terms := make([]string, 0, len(postings))
+	for term, p := range postings {
+		tasks, found := x.postings[term]
+		if !found {
+			tasks = map[TaskID]textPosting{}
+			x.postings[term] = tasks
+			x.insertTerm(term)
+		}
+		tasks[task.ID] = p
+		terms = append(terms, term)
+	}
+	x.docs[task.ID] = terms
+}
+
+// remove removes Task with given TaskID from the index.
+func (x *textIndex) remove(taskID TaskID) {
+	for _, term := range x.docs[taskID] {
+		tasks := x.postings[term]
+		delete(tasks, taskID)
+		if len(tasks) == 0 {
+			delete(x.postings, term)
+			x.removeTerm(term)
+		}
+	}
+	delete(x.docs, taskID)
+}
+
+// insertTerm adds new term into pending terms (or takes back removed term
+// which is still in sorted terms). Pending terms are merged into sorted terms
+// when there are too many of them.
+func (x *textIndex) insertTerm(term string) {
+	i := sort.SearchStrings(x.terms, term)
+	if i < len(x.terms) && x.terms[i] == term {
+		x.removed--
+		return
+	}
+
+	x.pending = append(x.pending, term)
+	if len(x.pending) > maxPendingTerms {
+		x.compact()
+	}
+}
+
+// removeTerm removes term from pending terms or marks it removed in sorted
+// terms. Sorted terms are compacted when most of them are removed.
+func (x *textIndex) removeTerm(term string) {
+	for i, pending := range x.pending {
+		if pending == term {
+			x.pending[i] = x.pending[len(x.pending)-1]
+			x.pending = x.pending[:len(x.pending)-1]
+			return
+		}
+	}
+
+	x.removed++
+	if x.removed > len(x.terms)/2 {
+		x.compact()
+	}
+}
+
+// compact merges pending terms into sorted terms and drops removed terms.
+func (x *textIndex) compact() {
+	x.terms = x.sortedTerms()
+	x.removed = 0
+	x.pending = x.pending[:0]
+}
+
+// sortedTerms returns sorted terms merged with pending terms without removed
+// terms.
+func (x *textIndex) sortedTerms() []string {
+	pending := append([]string{}, x.pending...)
+	sort.Strings(pending)
+
+	terms := make([]string, 0, len(x.terms)-x.removed+len(pending))
+	i, j := 0, 0
+	for i < len(x.terms) || j < len(pending) {
+		if j == len(pending) || (i < len(x.terms) && x.terms[i] < pending[j]) {
+			if _, found := x.postings[x.terms[i]]; found {
+				terms = append(terms, x.terms[i])
+			}
+			i++
+			continue
+		}
+
+		terms = append(terms, pending[j])
+		j++
+	}
+
+	return terms
+}
+
+// search returns Tasks which contain all tokens of the query ordered by score
+// (and TaskID for equal scores). Token matches every term it's prefix of.
+// Score is sum of term frequencies weighted by inverse document frequency of
+// the term, terms in the label and terms equal to the token weigh more.
+func (x *textIndex) search(query string) []textScore {
+	tokens := tokenize(query)
+	if len(tokens) == 0 {
+		return []textScore{}
+	}
+
+	// Tokens matching less Tasks go first so there are less candidates for
+	// the other tokens.
+	type tokenTerms struct {
+		token string
+		terms []string
+		count int
+	}
+	ranges := make([]tokenTerms, len(tokens))
+	for i, token := range tokens {
+		r := tokenTerms{token: token, terms: []string{}}
+		for j := sort.SearchStrings(x.terms, token); j < len(x.terms) && strings.HasPrefix(x.terms[j], token); j++ {
+			// Removed terms have no postings.
+			if tasks, found := x.postings[x.terms[j]]; found {
+				r.terms = append(r.terms, x.terms[j])
+				r.count += len(tasks)
+			}
+		}
+		for _, term := range x.pending {
+			if strings.HasPrefix(term, token) {
+				r.terms = append(r.terms, term)
+				r.count += len(x.postings[term])
+			}
+		}
+		ranges[i] = r
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].count < ranges[j].count })
+
+	var scores map[TaskID]float64
+	for _, r := range ranges {
+		tokenScores := make(map[TaskID]float64, r.count)
+		if scores != nil && len(scores) < r.count {
+			tokenScores = make(map[TaskID]float64, len(scores))
+		}
+
+		for _, term := range r.terms {
+			tasks := x.postings[term]
+
+			weight := math.Log(1 + float64(len(x.docs))/float64(len(tasks)))
+			if term != r.token {
+				weight *= prefixWeight
+			}
+
+			for taskID, p := range tasks {
+				// Candidates are only Tasks matching all previous tokens.
+				if _, found := scores[taskID]; scores != nil && !found {
+					continue
+				}
+				tokenScores[taskID] += float64(labelWeight*p.label+p.notes) * weight
+			}
+		}
+
+		if scores != nil {
+			for taskID, score := range tokenScores {
+				tokenScores[taskID] = score + scores[taskID]
+			}
+		}
+		scores = tokenScores
+
+		if len(scores) == 0 {
+			break
+		}
+	}
+
+	result := make([]textScore, 0, len(scores))
+	for taskID, score := range scores {
+		result = append(result, textScore{taskID: taskID, score: score})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].score != result[j].score {
+			return result[i].score > result[j].score
+		}
+
+		return result[i].taskID < result[j].taskID
+	})
+
+	return result
+}
+
+// FindByText returns Tasks (without children) from the whole tree which
+// contain all words of given full-text query in their labels or notes
+// ordered by score. Only Tasks matching given TaskFilter are returned.
+// FindByText implements TaskService interface.
+func (s *TaskStorageService) FindByText(query string, filter TaskFilter) ([]TextMatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matches, err := s.storage.FindByText(query)
+	if err != nil {
+		fmt.Println("(DEBUG) service: Finding Tasks by text failed.")
+		return nil, err
+	}
+
+	if filter.IsEmpty() {
+		return matches, nil
+	}
+
+	now := s.clock.Now()
+	result := []TextMatch{}
+	for _, match := range matches {
+		if filter.match(&match.Task, now) {
+			result = append(result, match)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,218 @@
+package tasks
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTextIndex(t *testing.T) {
+	x := newTextIndex()
+	x.add(&Task{ID: TaskID(1), Label: "Deploy the app", Notes: "Check logs"})
+	x.add(&Task{ID: TaskID(2), Label: "Write deployment notes"})
+	x.add(&Task{ID: TaskID(3), Label: "foo", Notes: "deploy, deploy, DEPLOY, deploy!"})
+	x.add(&Task{ID: TaskID(4), Label: "bar"})
+
+	tests := map[string]struct {
+		query string
+		res   []TaskID
+	}{
+		"empty": {
+			query: " ,. ",
+			res:   []TaskID{},
+		},
+		"exact match ranks first": {
+			query: "Deploy",
+			res:   []TaskID{TaskID(3), TaskID(1), TaskID(2)},
+		},
+		"prefix": {
+			query: "deployM",
+			res:   []TaskID{TaskID(2)},
+		},
+		"all tokens": {
+			query: "deploy logs",
+			res:   []TaskID{TaskID(1)},
+		},
+		"unknown token": {
+			query: "deploy qux",
+			res:   []TaskID{},
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Log(desc)
+
+		res := []TaskID{}
+		for _, score := range x.search(tc.query) {
+			res = append(res, score.taskID)
+		}
+
+		if !reflect.DeepEqual(tc.res, res) {
+			t.Fatalf("expected TaskIDs %v got %v", tc.res, res)
+		}
+	}
+
+	// Replaced Task is reindexed and removed terms are forgotten.
+	x.add(&Task{ID: TaskID(2), Label: "bar"})
+	x.remove(TaskID(3))
+	x.remove(TaskID(42))
+
+	if res := x.search("dep"); len(res) != 1 || res[0].taskID != TaskID(1) {
+		t.Fatalf("expected Task %d got %v", TaskID(1), res)
+	}
+
+	x.add(&Task{ID: TaskID(5), Label: "qux"})
+
+	expected := []string{"app", "bar", "check", "deploy", "logs", "qux", "the"}
+	if terms := x.sortedTerms(); !reflect.DeepEqual(expected, terms) {
+		t.Fatalf("expected terms %v got %v", expected, terms)
+	}
+
+	x.compact()
+	if !reflect.DeepEqual(expected, x.terms) || len(x.pending) != 0 || x.removed != 0 {
+		t.Fatalf("expected terms %v got %v (pending %v)", expected, x.terms, x.pending)
+	}
+
+	// Removed term is taken back from compacted terms and new term is
+	// pending until compaction.
+	x.remove(TaskID(5))
+	if res := x.search("qux"); len(res) != 0 || x.removed != 1 {
+		t.Fatalf("expected no Tasks got %v", res)
+	}
+
+	x.add(&Task{ID: TaskID(5), Label: "qux baz"})
+	if res := x.search("qux ba"); len(res) != 1 || res[0].taskID != TaskID(5) {
+		t.Fatalf("expected Task %d got %v", TaskID(5), res)
+	}
+
+	expected = []string{"app", "bar", "baz", "check", "deploy", "logs", "qux", "the"}
+	if terms := x.sortedTerms(); !reflect.DeepEqual(expected, terms) || x.removed != 0 || len(x.pending) != 1 {
+		t.Fatalf("expected terms %v got %v (pending %v)", expected, terms, x.pending)
+	}
+}
+
+func TestTaskMemoryStorageFindByText(t *testing.T) {
+	storage := NewTaskMemoryStorage()
+
+	foo := &Task{ID: TaskID(1), Label: "foo deploy"}
+	bar := &Task{ID: TaskID(2), Label: "bar"}
+	if err := storage.Insert([]TaskID{}, foo); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Insert([]TaskID{foo.ID}, bar); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Insert([]TaskID{}, &Task{ID: TaskID(3), Label: "baz"}); err != nil {
+		t.Fatal(err)
+	}
+
+	find := func(query string) []TaskIDPath {
+		matches, err := storage.FindByText(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		paths := []TaskIDPath{}
+		for _, match := range matches {
+			paths = append(paths, match.Path)
+		}
+
+		return paths
+	}
+
+	if err := storage.Update([]TaskID{foo.ID, bar.ID}, &Task{ID: bar.ID, Label: "bar", Notes: "deploy"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []TaskIDPath{TaskIDPath{foo.ID}, TaskIDPath{foo.ID, bar.ID}}
+	if res := find("deploy"); !reflect.DeepEqual(expected, res) {
+		t.Fatalf("expected paths %v got %v", expected, res)
+	}
+
+	if err := storage.Move([]TaskID{foo.ID, bar.ID}, []TaskID{TaskID(3)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storage.Delete([]TaskID{foo.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []TaskIDPath{TaskIDPath{TaskID(3), bar.ID}}
+	if res := find("deploy"); !reflect.DeepEqual(expected, res) {
+		t.Fatalf("expected paths %v got %v", expected, res)
+	}
+}
+
+// benchmarkStorage returns storage with n Tasks in trees of depth 3 with
+// labels and notes generated from small vocabulary.
+func benchmarkStorage(b *testing.B, n int) *TaskMemoryStorage {
+	words := []string{"deploy", "review", "release", "fix", "write", "test", "plan", "meeting", "backend", "frontend", "database", "docs"}
+
+	storage := NewTaskMemoryStorage()
+	parents := [][]TaskID{[]TaskID{}}
+	for i := 0; i < n; i++ {
+		task := &Task{
+			ID:    storage.NextTaskID(),
+			Label: fmt.Sprintf("%s %s task%d", words[i%len(words)], words[(i/7)%len(words)], i),
+			Notes: fmt.Sprintf("%s notes for %s", words[(i/3)%len(words)], words[(i/11)%len(words)]),
+		}
+
+		parent := parents[i%len(parents)]
+		if err := storage.Insert(parent, task); err != nil {
+			b.Fatal(err)
+		}
+
+		if len(parent) < 2 {
+			parents = append(parents, childPath(parent, task.ID))
+		}
+	}
+
+	return storage
+}
+
+func BenchmarkTaskMemoryStorageFindByText(b *testing.B) {
+	storage := benchmarkStorage(b, 100000)
+
+	queries := map[string]string{
+		"rare":   "task4242",
+		"prefix": "task999",
+		"words":  "deploy backend",
+	}
+
+	for name, query := range queries {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := storage.FindByText(query); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTextIndexSearch(b *testing.B) {
+	storage := benchmarkStorage(b, 100000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		storage.text.search("deploy back")
+	}
+}
+
+func BenchmarkTextIndexInsert(b *testing.B) {
+	storage := benchmarkStorage(b, 100000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		storage.text.add(&Task{ID: TaskID(100001 + i), Label: fmt.Sprintf("new%d", i)})
+	}
+}
+
+func BenchmarkTextIndexAdd(b *testing.B) {
+	storage := benchmarkStorage(b, 100000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		storage.text.add(&Task{ID: TaskID(i%100000 + 1), Label: fmt.Sprintf("write docs task%d", i)})
+	}
+}
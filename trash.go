@@ -0,0 +1,238 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrTrashedTaskNotFound
+	ErrTrashedTaskNotFound error = errors.New("Trashed Task not found")
+)
+
+// TrashedTask is deleted Task with its children kept in trash until it's
+// restored or purged.
+type TrashedTask struct {
+	// Task is deleted Task with its children.
+	Task Task `json:"task"`
+	// Path is TaskID path of the Task when it was deleted.
+	Path TaskIDPath `json:"path"`
+	// DeletedAt is time when the Task was deleted.
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// clone returns deep copy of the TrashedTask.
+func (t *TrashedTask) clone() *TrashedTask {
+	trashed := *t
+	trashed.Task = *t.Task.clone()
+	trashed.Path = append(TaskIDPath{}, t.Path...)
+
+	return &trashed
+}
+
+// parentID returns TaskID of the parent of trashed Task or noParentTaskID if
+// it was root Task.
+func (t *TrashedTask) parentID() TaskID {
+	if len(t.Path) < 2 {
+		return noParentTaskID
+	}
+
+	return t.Path[len(t.Path)-2]
+}
+
+// FindTrash returns all trashed Tasks with their children. The most recently
+// deleted Tasks are first.
+// FindTrash implements TaskService interface.
+func (s *TaskStorageService) FindTrash() ([]TrashedTask, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trash, err := s.storage.FindAllTrash()
+	if err != nil {
+		fmt.Println("(DEBUG) service: Finding trashed Tasks failed.")
+		return nil, err
+	}
+
+	sort.Slice(trash, func(i, j int) bool {
+		if !trash[i].DeletedAt.Equal(trash[j].DeletedAt) {
+			return trash[i].DeletedAt.After(trash[j].DeletedAt)
+		}
+
+		return trash[i].Task.ID < trash[j].Task.ID
+	})
+
+	return trash, nil
+}
+
+// Restore moves trashed Task with given TaskID (with its children) back under
+// its original parent or to top level if the parent is not in the tree
+// anymore. Restored Task is placed after its new siblings and links to Tasks
+// which are not in the tree are removed. It returns restored Task and its
+// TaskID path.
+// Restore implements TaskService interface.
+func (s *TaskStorageService) Restore(taskID TaskID) (Task, []TaskID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Restore is applied in transaction so the Task isn't left both in the
+	// tree and in trash when removing its links fails.
+	var task Task
+	var path []TaskID
+	_, err := s.transaction(OperationRestore, 0, func() error {
+		var err error
+		task, path, err = s.restoreTask(taskID)
+		return err
+	})
+	if err != nil {
+		return Task{}, nil, err
+	}
+
+	return task, path, nil
+}
+
+// restoreTask moves trashed Task with given TaskID back into the tree. Caller
+// must hold the lock and apply it in transaction.
+func (s *TaskStorageService) restoreTask(taskID TaskID) (Task, []TaskID, error) {
+	trashed, err := s.storage.FindTrash(taskID)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Restoring Task failed: %s\n", err)
+		return Task{}, nil, err
+	}
+
+	parent := []TaskID{}
+	if parentID := trashed.parentID(); parentID != noParentTaskID {
+		if _, path, err := s.storage.FindByID(parentID); err == nil {
+			parent = path
+		}
+	}
+
+	position, err := s.nextPosition(parent)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Restoring Task failed: %s\n", err)
+		return Task{}, nil, err
+	}
+
+	// Parent of the Task may change so it's new revision of the Task.
+	task := &trashed.Task
+	task.Position = position
+	task.Revision++
+	s.touch(task, task.Completed)
+
+	if err := s.storage.Insert(parent, task); err != nil {
+		fmt.Printf("(DEBUG) service: Restoring Task failed: %s\n", err)
+		return Task{}, nil, err
+	}
+
+	if err := s.storage.DeleteTrash(taskID); err != nil {
+		fmt.Printf("(DEBUG) service: Restoring Task failed: %s\n", err)
+		return Task{}, nil, err
+	}
+
+	tasks, err := s.storage.FindAll()
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Restoring Task failed: %s\n", err)
+		return Task{}, nil, err
+	}
+
+	g := newDependencyGraph(tasks)
+	unknown := map[TaskID]bool{}
+	for _, blockers := range g.blockedBy {
+		for _, blocker := range blockers {
+			if !g.contains(blocker) {
+				unknown[blocker] = true
+			}
+		}
+	}
+
+	if err := s.removeBlockers(unknown); err != nil {
+		fmt.Printf("(DEBUG) service: Removing links of restored Task failed: %s\n", err)
+		return Task{}, nil, err
+	}
+
+	path := childPath(parent, taskID)
+	restoredTask, err := s.storage.Find(path)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Restoring Task failed: %s\n", err)
+		return Task{}, nil, err
+	}
+
+	return restoredTask, path, nil
+}
+
+// Purge removes trashed Task with given TaskID (with its children) from trash
+// and returns it. Purge is recorded in history so it can be undone.
+// Purge implements TaskService interface.
+func (s *TaskStorageService) Purge(taskID TaskID) (TrashedTask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.operation(OperationPurge)()
+
+	trashed, err := s.storage.FindTrash(taskID)
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Purging Task failed: %s\n", err)
+		return TrashedTask{}, err
+	}
+
+	if err := s.storage.DeleteTrash(taskID); err != nil {
+		fmt.Printf("(DEBUG) service: Purging Task failed: %s\n", err)
+		return TrashedTask{}, err
+	}
+
+	return trashed, nil
+}
+
+// PurgeExpired removes Tasks which are in trash longer than given retention.
+// It returns number of purged Tasks. All purged Tasks are recorded in history
+// as one operation.
+func (s *TaskStorageService) PurgeExpired(retention time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.operation(OperationPurge)()
+
+	trash, err := s.storage.FindAllTrash()
+	if err != nil {
+		fmt.Printf("(DEBUG) service: Purging expired Tasks failed: %s\n", err)
+		return 0, err
+	}
+
+	expiredAt := s.clock.Now().Add(-retention)
+	purged := 0
+	for _, trashed := range trash {
+		if !trashed.DeletedAt.Before(expiredAt) {
+			continue
+		}
+
+		if err := s.storage.DeleteTrash(trashed.Task.ID); err != nil {
+			fmt.Printf("(DEBUG) service: Purging expired Tasks failed: %s\n", err)
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// trash moves Task at given TaskID path (with its children) into trash.
+// Trashed Task is stored before it's removed from the tree so it can't be
+// lost.
+func (s *TaskStorageService) trash(path []TaskID, task *Task) error {
+	trashed := &TrashedTask{
+		Task:      *task,
+		Path:      append(TaskIDPath{}, path...),
+		DeletedAt: s.clock.Now().UTC(),
+	}
+
+	if err := s.storage.InsertTrash(trashed); err != nil {
+		return err
+	}
+
+	if err := s.storage.Delete(path); err != nil {
+		// Task is still in the tree so it must not be in trash.
+		s.storage.DeleteTrash(task.ID)
+		return err
+	}
+
+	return nil
+}